@@ -3,12 +3,16 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/dfr99/golang-101/dia_2/reto/makeacall"
+	"github.com/dfr99/golang-101/dia_2/reto/store"
+	"github.com/dfr99/golang-101/dia_2/reto/telefono"
 	"github.com/joho/godotenv"
 )
 
@@ -29,10 +33,28 @@ func main() {
 		log.Fatal("❌ Faltan variables de Twilio en .env")
 	}
 
-	// Mapa de contactos: nombre -> teléfono
-	contactos := make(map[string]string)
+	// Agenda de contactos guardada en disco. La ruta
+	// se puede cambiar con CONTACTOS_FILE.
+	ruta := os.Getenv("CONTACTOS_FILE")
+	if ruta == "" {
+		ruta, err = store.RutaPorDefecto()
+		if err != nil {
+			log.Fatalf("No se encontró el directorio de configuración: %v", err)
+		}
+	}
+	// País de los teléfonos que se escriben sin prefijo internacional.
+	pais := os.Getenv("PAIS_POR_DEFECTO")
+	if pais == "" {
+		pais = "ES"
+	}
+	contactos, err := store.Abrir(ruta, pais)
+	if err != nil {
+		log.Fatalf("Error cargando los contactos: %v", err)
+	}
+	fmt.Printf("📂 %d contactos cargados de %s (país por defecto: %s)\n", contactos.Len(), contactos.Ruta(), contactos.Pais())
 
 	reader := bufio.NewReader(os.Stdin)
+	var ultima deshacer // última acción destructiva, para la opción 8
 
 	// Bucle infinito
 	for {
@@ -41,57 +63,83 @@ func main() {
 		fmt.Println("2. Buscar contacto")
 		fmt.Println("3. Listar contactos")
 		fmt.Println("4. Llamar contacto")
-		fmt.Println("5. Salir")
+		fmt.Println("5. Editar contacto")
+		fmt.Println("6. Renombrar contacto")
+		fmt.Println("7. Eliminar contacto")
+		fmt.Println("8. Deshacer última acción")
+		fmt.Println("9. Salir")
 		fmt.Print("Elige una opción: ")
 
-		opcion, _ := reader.ReadString('\n')
+		opcion, err := reader.ReadString('\n')
+		if err != nil && opcion == "" {
+			// Fin de la entrada (Ctrl+D o fichero terminado).
+			fmt.Println("\n👋 Saliendo...")
+			return
+		}
 		opcion = strings.TrimSpace(opcion)
 
 		switch opcion {
 		case "1":
-			fmt.Print("Nombre: ")
-			nombre, _ := reader.ReadString('\n')
-			nombre = strings.TrimSpace(nombre)
-
-			fmt.Print("Teléfono: ")
-			telefono, _ := reader.ReadString('\n')
-			telefono = strings.TrimSpace(telefono)
-
-			contactos[nombre] = telefono
-			fmt.Println("✅ Contacto agregado.")
+			agregar(reader, contactos, &ultima)
 
 		case "2":
-			fmt.Print("Nombre a buscar: ")
-			nombre, _ := reader.ReadString('\n')
-			nombre = strings.TrimSpace(nombre)
-
-			if telefono, ok := contactos[nombre]; ok {
-				fmt.Printf("📞 %s: %s\n", nombre, telefono)
+			nombre := leer(reader, "Nombre a buscar: ")
+			if c, ok := contactos.Buscar(nombre); ok {
+				mostrar(c)
 			} else {
 				fmt.Println("❌ Contacto no encontrado.")
 			}
 
 		case "3":
 			fmt.Println("\n📋 Lista de contactos:")
-			if len(contactos) == 0 {
+			if contactos.Len() == 0 {
 				fmt.Println("No hay contactos guardados.")
 			}
-			for nombre, telefono := range contactos {
-				fmt.Printf("- %s: %s\n", nombre, telefono)
+			for _, nombre := range contactos.Nombres() {
+				c, _ := contactos.Buscar(nombre)
+				fmt.Println("- " + resumen(c))
 			}
 
 		case "4":
-			fmt.Print("Nombre del contacto a llamar: ")
-			nombre, _ := reader.ReadString('\n')
-			nombre = strings.TrimSpace(nombre)
-
-			if numero, ok := contactos[nombre]; ok {
-				makeacall.Llamar(numero)
-			} else {
+			nombre := leer(reader, "Nombre del contacto a llamar: ")
+			c, ok := contactos.Buscar(nombre)
+			if !ok {
 				fmt.Println("❌ Contacto no encontrado.")
+				continue
+			}
+			numero, ok := elegirTelefono(reader, c)
+			if !ok {
+				continue
+			}
+			// Los números que no se pudieron migrar siguen como se
+			// escribieron: mejor avisar aquí que dejar que falle Twilio.
+			if e164, err := telefono.Normalizar(numero, contactos.Pais()); err != nil {
+				fmt.Printf("❌ No se puede llamar: %v. Corrígelo con la opción 5.\n", err)
+			} else {
+				makeacall.Llamar(e164)
 			}
 
 		case "5":
+			editar(reader, contactos, &ultima)
+
+		case "6":
+			renombrar(reader, contactos, &ultima)
+
+		case "7":
+			eliminar(reader, contactos, &ultima)
+
+		case "8":
+			descripcion, err := ultima.aplicar(contactos)
+			switch {
+			case err != nil:
+				fmt.Printf("❌ No se pudo deshacer (%s): %v\n", descripcion, err)
+			case descripcion == "":
+				fmt.Println("No hay nada que deshacer.")
+			default:
+				fmt.Printf("↩️  Deshecho: %s.\n", descripcion)
+			}
+
+		case "9":
 			fmt.Println("👋 Saliendo...")
 			return
 
@@ -100,3 +148,101 @@ func main() {
 		}
 	}
 }
+
+// agregar pide un contacto nuevo. Si el nombre ya existe pregunta antes de
+// sobrescribirlo.
+func agregar(reader *bufio.Reader, contactos *store.Store, ultima *deshacer) {
+	nombre := leer(reader, "Nombre: ")
+	if nombre == "" {
+		fmt.Println("❌ El nombre no puede estar vacío.")
+		return
+	}
+	anterior, existe := contactos.Buscar(nombre)
+	if existe {
+		mostrar(anterior)
+		if !confirmar(reader, fmt.Sprintf("⚠️  %s ya existe. ¿Sobrescribir?", nombre)) {
+			fmt.Println("Contacto sin cambios.")
+			return
+		}
+	}
+
+	c := pedirDatos(reader, store.Contacto{Nombre: nombre}, contactos.Pais())
+	if err := contactos.Guardar(c); err != nil {
+		fmt.Printf("❌ No se pudo guardar el contacto: %v\n", err)
+		return
+	}
+	if existe {
+		ultima.modificado(anterior)
+		fmt.Println("✅ Contacto sobrescrito.")
+		return
+	}
+	ultima.olvidar()
+	fmt.Println("✅ Contacto agregado.")
+}
+
+// editar cambia los datos de un contacto existente; lo que se deja vacío
+// no cambia.
+func editar(reader *bufio.Reader, contactos *store.Store, ultima *deshacer) {
+	nombre := leer(reader, "Nombre del contacto: ")
+	anterior, ok := contactos.Buscar(nombre)
+	if !ok {
+		fmt.Println("❌ Contacto no encontrado.")
+		return
+	}
+	fmt.Println("Deja un campo vacío para no cambiarlo, o escribe - para borrarlo.")
+	c := pedirDatos(reader, anterior, contactos.Pais())
+	if reflect.DeepEqual(c, anterior) {
+		fmt.Println("Contacto sin cambios.")
+		return
+	}
+	if err := contactos.Guardar(c); err != nil {
+		fmt.Printf("❌ No se pudo guardar el contacto: %v\n", err)
+		return
+	}
+	ultima.modificado(anterior)
+	fmt.Println("✅ Contacto actualizado.")
+}
+
+// renombrar cambia el nombre de un contacto conservando el resto de sus
+// datos.
+func renombrar(reader *bufio.Reader, contactos *store.Store, ultima *deshacer) {
+	viejo := leer(reader, "Nombre actual: ")
+	if _, ok := contactos.Buscar(viejo); !ok {
+		fmt.Println("❌ Contacto no encontrado.")
+		return
+	}
+	nuevo := leer(reader, "Nuevo nombre: ")
+	if nuevo == "" || nuevo == viejo {
+		fmt.Println("Contacto sin cambios.")
+		return
+	}
+	switch err := contactos.Renombrar(viejo, nuevo); {
+	case errors.Is(err, store.ErrYaExiste):
+		fmt.Printf("❌ Ya hay un contacto llamado %s.\n", nuevo)
+	case err != nil:
+		fmt.Printf("❌ No se pudo renombrar el contacto: %v\n", err)
+	default:
+		ultima.renombrado(viejo, nuevo)
+		fmt.Println("✅ Contacto renombrado.")
+	}
+}
+
+// eliminar borra un contacto tras confirmarlo.
+func eliminar(reader *bufio.Reader, contactos *store.Store, ultima *deshacer) {
+	nombre := leer(reader, "Nombre del contacto a eliminar: ")
+	c, ok := contactos.Buscar(nombre)
+	if !ok {
+		fmt.Println("❌ Contacto no encontrado.")
+		return
+	}
+	if !confirmar(reader, fmt.Sprintf("¿Eliminar a %s?", resumen(c))) {
+		fmt.Println("Contacto sin cambios.")
+		return
+	}
+	if err := contactos.Borrar(nombre); err != nil {
+		fmt.Printf("❌ No se pudo eliminar el contacto: %v\n", err)
+		return
+	}
+	ultima.borrado(c)
+	fmt.Println("🗑️  Contacto eliminado (opción 8 para deshacer).")
+}
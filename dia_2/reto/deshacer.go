@@ -0,0 +1,58 @@
+package main
+
+import "github.com/dfr99/golang-101/dia_2/reto/store"
+
+// deshacer recuerda la última acción destructiva de la sesión (sobrescribir,
+// editar, renombrar o eliminar) y sabe revertirla. Solo se guarda una:
+// deshacer dos veces seguidas no vuelve más atrás.
+type deshacer struct {
+	descripcion string
+	revertir    func(*store.Store) error
+}
+
+// recordar guarda cómo revertir la acción descrita por descripcion.
+func (d *deshacer) recordar(descripcion string, revertir func(*store.Store) error) {
+	d.descripcion, d.revertir = descripcion, revertir
+}
+
+// modificado recuerda cómo era el contacto antes de cambiarlo.
+func (d *deshacer) modificado(anterior store.Contacto) {
+	d.recordar("cambio de "+anterior.Nombre, func(s *store.Store) error {
+		return s.Restaurar(anterior)
+	})
+}
+
+// borrado recuerda el contacto eliminado. Si al deshacer ya hay otro con
+// su nombre (por ejemplo, desde otra sesión), no lo pisa.
+func (d *deshacer) borrado(c store.Contacto) {
+	d.recordar("eliminación de "+c.Nombre, func(s *store.Store) error {
+		return s.Reponer(c)
+	})
+}
+
+// olvidar descarta la acción guardada: tras cualquier otro cambio ya no
+// sería la última.
+func (d *deshacer) olvidar() {
+	*d = deshacer{}
+}
+
+// renombrado recuerda que nuevo se llamaba viejo.
+func (d *deshacer) renombrado(viejo, nuevo string) {
+	d.recordar("renombrado de "+viejo+" a "+nuevo, func(s *store.Store) error {
+		return s.Renombrar(nuevo, viejo)
+	})
+}
+
+// aplicar revierte la última acción y la olvida. Devuelve su descripción,
+// o "" si no había nada que deshacer.
+func (d *deshacer) aplicar(s *store.Store) (string, error) {
+	if d.revertir == nil {
+		return "", nil
+	}
+	if err := d.revertir(s); err != nil {
+		return d.descripcion, err
+	}
+	descripcion := d.descripcion
+	d.olvidar()
+	return descripcion, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dfr99/golang-101/dia_2/reto/store"
+)
+
+func TestDeshacer(t *testing.T) {
+	ana := store.Contacto{Nombre: "Ana", Telefonos: []store.Telefono{{Tipo: store.TipoMovil, Numero: "600111222"}}}
+	s, err := store.Abrir(filepath.Join(t.TempDir(), "contactos.json"), "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Guardar(ana)
+	ana, _ = s.Buscar("Ana") // como en el menú: se recuerda lo guardado
+
+	var d deshacer
+	if desc, err := d.aplicar(s); desc != "" || err != nil {
+		t.Fatalf("sin acciones: aplicar = %q, %v; esperado nada", desc, err)
+	}
+
+	tests := []struct {
+		nombre string
+		hacer  func()            // la acción y su registro en d
+		want   map[string]string // nombre → teléfono
+	}{
+		{"edición", func() {
+			s.Guardar(store.Contacto{Nombre: "Ana", Empresa: "ACME"})
+			d.modificado(ana)
+		}, map[string]string{"Ana": "+34600111222"}},
+		{"renombrado", func() {
+			s.Renombrar("Ana", "Anita")
+			d.renombrado("Ana", "Anita")
+		}, map[string]string{"Ana": "+34600111222"}},
+		{"eliminación", func() {
+			s.Borrar("Ana")
+			d.borrado(ana)
+		}, map[string]string{"Ana": "+34600111222"}},
+	}
+	for _, tt := range tests {
+		tt.hacer()
+		if _, err := d.aplicar(s); err != nil {
+			t.Fatalf("%s: %v", tt.nombre, err)
+		}
+		for nombre, tel := range tt.want {
+			if got, ok := s.Buscar(nombre); !ok || !reflect.DeepEqual(got.Telefonos, []store.Telefono{{Tipo: store.TipoMovil, Numero: tel}}) || s.Len() != len(tt.want) {
+				t.Errorf("%s: tras deshacer %v, Buscar(%q) = %+v; esperado %q", tt.nombre, s.Nombres(), nombre, got, tel)
+			}
+		}
+		// Solo se deshace una vez.
+		if desc, _ := d.aplicar(s); desc != "" {
+			t.Errorf("%s: segundo aplicar = %q; esperado nada", tt.nombre, desc)
+		}
+	}
+	if got := s.Nombres(); !slices.Equal(got, []string{"Ana"}) {
+		t.Errorf("Nombres() = %v; esperado [Ana]", got)
+	}
+}
+
+func TestDeshacerEliminacionTrasAlta(t *testing.T) {
+	s, err := store.Abrir(filepath.Join(t.TempDir(), "contactos.json"), "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vieja := store.Contacto{Nombre: "Ana", Empresa: "Vieja"}
+	nueva := store.Contacto{Nombre: "Ana", Empresa: "Nueva"}
+	s.Guardar(vieja)
+
+	// Se borra Ana y se da de alta otra Ana desde el menú: deshacer ya no
+	// tiene nada que hacer.
+	var d deshacer
+	s.Borrar("Ana")
+	d.borrado(vieja)
+	agregar(bufio.NewReader(strings.NewReader("Ana\n\n\n\n\nNueva\n")), s, &d)
+	if desc, err := d.aplicar(s); desc != "" || err != nil {
+		t.Errorf("tras el alta: aplicar = %q, %v; esperado nada", desc, err)
+	}
+	if got, _ := s.Buscar("Ana"); got.Empresa != "Nueva" {
+		t.Errorf("Buscar(Ana).Empresa = %q; esperado Nueva", got.Empresa)
+	}
+
+	// Si la otra Ana la crea otra sesión, deshacer se niega a pisarla.
+	s.Borrar("Ana")
+	d.borrado(vieja)
+	s.Guardar(nueva)
+	if _, err := d.aplicar(s); !errors.Is(err, store.ErrYaExiste) {
+		t.Errorf("aplicar con el nombre ocupado = %v; esperado %v", err, store.ErrYaExiste)
+	}
+	if got, _ := s.Buscar("Ana"); got.Empresa != "Nueva" {
+		t.Errorf("Buscar(Ana).Empresa = %q; esperado Nueva", got.Empresa)
+	}
+}
+
+func TestDeshacerTelefonoAntiguo(t *testing.T) {
+	// Un número de la versión 1 que no se pudo pasar a E.164.
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	if err := os.WriteFile(ruta, []byte(`{"Pepe": "123"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.Abrir(ruta, "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pepe, _ := s.Buscar("Pepe")
+
+	var d deshacer
+	if err := s.Borrar("Pepe"); err != nil {
+		t.Fatal(err)
+	}
+	d.borrado(pepe)
+	if _, err := d.aplicar(s); err != nil {
+		t.Fatalf("deshacer la eliminación: %v", err)
+	}
+	if got, ok := s.Buscar("Pepe"); !ok || !reflect.DeepEqual(got, pepe) {
+		t.Errorf("tras deshacer, Buscar(Pepe) = %+v, %v; esperado %+v", got, ok, pepe)
+	}
+
+	// Editar otro campo también funciona, y se puede deshacer.
+	editado := pepe
+	editado.Empresa = "ACME"
+	if err := s.Guardar(editado); err != nil {
+		t.Fatalf("Guardar con el número antiguo sin cambiar: %v", err)
+	}
+	d.modificado(pepe)
+	if _, err := d.aplicar(s); err != nil {
+		t.Fatalf("deshacer la edición: %v", err)
+	}
+	if got, _ := s.Buscar("Pepe"); !reflect.DeepEqual(got, pepe) {
+		t.Errorf("tras deshacer la edición, Buscar(Pepe) = %+v; esperado %+v", got, pepe)
+	}
+}
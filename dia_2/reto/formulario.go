@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dfr99/golang-101/dia_2/reto/store"
+	"github.com/dfr99/golang-101/dia_2/reto/telefono"
+)
+
+// nombresTipo son los nombres de los tipos de teléfono que se muestran.
+var nombresTipo = map[string]string{
+	store.TipoMovil:   "Móvil",
+	store.TipoTrabajo: "Trabajo",
+	store.TipoCasa:    "Casa",
+}
+
+// leer muestra texto y devuelve la línea que escriba el usuario, sin
+// espacios alrededor.
+func leer(reader *bufio.Reader, texto string) string {
+	fmt.Print(texto)
+	linea, _ := reader.ReadString('\n')
+	return strings.TrimSpace(linea)
+}
+
+// confirmar hace una pregunta de sí o no; cualquier cosa que no sea "s" o
+// "si" cuenta como no.
+func confirmar(reader *bufio.Reader, pregunta string) bool {
+	switch strings.ToLower(leer(reader, pregunta+" (s/n): ")) {
+	case "s", "si", "sí":
+		return true
+	}
+	return false
+}
+
+// campo pide un dato mostrando el valor actual entre corchetes. Si el
+// usuario no escribe nada se queda el actual; "-" lo borra.
+func campo(reader *bufio.Reader, etiqueta, actual string) string {
+	texto := etiqueta + ": "
+	if actual != "" {
+		texto = etiqueta + " [" + actual + "]: "
+	}
+	switch v := leer(reader, texto); v {
+	case "":
+		return actual
+	case "-":
+		return ""
+	default:
+		return v
+	}
+}
+
+// campoLista es como campo para una lista separada por comas.
+func campoLista(reader *bufio.Reader, etiqueta string, actual []string) []string {
+	var lista []string
+	for _, v := range strings.Split(campo(reader, etiqueta, strings.Join(actual, ", ")), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			lista = append(lista, v)
+		}
+	}
+	return lista
+}
+
+// campoTelefonos es como campoLista para los teléfonos: los pasa a E.164
+// (los nacionales, como de pais) y vuelve a preguntar mientras alguno sea
+// inválido. Los números antiguos que ya tenía el contacto se aceptan
+// tal cual: se corrigen cuando el usuario quiera.
+func campoTelefonos(reader *bufio.Reader, etiqueta string, actual []string, pais string) []string {
+	for {
+		numeros := campoLista(reader, etiqueta, actual)
+		var errs []error
+		for i, n := range numeros {
+			e164, err := telefono.Normalizar(n, pais)
+			if err != nil && slices.Contains(actual, n) {
+				fmt.Printf("⚠️  %v (se deja como estaba)\n", err)
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			numeros[i] = e164
+		}
+		if len(errs) == 0 {
+			return numeros
+		}
+		for _, err := range errs {
+			fmt.Printf("❌ %v\n", err)
+		}
+		fmt.Println("Corrige los números o escribe - para borrarlos.")
+	}
+}
+
+// pedirDatos pide todos los campos del contacto partiendo de base (vacío
+// al agregar, el contacto actual al editar). El nombre no se pide: se
+// cambia con la opción de renombrar. Los teléfonos sin prefijo
+// internacional son del país pais.
+func pedirDatos(reader *bufio.Reader, base store.Contacto, pais string) store.Contacto {
+	c := store.Contacto{Nombre: base.Nombre}
+	// Un número por tipo, o varios separados por comas.
+	for _, tipo := range store.TiposTelefono {
+		var actuales []string
+		for _, t := range base.Telefonos {
+			if t.Tipo == tipo {
+				actuales = append(actuales, t.Numero)
+			}
+		}
+		for _, numero := range campoTelefonos(reader, "Teléfono "+strings.ToLower(nombresTipo[tipo]), actuales, pais) {
+			c.Telefonos = append(c.Telefonos, store.Telefono{Tipo: tipo, Numero: numero})
+		}
+	}
+	c.Emails = campoLista(reader, "Emails (separados por comas)", base.Emails)
+	c.Empresa = campo(reader, "Empresa", base.Empresa)
+	c.Cumpleanos = campo(reader, "Cumpleaños (AAAA-MM-DD)", base.Cumpleanos)
+	c.Etiquetas = campoLista(reader, "Etiquetas (separadas por comas)", base.Etiquetas)
+	c.Notas = campo(reader, "Notas", base.Notas)
+	return c
+}
+
+// mostrar imprime la ficha completa del contacto.
+func mostrar(c store.Contacto) {
+	fmt.Printf("📇 %s\n", c.Nombre)
+	for _, t := range c.Telefonos {
+		fmt.Printf("   📞 %s: %s\n", nombresTipo[t.Tipo], t.Numero)
+	}
+	for _, e := range c.Emails {
+		fmt.Printf("   ✉️  %s\n", e)
+	}
+	if c.Empresa != "" {
+		fmt.Printf("   🏢 %s\n", c.Empresa)
+	}
+	if c.Cumpleanos != "" {
+		fmt.Printf("   🎂 %s\n", c.Cumpleanos)
+	}
+	if len(c.Etiquetas) > 0 {
+		fmt.Printf("   🏷️  %s\n", strings.Join(c.Etiquetas, ", "))
+	}
+	if c.Notas != "" {
+		fmt.Printf("   📝 %s\n", c.Notas)
+	}
+}
+
+// resumen devuelve una línea con el nombre, el primer teléfono y las
+// etiquetas, para las listas.
+func resumen(c store.Contacto) string {
+	s := c.Nombre
+	if len(c.Telefonos) > 0 {
+		t := c.Telefonos[0]
+		s += fmt.Sprintf(": %s (%s", t.Numero, strings.ToLower(nombresTipo[t.Tipo]))
+		if n := len(c.Telefonos) - 1; n > 0 {
+			s += fmt.Sprintf(", +%d", n)
+		}
+		s += ")"
+	}
+	if len(c.Etiquetas) > 0 {
+		s += " [" + strings.Join(c.Etiquetas, ", ") + "]"
+	}
+	return s
+}
+
+// elegirTelefono devuelve el número al que llamar: el único que tenga el
+// contacto o, si tiene varios, el que elija el usuario.
+func elegirTelefono(reader *bufio.Reader, c store.Contacto) (string, bool) {
+	switch len(c.Telefonos) {
+	case 0:
+		fmt.Printf("❌ %s no tiene ningún teléfono.\n", c.Nombre)
+		return "", false
+	case 1:
+		return c.Telefonos[0].Numero, true
+	}
+	for i, t := range c.Telefonos {
+		fmt.Printf("%d. %s: %s\n", i+1, nombresTipo[t.Tipo], t.Numero)
+	}
+	n, err := strconv.Atoi(leer(reader, fmt.Sprintf("¿A qué número llamo? (1-%d): ", len(c.Telefonos))))
+	if err != nil || n < 1 || n > len(c.Telefonos) {
+		fmt.Println("Opción no válida.")
+		return "", false
+	}
+	return c.Telefonos[n-1].Numero, true
+}
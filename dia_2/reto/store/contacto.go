@@ -0,0 +1,114 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dfr99/golang-101/dia_2/reto/telefono"
+)
+
+// Tipos de teléfono.
+const (
+	TipoMovil   = "movil"
+	TipoTrabajo = "trabajo"
+	TipoCasa    = "casa"
+)
+
+// TiposTelefono son los tipos válidos, en el orden en que se muestran.
+var TiposTelefono = []string{TipoMovil, TipoTrabajo, TipoCasa}
+
+// FormatoCumpleanos es el formato de Contacto.Cumpleanos (AAAA-MM-DD).
+const FormatoCumpleanos = time.DateOnly
+
+// Telefono es un número con su tipo.
+type Telefono struct {
+	Tipo   string `json:"tipo"` // TipoMovil, TipoTrabajo o TipoCasa
+	Numero string `json:"numero"`
+}
+
+// Contacto es una entrada de la agenda. Solo el nombre es obligatorio.
+type Contacto struct {
+	Nombre     string     `json:"nombre"`
+	Telefonos  []Telefono `json:"telefonos,omitempty"`
+	Emails     []string   `json:"emails,omitempty"`
+	Empresa    string     `json:"empresa,omitempty"`
+	Notas      string     `json:"notas,omitempty"`
+	Cumpleanos string     `json:"cumpleanos,omitempty"` // AAAA-MM-DD
+	Etiquetas  []string   `json:"etiquetas,omitempty"`
+}
+
+// Validar comprueba que los campos del contacto tengan sentido.
+func (c Contacto) Validar() error {
+	var errs []error
+	if strings.TrimSpace(c.Nombre) == "" {
+		errs = append(errs, errors.New("el nombre no puede estar vacío"))
+	}
+	for _, t := range c.Telefonos {
+		if !slices.Contains(TiposTelefono, t.Tipo) {
+			errs = append(errs, fmt.Errorf("tipo de teléfono %q desconocido (usa %s)", t.Tipo, strings.Join(TiposTelefono, ", ")))
+		}
+		if strings.TrimSpace(t.Numero) == "" {
+			errs = append(errs, fmt.Errorf("el teléfono %s está vacío", t.Tipo))
+		}
+	}
+	for _, e := range c.Emails {
+		if at := strings.Index(e, "@"); at < 1 || at == len(e)-1 || strings.ContainsAny(e, " \t") {
+			errs = append(errs, fmt.Errorf("email %q inválido", e))
+		}
+	}
+	if c.Cumpleanos != "" {
+		if _, err := time.Parse(FormatoCumpleanos, c.Cumpleanos); err != nil {
+			errs = append(errs, fmt.Errorf("cumpleaños %q inválido (usa AAAA-MM-DD)", c.Cumpleanos))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// normalizar devuelve una copia del contacto con los teléfonos en E.164,
+// interpretando los nacionales como del país pais. Los números que están
+// tal cual en guardados se dejan como están. Los errores de todos los
+// teléfonos inválidos se devuelven juntos.
+func (c Contacto) normalizar(pais string, guardados []Telefono) (Contacto, error) {
+	c = c.clonar()
+	var errs []error
+	for i, t := range c.Telefonos {
+		if strings.TrimSpace(t.Numero) == "" {
+			continue // ya lo señala Validar
+		}
+		if slices.ContainsFunc(guardados, func(g Telefono) bool { return g.Numero == t.Numero }) {
+			continue // número antiguo que no se pudo migrar
+		}
+		n, err := telefono.Normalizar(t.Numero, pais)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.Telefonos[i].Numero = n
+	}
+	return c, errors.Join(errs...)
+}
+
+// clonar copia el contacto sin compartir sus slices.
+func (c Contacto) clonar() Contacto {
+	c.Telefonos = slices.Clone(c.Telefonos)
+	c.Emails = slices.Clone(c.Emails)
+	c.Etiquetas = slices.Clone(c.Etiquetas)
+	return c
+}
+
+// desdeMapa convierte la agenda del formato antiguo (nombre → teléfono)
+// al actual. Cada teléfono pasa a ser el móvil del contacto.
+func desdeMapa(viejo map[string]string) map[string]Contacto {
+	contactos := make(map[string]Contacto, len(viejo))
+	for nombre, numero := range viejo {
+		c := Contacto{Nombre: nombre}
+		if numero != "" {
+			c.Telefonos = []Telefono{{Tipo: TipoMovil, Numero: numero}}
+		}
+		contactos[nombre] = c
+	}
+	return contactos
+}
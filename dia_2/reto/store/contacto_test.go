@@ -0,0 +1,181 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dfr99/golang-101/dia_2/reto/telefono"
+)
+
+func TestValidar(t *testing.T) {
+	tests := []struct {
+		nombre  string
+		c       Contacto
+		wantErr bool
+	}{
+		{"solo nombre", Contacto{Nombre: "Ana"}, false},
+		{"completo", Contacto{
+			Nombre:     "Ana",
+			Telefonos:  []Telefono{{TipoMovil, "600111222"}, {TipoTrabajo, "910000000"}, {TipoCasa, "915555555"}},
+			Emails:     []string{"ana@example.com"},
+			Empresa:    "ACME",
+			Notas:      "prefiere WhatsApp",
+			Cumpleanos: "1990-02-28",
+			Etiquetas:  []string{"familia"},
+		}, false},
+		{"sin nombre", Contacto{Nombre: "  "}, true},
+		{"tipo desconocido", Contacto{Nombre: "Ana", Telefonos: []Telefono{{"fax", "600"}}}, true},
+		{"teléfono vacío", Contacto{Nombre: "Ana", Telefonos: []Telefono{{TipoMovil, ""}}}, true},
+		{"email sin @", Contacto{Nombre: "Ana", Emails: []string{"ana.example.com"}}, true},
+		{"email sin dominio", Contacto{Nombre: "Ana", Emails: []string{"ana@"}}, true},
+		{"cumpleaños imposible", Contacto{Nombre: "Ana", Cumpleanos: "1990-02-30"}, true},
+		{"cumpleaños sin año", Contacto{Nombre: "Ana", Cumpleanos: "02-28"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.c.Validar(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validar() = %v; esperado error %v", tt.nombre, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGuardarContactoCompleto(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	s, _ := Abrir(ruta, "ES")
+	c := Contacto{
+		Nombre:     "Ana",
+		Telefonos:  []Telefono{{TipoMovil, "600111222"}, {TipoCasa, "915555555"}},
+		Emails:     []string{"ana@example.com", "ana@trabajo.es"},
+		Empresa:    "ACME",
+		Notas:      "prefiere WhatsApp",
+		Cumpleanos: "1990-02-28",
+		Etiquetas:  []string{"familia", "urgente"},
+	}
+	if err := s.Guardar(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Guardar(Contacto{Nombre: "Luis", Telefonos: []Telefono{{"fax", "1"}}}); err == nil {
+		t.Error("Guardar con un tipo inválido: err = nil; esperado error")
+	}
+
+	// Se guarda igual, con los teléfonos en E.164.
+	c.Telefonos = []Telefono{{TipoMovil, "+34600111222"}, {TipoCasa, "+34915555555"}}
+	s, _ = Abrir(ruta, "ES")
+	got, ok := s.Buscar("Ana")
+	if !ok || !reflect.DeepEqual(got, c) {
+		t.Errorf("Buscar(Ana) = %+v; esperado %+v", got, c)
+	}
+	// Cambiar lo devuelto no cambia la agenda.
+	got.Telefonos[0].Numero = "000"
+	if again, _ := s.Buscar("Ana"); again.Telefonos[0].Numero != "+34600111222" {
+		t.Error("Buscar devuelve slices compartidos con la agenda")
+	}
+}
+
+func TestMigracionFormatoAntiguo(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	viejo := []byte(`{"Ana": "600 111 222", "Luis": "+34600333444", "version": "910000000", "Pepe": "123"}`)
+	if err := os.WriteFile(ruta, viejo, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Abrir(ruta, "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Contacto{
+		"Ana":     movil("Ana", "+34600111222"),
+		"Luis":    movil("Luis", "+34600333444"),
+		"version": movil("version", "+34910000000"), // un contacto llamado así no confunde a la migración
+		"Pepe":    movil("Pepe", "123"),             // inválido: se deja como estaba
+	}
+	if s.Len() != len(want) {
+		t.Fatalf("Nombres() = %v; esperado %d contactos", s.Nombres(), len(want))
+	}
+	for nombre, c := range want {
+		if got, _ := s.Buscar(nombre); !reflect.DeepEqual(got, c) {
+			t.Errorf("Buscar(%q) = %+v; esperado %+v", nombre, got, c)
+		}
+	}
+
+	// El original queda en .v1 y el fichero pasa al formato nuevo.
+	if copia, err := os.ReadFile(ruta + ".v1"); err != nil || string(copia) != string(viejo) {
+		t.Errorf("copia del formato antiguo = %q, %v", copia, err)
+	}
+	data, _ := os.ReadFile(ruta)
+	var f fichero
+	if err := json.Unmarshal(data, &f); err != nil || f.Version != versionFichero || len(f.Contactos) != 4 {
+		t.Errorf("fichero migrado = %s (%v)", data, err)
+	}
+}
+
+func TestVersionFutura(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	if err := os.WriteFile(ruta, []byte(`{"version": 99, "contactos": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Abrir(ruta, "ES"); err == nil {
+		t.Error("Abrir con una versión futura: err = nil; esperado error")
+	}
+}
+
+func TestMigracionVersion2(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	v2 := []byte(`{"version": 2, "contactos": [{"nombre": "Ana", "telefonos": [{"tipo": "casa", "numero": "91 555 55 55"}], "empresa": "ACME"}]}`)
+	if err := os.WriteFile(ruta, v2, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Abrir(ruta, "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Contacto{Nombre: "Ana", Telefonos: []Telefono{{TipoCasa, "+34915555555"}}, Empresa: "ACME"}
+	if got, _ := s.Buscar("Ana"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Buscar(Ana) = %+v; esperado %+v", got, want)
+	}
+	if copia, err := os.ReadFile(ruta + ".v2"); err != nil || string(copia) != string(v2) {
+		t.Errorf("copia de la versión 2 = %q, %v", copia, err)
+	}
+}
+
+func TestGuardarTelefonoInvalido(t *testing.T) {
+	s, _ := Abrir(filepath.Join(t.TempDir(), "contactos.json"), "ES")
+	err := s.Guardar(Contacto{Nombre: "Ana", Telefonos: []Telefono{{TipoMovil, "500111222"}, {TipoCasa, "12"}}})
+	if !errors.Is(err, telefono.ErrInvalido) {
+		t.Errorf("Guardar = %v; esperado telefono.ErrInvalido", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d; esperado 0", s.Len())
+	}
+	if _, err := Abrir(filepath.Join(t.TempDir(), "contactos.json"), "XX"); err == nil {
+		t.Error("Abrir con un país desconocido: err = nil; esperado error")
+	}
+}
+
+func TestGuardarConservaTelefonoAntiguo(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	if err := os.WriteFile(ruta, []byte(`{"Pepe": "123"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := Abrir(ruta, "ES")
+
+	// El número antiguo se conserva si no cambia...
+	c := Contacto{Nombre: "Pepe", Telefonos: []Telefono{{TipoMovil, "123"}, {TipoCasa, "915555555"}}, Empresa: "ACME"}
+	if err := s.Guardar(c); err != nil {
+		t.Fatalf("Guardar = %v; esperado nil", err)
+	}
+	want := Contacto{Nombre: "Pepe", Telefonos: []Telefono{{TipoMovil, "123"}, {TipoCasa, "+34915555555"}}, Empresa: "ACME"}
+	if got, _ := s.Buscar("Pepe"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Buscar(Pepe) = %+v; esperado %+v", got, want)
+	}
+	// ...pero no vale para otro contacto ni para un número nuevo.
+	if err := s.Guardar(movil("Luis", "123")); !errors.Is(err, telefono.ErrInvalido) {
+		t.Errorf("Guardar(Luis) = %v; esperado telefono.ErrInvalido", err)
+	}
+	if err := s.Guardar(movil("Pepe", "124")); !errors.Is(err, telefono.ErrInvalido) {
+		t.Errorf("Guardar(Pepe, 124) = %v; esperado telefono.ErrInvalido", err)
+	}
+}
@@ -0,0 +1,382 @@
+// Package store guarda los contactos en un fichero JSON para que no se
+// pierdan al salir del programa.
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dfr99/golang-101/dia_2/reto/telefono"
+)
+
+// Errores de las operaciones sobre un contacto.
+var (
+	ErrNoExiste = errors.New("el contacto no existe")
+	ErrYaExiste = errors.New("ya existe un contacto con ese nombre")
+)
+
+// ErrBloqueado indica que otro proceso tiene el fichero bloqueado desde
+// hace más de lo que se está dispuesto a esperar.
+var ErrBloqueado = errors.New("el fichero de contactos está bloqueado por otro proceso")
+
+// Tiempos del bloqueo. Una escritura tarda milisegundos, así que un
+// bloqueo más antiguo que bloqueoCaducado es de un proceso que murió sin
+// liberarlo.
+var (
+	esperaBloqueo   = 5 * time.Second
+	bloqueoCaducado = 30 * time.Second
+)
+
+// versionFichero es la versión actual del formato del fichero. La 1 era
+// un objeto JSON nombre → teléfono, sin número de versión; la 2 guardaba
+// los teléfonos tal y como se escribieron, y desde la 3 van en E.164.
+const versionFichero = 3
+
+// fichero es el contenido de contactos.json.
+type fichero struct {
+	Version   int        `json:"version"`
+	Contactos []Contacto `json:"contactos"` // por nombre
+}
+
+// Store es la agenda de contactos guardada en un fichero JSON.
+type Store struct {
+	ruta      string
+	pais      string              // país de los números sin prefijo internacional
+	contactos map[string]Contacto // por nombre
+	version   int                 // versión del fichero leído (0 si no existe)
+}
+
+// RutaPorDefecto devuelve dónde se guardan los contactos: contactos.json
+// dentro del directorio de configuración del usuario (por ejemplo
+// ~/.config/golang-101 en Linux).
+func RutaPorDefecto() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "golang-101", "contactos.json"), nil
+}
+
+// Abrir carga los contactos de ruta. Los teléfonos sin prefijo
+// internacional se interpretan como del país pais (código ISO, "ES").
+// Si el fichero no existe se empieza con la agenda vacía; el fichero se
+// crea con el primer cambio. Un fichero de una versión anterior se
+// convierte a la actual y el original se guarda en ruta + ".v<versión>".
+func Abrir(ruta, pais string) (*Store, error) {
+	if _, ok := telefono.BuscarPais(pais); !ok {
+		return nil, fmt.Errorf("país %q no soportado (usa uno de %s)", pais, strings.Join(telefono.Codigos(), ", "))
+	}
+	if err := os.MkdirAll(filepath.Dir(ruta), 0o700); err != nil {
+		return nil, err
+	}
+	s := &Store{ruta: ruta, pais: pais}
+	if err := s.cargar(); err != nil {
+		return nil, err
+	}
+	if s.version != 0 && s.version < versionFichero {
+		if err := s.migrar(); err != nil {
+			return nil, fmt.Errorf("migrando %s: %w", ruta, err)
+		}
+	}
+	return s, nil
+}
+
+// migrar reescribe el fichero en el formato actual, dejando una copia del
+// anterior.
+func (s *Store) migrar() error {
+	return s.modificar(func(map[string]Contacto) error {
+		if s.version == versionFichero {
+			return nil // otra terminal se adelantó
+		}
+		data, err := os.ReadFile(s.ruta)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(s.ruta+".v"+strconv.Itoa(s.version), data, 0o600)
+	})
+}
+
+// Ruta devuelve la ruta del fichero.
+func (s *Store) Ruta() string {
+	return s.ruta
+}
+
+// Pais devuelve el país de los números sin prefijo internacional.
+func (s *Store) Pais() string {
+	return s.pais
+}
+
+// Buscar devuelve el contacto nombre.
+func (s *Store) Buscar(nombre string) (Contacto, bool) {
+	c, ok := s.contactos[nombre]
+	return c.clonar(), ok
+}
+
+// Nombres devuelve los nombres de los contactos en orden alfabético.
+func (s *Store) Nombres() []string {
+	return slices.Sorted(maps.Keys(s.contactos))
+}
+
+// Len devuelve cuántos contactos hay.
+func (s *Store) Len() int {
+	return len(s.contactos)
+}
+
+// Guardar añade el contacto c, o lo sustituye si ya hay uno con su
+// nombre, y lo escribe a disco. Los teléfonos se guardan en E.164; si
+// alguno no es válido no se guarda nada. Se exceptúan los que el contacto
+// ya tenía guardados tal cual (números antiguos que la migración no pudo
+// normalizar), para poder editar el resto de la ficha.
+func (s *Store) Guardar(c Contacto) error {
+	return s.modificar(func(contactos map[string]Contacto) error {
+		c, err := c.normalizar(s.pais, contactos[c.Nombre].Telefonos)
+		if err = errors.Join(err, c.Validar()); err != nil {
+			return err
+		}
+		contactos[c.Nombre] = c
+		return nil
+	})
+}
+
+// Restaurar vuelve a guardar c tal cual, sin normalizar ni validar. Es
+// para deshacer: c es un contacto que ya estuvo en la agenda, aunque
+// tenga números antiguos que Guardar rechazaría.
+func (s *Store) Restaurar(c Contacto) error {
+	return s.modificar(func(contactos map[string]Contacto) error {
+		contactos[c.Nombre] = c.clonar()
+		return nil
+	})
+}
+
+// Reponer vuelve a añadir c, borrado antes, como Restaurar pero sin pisar
+// a otro contacto que se haya creado después con el mismo nombre.
+func (s *Store) Reponer(c Contacto) error {
+	return s.modificar(func(contactos map[string]Contacto) error {
+		if _, ok := contactos[c.Nombre]; ok {
+			return ErrYaExiste
+		}
+		contactos[c.Nombre] = c.clonar()
+		return nil
+	})
+}
+
+// Borrar quita el contacto nombre.
+func (s *Store) Borrar(nombre string) error {
+	return s.modificar(func(c map[string]Contacto) error {
+		if _, ok := c[nombre]; !ok {
+			return ErrNoExiste
+		}
+		delete(c, nombre)
+		return nil
+	})
+}
+
+// Renombrar cambia el nombre de un contacto conservando el resto de sus
+// datos. No pisa a otro contacto que ya se llame nuevo.
+func (s *Store) Renombrar(viejo, nuevo string) error {
+	if strings.TrimSpace(nuevo) == "" {
+		return errors.New("el nombre no puede estar vacío")
+	}
+	return s.modificar(func(c map[string]Contacto) error {
+		contacto, ok := c[viejo]
+		if !ok {
+			return ErrNoExiste
+		}
+		if _, ok := c[nuevo]; ok && nuevo != viejo {
+			return ErrYaExiste
+		}
+		delete(c, viejo)
+		contacto.Nombre = nuevo
+		c[nuevo] = contacto
+		return nil
+	})
+}
+
+// modificar aplica cambio con el fichero bloqueado: relee el fichero (por
+// si otra terminal lo cambió), aplica el cambio y lo escribe entero. Si
+// cambio devuelve un error no se escribe nada.
+func (s *Store) modificar(cambio func(map[string]Contacto) error) error {
+	liberar, err := bloquear(s.ruta + ".lock")
+	if err != nil {
+		return err
+	}
+	defer liberar()
+
+	if err := s.cargar(); err != nil {
+		return err
+	}
+	if err := cambio(s.contactos); err != nil {
+		return err
+	}
+	if err := s.escribir(); err != nil {
+		// La agenda en memoria vuelve a ser la del disco. Si ni eso se
+		// puede, se avisa: en memoria queda el cambio que no se guardó.
+		if errCargar := s.cargar(); errCargar != nil {
+			return errors.Join(err, fmt.Errorf("releyendo %s: %w", s.ruta, errCargar))
+		}
+		return err
+	}
+	return nil
+}
+
+// cargar lee el fichero; si no existe, la agenda queda vacía. Acepta
+// también el formato de la versión 1, un objeto con solo strings (nombre →
+// teléfono): los demás tienen un número y un array, así que no se
+// confunden. Los teléfonos de versiones anteriores a la 3 se normalizan;
+// los que no son válidos se dejan como estaban.
+func (s *Store) cargar() error {
+	data, err := os.ReadFile(s.ruta)
+	if errors.Is(err, os.ErrNotExist) {
+		s.contactos, s.version = make(map[string]Contacto), 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var viejo map[string]string
+	if json.Unmarshal(data, &viejo) == nil {
+		s.contactos, s.version = desdeMapa(viejo), 1
+		s.normalizarTodos()
+		return nil
+	}
+	var f fichero
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%s: %w", s.ruta, err)
+	}
+	if f.Version > versionFichero {
+		return fmt.Errorf("%s: versión %d del formato no soportada (como mucho %d)", s.ruta, f.Version, versionFichero)
+	}
+	contactos := make(map[string]Contacto, len(f.Contactos))
+	for _, c := range f.Contactos {
+		contactos[c.Nombre] = c
+	}
+	s.contactos, s.version = contactos, f.Version
+	if f.Version < 3 {
+		s.normalizarTodos()
+	}
+	return nil
+}
+
+// normalizarTodos pasa a E.164 los teléfonos que se puedan.
+func (s *Store) normalizarTodos() {
+	for nombre, c := range s.contactos {
+		for i, t := range c.Telefonos {
+			if n, err := telefono.Normalizar(t.Numero, s.pais); err == nil {
+				c.Telefonos[i].Numero = n
+			}
+		}
+		s.contactos[nombre] = c
+	}
+}
+
+// escribir guarda los contactos de forma atómica: escribe un temporal en
+// el mismo directorio y lo renombra encima del fichero, así que quien lo
+// lea ve la versión anterior o la nueva, nunca una a medias.
+func (s *Store) escribir() error {
+	f := fichero{Version: versionFichero, Contactos: make([]Contacto, 0, len(s.contactos))}
+	for _, nombre := range s.Nombres() {
+		f.Contactos = append(f.Contactos, s.contactos[nombre])
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.ruta), ".contactos-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no hace nada si el rename fue bien
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.ruta)
+}
+
+// bloquear crea el fichero de bloqueo ruta, esperando si ya existe. Crear
+// con O_EXCL es atómico: si dos procesos lo intentan a la vez, solo uno
+// gana. Devuelve la función que lo libera.
+//
+// El fichero contiene el PID (para saber quién lo tiene) y un testigo
+// aleatorio que distingue cada bloqueo, también los de un mismo proceso.
+// Liberar solo borra el fichero si sigue conteniendo el testigo propio:
+// si otro lo dio por caducado y lo sustituyó por uno suyo, no se le quita.
+func bloquear(ruta string) (func(), error) {
+	testigo := make([]byte, 8)
+	if _, err := rand.Read(testigo); err != nil {
+		return nil, err
+	}
+	contenido := fmt.Sprintf("%d %x\n", os.Getpid(), testigo)
+
+	limite := time.Now().Add(esperaBloqueo)
+	for {
+		f, err := os.OpenFile(ruta, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, err = f.WriteString(contenido)
+			if errClose := f.Close(); err == nil {
+				err = errClose
+			}
+			if err != nil {
+				os.Remove(ruta)
+				return nil, err
+			}
+			return func() {
+				if data, err := os.ReadFile(ruta); err == nil && string(data) == contenido {
+					os.Remove(ruta)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		// Un bloqueo caducado se aparta y se vuelve a intentar.
+		if info, err := os.Stat(ruta); err == nil && time.Since(info.ModTime()) > bloqueoCaducado {
+			apartarCaducado(ruta, fmt.Sprintf("%x", testigo))
+			continue
+		}
+		if time.Now().After(limite) {
+			data, _ := os.ReadFile(ruta)
+			pid, _, _ := strings.Cut(string(bytes.TrimSpace(data)), " ")
+			return nil, fmt.Errorf("%w (%s, pid %s)", ErrBloqueado, ruta, pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// apartarCaducado quita de en medio el bloqueo caducado ruta. No lo borra
+// directamente: entre comprobar que está caducado y borrarlo, otro proceso
+// puede haber hecho lo mismo y creado ya el suyo, y se borraría ese. Lo
+// renombra a un nombre propio (el rename es atómico: si varios lo
+// intentan, solo uno se lo lleva) y mira lo que se ha llevado: si resulta
+// que no estaba caducado, lo devuelve con un enlace, que nunca pisa un
+// bloqueo que ya exista.
+func apartarCaducado(ruta, testigo string) {
+	apartado := ruta + ".caducado-" + testigo
+	if os.Rename(ruta, apartado) != nil {
+		return // otro se adelantó
+	}
+	if info, err := os.Stat(apartado); err == nil && time.Since(info.ModTime()) <= bloqueoCaducado {
+		os.Link(apartado, ruta)
+	}
+	os.Remove(apartado)
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// movil devuelve un contacto con solo un móvil.
+func movil(nombre, numero string) Contacto {
+	return Contacto{Nombre: nombre, Telefonos: []Telefono{{Tipo: TipoMovil, Numero: numero}}}
+}
+
+// primerNumero devuelve el primer teléfono de c, o "".
+func primerNumero(c Contacto) string {
+	if len(c.Telefonos) == 0 {
+		return ""
+	}
+	return c.Telefonos[0].Numero
+}
+
+func TestGuardarYAbrir(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "sub", "contactos.json")
+	s, err := Abrir(ruta, "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 0 {
+		t.Fatalf("Len() = %d; esperado 0", s.Len())
+	}
+	for _, c := range [][2]string{{"Luis", "600111222"}, {"Ana", "600333444"}, {"Luis", "600555666"}} {
+		if err := s.Guardar(movil(c[0], c[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Al volver a abrir están los mismos contactos.
+	s, err = Abrir(ruta, "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Nombres(); !slices.Equal(got, []string{"Ana", "Luis"}) {
+		t.Errorf("Nombres() = %v; esperado [Ana Luis]", got)
+	}
+	if c, ok := s.Buscar("Luis"); !ok || primerNumero(c) != "+34600555666" {
+		t.Errorf("Buscar(Luis) = %+v, %v; esperado +34600555666", c, ok)
+	}
+
+	// No quedan temporales ni el bloqueo.
+	entradas, _ := os.ReadDir(filepath.Dir(ruta))
+	if len(entradas) != 1 {
+		t.Errorf("ficheros en el directorio = %v; esperado solo contactos.json", entradas)
+	}
+}
+
+func TestAbrirFicheroDañado(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	if err := os.WriteFile(ruta, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Abrir(ruta, "ES"); err == nil {
+		t.Error("Abrir con JSON inválido: err = nil; esperado error")
+	}
+}
+
+// TestDosTerminales simula dos procesos con la agenda abierta a la vez:
+// ninguno pierde los cambios del otro.
+func TestDosTerminales(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	a, _ := Abrir(ruta, "ES")
+	b, _ := Abrir(ruta, "ES")
+
+	var wg sync.WaitGroup
+	for i, s := range []*Store{a, b} {
+		wg.Go(func() {
+			for j := range 20 {
+				nombre := string(rune('A'+i)) + string(rune('a'+j))
+				if err := s.Guardar(movil(nombre, "600000000")); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	s, err := Abrir(ruta, "ES")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 40 {
+		t.Errorf("Len() = %d; esperado 40", s.Len())
+	}
+}
+
+func TestBloqueo(t *testing.T) {
+	saved := esperaBloqueo
+	esperaBloqueo = 100 * time.Millisecond
+	t.Cleanup(func() { esperaBloqueo = saved })
+
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	s, _ := Abrir(ruta, "ES")
+
+	// Bloqueo de otro proceso que sigue vivo: se espera y se falla.
+	if err := os.WriteFile(ruta+".lock", []byte("12345\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Guardar(movil("Ana", "600111222")); !errors.Is(err, ErrBloqueado) {
+		t.Errorf("Guardar con bloqueo: err = %v; esperado ErrBloqueado", err)
+	}
+	if _, ok := s.Buscar("Ana"); ok {
+		t.Error("el contacto se guardó aunque el fichero estaba bloqueado")
+	}
+
+	// Bloqueo caducado: se aparta y se sigue, sin dejar nada detrás.
+	viejo := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(ruta+".lock", viejo, viejo); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Guardar(movil("Ana", "600111222")); err != nil {
+		t.Errorf("Guardar con bloqueo caducado: %v", err)
+	}
+	if restos, _ := filepath.Glob(ruta + ".lock*"); len(restos) != 0 {
+		t.Errorf("quedan ficheros de bloqueo: %v", restos)
+	}
+}
+
+func TestBloqueoAjeno(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json.lock")
+
+	// Otro proceso da el bloqueo por caducado y pone el suyo: al liberar
+	// no se le quita.
+	liberar, err := bloquear(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ruta, []byte("12345 otro\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	liberar()
+	if data, err := os.ReadFile(ruta); err != nil || string(data) != "12345 otro\n" {
+		t.Errorf("liberar borró un bloqueo ajeno: %q, %v", data, err)
+	}
+	os.Remove(ruta)
+
+	// Dos bloqueos del mismo proceso no se confunden.
+	liberar1, err := bloquear(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(ruta)
+	liberar2, err := bloquear(ruta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	liberar1()
+	if _, err := os.Stat(ruta); err != nil {
+		t.Errorf("el primer liberar quitó el segundo bloqueo: %v", err)
+	}
+	liberar2()
+	if _, err := os.Stat(ruta); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("el bloqueo sigue tras liberarlo: %v", err)
+	}
+}
+
+func TestApartarCaducado(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json.lock")
+
+	// Entre el Stat y el rename, otro proceso ya puso un bloqueo nuevo: se
+	// devuelve a su sitio intacto.
+	if err := os.WriteFile(ruta, []byte("12345 nuevo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	apartarCaducado(ruta, "abc")
+	if data, err := os.ReadFile(ruta); err != nil || string(data) != "12345 nuevo\n" {
+		t.Errorf("se perdió el bloqueo reciente: %q, %v", data, err)
+	}
+
+	// Uno caducado desaparece.
+	viejo := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(ruta, viejo, viejo); err != nil {
+		t.Fatal(err)
+	}
+	apartarCaducado(ruta, "abc")
+	if restos, _ := filepath.Glob(ruta + "*"); len(restos) != 0 {
+		t.Errorf("quedan ficheros: %v", restos)
+	}
+}
+
+func TestModificarSinReleer(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	s, _ := Abrir(ruta, "ES")
+	s.Guardar(movil("Ana", "600111222"))
+
+	// Durante el cambio el fichero se convierte en un directorio: no se
+	// puede escribir ni volver a leer, y el error dice las dos cosas.
+	err := s.modificar(func(c map[string]Contacto) error {
+		c["Luis"] = movil("Luis", "+34600333444")
+		if err := os.Remove(ruta); err != nil {
+			return err
+		}
+		return os.Mkdir(ruta, 0o700)
+	})
+	if err == nil || !strings.Contains(err.Error(), "releyendo") {
+		t.Errorf("err = %v; esperado el error de escribir y el de releer", err)
+	}
+}
+
+func TestBorrarYRenombrar(t *testing.T) {
+	ruta := filepath.Join(t.TempDir(), "contactos.json")
+	s, _ := Abrir(ruta, "ES")
+	s.Guardar(movil("Ana", "600111222"))
+	s.Guardar(movil("Luis", "600333444"))
+
+	tests := []struct {
+		nombre  string
+		op      func() error
+		wantErr error
+		want    map[string]string
+	}{
+		{"renombrar", func() error { return s.Renombrar("Ana", "Ana María") }, nil,
+			map[string]string{"Ana María": "+34600111222", "Luis": "+34600333444"}},
+		{"renombrar a un nombre ocupado", func() error { return s.Renombrar("Luis", "Ana María") }, ErrYaExiste,
+			map[string]string{"Ana María": "+34600111222", "Luis": "+34600333444"}},
+		{"renombrar inexistente", func() error { return s.Renombrar("Pepe", "José") }, ErrNoExiste,
+			map[string]string{"Ana María": "+34600111222", "Luis": "+34600333444"}},
+		{"borrar", func() error { return s.Borrar("Luis") }, nil,
+			map[string]string{"Ana María": "+34600111222"}},
+		{"borrar inexistente", func() error { return s.Borrar("Luis") }, ErrNoExiste,
+			map[string]string{"Ana María": "+34600111222"}},
+		{"reponer", func() error { return s.Reponer(movil("Luis", "+34600333444")) }, nil,
+			map[string]string{"Ana María": "+34600111222", "Luis": "+34600333444"}},
+		{"reponer un nombre ocupado", func() error { return s.Reponer(movil("Luis", "+34600999999")) }, ErrYaExiste,
+			map[string]string{"Ana María": "+34600111222", "Luis": "+34600333444"}},
+	}
+	for _, tt := range tests {
+		if err := tt.op(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v; esperado %v", tt.nombre, err, tt.wantErr)
+		}
+		// Lo que hay en disco coincide con lo esperado.
+		d, err := Abrir(ruta, "ES")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Len() != len(tt.want) {
+			t.Errorf("%s: %v; esperado %v", tt.nombre, d.Nombres(), tt.want)
+		}
+		for nombre, tel := range tt.want {
+			if got, ok := d.Buscar(nombre); !ok || got.Nombre != nombre || primerNumero(got) != tel {
+				t.Errorf("%s: Buscar(%q) = %+v, %v; esperado %q", tt.nombre, nombre, got, ok, tel)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package telefono normaliza números de teléfono al formato E.164
+// (+34600111222): el que espera Twilio y el que se guarda en la agenda.
+package telefono
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Pais son las reglas de numeración de un país.
+type Pais struct {
+	Codigo     string   // código ISO 3166-1 ("ES")
+	Nombre     string   // para los mensajes
+	Prefijo    string   // prefijo internacional, sin "+" ("34")
+	Troncal    string   // prefijo nacional que se quita al pasar a E.164 ("0" en Francia; "" si no hay)
+	Longitudes []int    // longitudes válidas del número nacional, sin prefijos
+	Inicios    []string // por qué dígitos puede empezar el número nacional
+}
+
+// Paises son los países soportados, por código ISO. Las reglas son las
+// generales de cada plan de numeración, no una lista exhaustiva de rangos.
+var Paises = map[string]Pais{
+	"ES": {"ES", "España", "34", "", []int{9}, []string{"6", "7", "8", "9"}},
+	"PT": {"PT", "Portugal", "351", "", []int{9}, []string{"2", "9"}},
+	"FR": {"FR", "Francia", "33", "0", []int{9}, []string{"1", "2", "3", "4", "5", "6", "7", "9"}},
+	"DE": {"DE", "Alemania", "49", "0", []int{7, 8, 9, 10, 11}, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+	"IT": {"IT", "Italia", "39", "", []int{6, 7, 8, 9, 10, 11}, []string{"0", "3"}},
+	"GB": {"GB", "Reino Unido", "44", "0", []int{9, 10}, []string{"1", "2", "3", "7", "8", "9"}},
+	"US": {"US", "EE. UU. y Canadá", "1", "1", []int{10}, []string{"2", "3", "4", "5", "6", "7", "8", "9"}},
+	"MX": {"MX", "México", "52", "", []int{10}, []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+}
+
+// empiezaBien dice si el número nacional n empieza por uno de los
+// Inicios del país.
+func (p Pais) empiezaBien(n string) bool {
+	return slices.ContainsFunc(p.Inicios, func(i string) bool { return strings.HasPrefix(n, i) })
+}
+
+// ErrInvalido es el error base de los números que no se pueden normalizar;
+// el mensaje concreto dice por qué.
+var ErrInvalido = errors.New("teléfono inválido")
+
+// BuscarPais devuelve las reglas del país con código ISO codigo (sin
+// distinguir mayúsculas).
+func BuscarPais(codigo string) (Pais, bool) {
+	p, ok := Paises[strings.ToUpper(codigo)]
+	return p, ok
+}
+
+// Codigos devuelve los códigos de los países soportados, ordenados.
+func Codigos() []string {
+	return slices.Sorted(maps.Keys(Paises))
+}
+
+// Normalizar pasa entrada a E.164. Acepta números internacionales (con
+// "+" o "00" delante) y nacionales, que se interpretan como del país
+// porDefecto. Ignora espacios, guiones, puntos y paréntesis.
+//
+//	Normalizar("600 11 12 22", "ES")      → "+34600111222"
+//	Normalizar("0033 6 12 34 56 78", "ES") → "+33612345678"
+//	Normalizar("020 7946 0018", "GB")     → "+442079460018"
+func Normalizar(entrada, porDefecto string) (string, error) {
+	digitos, internacional, err := limpiar(entrada)
+	if err != nil {
+		return "", err
+	}
+
+	var p Pais
+	if internacional {
+		var ok bool
+		if p, ok = paisDePrefijo(digitos); !ok {
+			return "", fmt.Errorf("%w: %q: prefijo internacional no soportado (países: %s)", ErrInvalido, entrada, strings.Join(Codigos(), ", "))
+		}
+		digitos = digitos[len(p.Prefijo):]
+	} else {
+		var ok bool
+		if p, ok = BuscarPais(porDefecto); !ok {
+			return "", fmt.Errorf("%w: %q: sin prefijo internacional y sin país por defecto", ErrInvalido, entrada)
+		}
+	}
+
+	// Quitar el prefijo troncal ("0" en "020 7946 0018"). Ningún número
+	// nacional empieza por él, así que no se confunde con una cifra del
+	// número. Se quita también tras el internacional, como en "+44 (0)20...".
+	if p.Troncal != "" && strings.HasPrefix(digitos, p.Troncal) && !p.empiezaBien(digitos) {
+		digitos = digitos[len(p.Troncal):]
+	}
+
+	if !slices.Contains(p.Longitudes, len(digitos)) {
+		return "", fmt.Errorf("%w: %q: un número de %s tiene %s cifras, sin el +%s", ErrInvalido, entrada, p.Nombre, longitudes(p.Longitudes), p.Prefijo)
+	}
+	if !p.empiezaBien(digitos) {
+		return "", fmt.Errorf("%w: %q: en %s los números empiezan por %s", ErrInvalido, entrada, p.Nombre, strings.Join(p.Inicios, ", "))
+	}
+	return "+" + p.Prefijo + digitos, nil
+}
+
+// EsE164 dice si s ya está normalizado, es decir, si Normalizar lo deja
+// igual.
+func EsE164(s string) bool {
+	n, err := Normalizar(s, "")
+	return err == nil && n == s
+}
+
+// limpiar quita los separadores de entrada y el "+" o "00" inicial, y dice
+// si el número era internacional.
+func limpiar(entrada string) (digitos string, internacional bool, err error) {
+	s := strings.TrimSpace(entrada)
+	if s == "" {
+		return "", false, fmt.Errorf("%w: vacío", ErrInvalido)
+	}
+	if rest, ok := strings.CutPrefix(s, "+"); ok {
+		s, internacional = rest, true
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune(" -.()/", r):
+		default:
+			return "", false, fmt.Errorf("%w: %q: el carácter %q no es válido", ErrInvalido, entrada, r)
+		}
+	}
+	digitos = b.String()
+	if !internacional {
+		if rest, ok := strings.CutPrefix(digitos, "00"); ok {
+			digitos, internacional = rest, true
+		}
+	}
+	if digitos == "" {
+		return "", false, fmt.Errorf("%w: %q no tiene cifras", ErrInvalido, entrada)
+	}
+	return digitos, internacional, nil
+}
+
+// paisDePrefijo busca el país cuyo prefijo internacional empieza digitos.
+// Los prefijos no se solapan (E.164 es un código prefijo), así que hay
+// como mucho uno.
+func paisDePrefijo(digitos string) (Pais, bool) {
+	for _, p := range Paises {
+		if strings.HasPrefix(digitos, p.Prefijo) {
+			return p, true
+		}
+	}
+	return Pais{}, false
+}
+
+// longitudes describe una lista de longitudes: "9" o "de 7 a 11".
+func longitudes(l []int) string {
+	if len(l) == 1 {
+		return fmt.Sprint(l[0])
+	}
+	if l[len(l)-1]-l[0] == len(l)-1 {
+		return fmt.Sprintf("de %d a %d", l[0], l[len(l)-1])
+	}
+	s := make([]string, len(l))
+	for i, n := range l {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, " o ")
+}
@@ -0,0 +1,94 @@
+package telefono
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizar(t *testing.T) {
+	tests := []struct {
+		entrada, pais string
+		esperado      string // "" = inválido
+	}{
+		// Nacionales con el país por defecto.
+		{"600111222", "ES", "+34600111222"},
+		{"600 11 12 22", "ES", "+34600111222"},
+		{"600-111-222", "es", "+34600111222"},
+		{"(91) 555.55.55", "ES", "+34915555555"},
+		{"06 12 34 56 78", "FR", "+33612345678"},
+		{"020 7946 0018", "GB", "+442079460018"},
+		{"030 1234567", "DE", "+49301234567"},
+		{"06 6982 1234", "IT", "+390669821234"}, // en Italia el 0 se conserva
+		{"(415) 555-0100", "US", "+14155550100"},
+		{"1 415 555 0100", "US", "+14155550100"},
+
+		// Internacionales: el país por defecto no importa.
+		{"+34 600 111 222", "FR", "+34600111222"},
+		{"0034600111222", "ES", "+34600111222"},
+		{"+33 6 12 34 56 78", "ES", "+33612345678"},
+		{"+44 (0)20 7946 0018", "ES", "+442079460018"},
+		{"+351 912 345 678", "ES", "+351912345678"},
+		{"+52 55 1234 5678", "ES", "+525512345678"},
+		{"+34600111222", "", "+34600111222"},
+
+		// Inválidos.
+		{"", "ES", ""},
+		{"  ", "ES", ""},
+		{"abc", "ES", ""},
+		{"600 111 22x", "ES", ""},
+		{"60011122", "ES", ""},   // corto
+		{"6001112223", "ES", ""}, // largo
+		{"500111222", "ES", ""},  // en España no empiezan por 5
+		{"+", "ES", ""},
+		{"+999 123456", "ES", ""}, // prefijo no soportado
+		{"600111222", "", ""},     // nacional sin país
+		{"600111222", "XX", ""},
+		{"+1 015 555 0100", "ES", ""},
+	}
+	for _, tt := range tests {
+		got, err := Normalizar(tt.entrada, tt.pais)
+		if tt.esperado == "" {
+			if err == nil || !errors.Is(err, ErrInvalido) {
+				t.Errorf("Normalizar(%q, %q) = %q, %v; esperado ErrInvalido", tt.entrada, tt.pais, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.esperado {
+			t.Errorf("Normalizar(%q, %q) = %q, %v; esperado %q", tt.entrada, tt.pais, got, err, tt.esperado)
+		}
+	}
+}
+
+func TestEsE164(t *testing.T) {
+	tests := []struct {
+		entrada  string
+		esperado bool
+	}{
+		{"+34600111222", true},
+		{"+442079460018", true},
+		{"+34 600 111 222", false},
+		{"600111222", false},
+		{"+4402079460018", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := EsE164(tt.entrada); got != tt.esperado {
+			t.Errorf("EsE164(%q) = %v; esperado %v", tt.entrada, got, tt.esperado)
+		}
+	}
+}
+
+// TestPrefijosSinSolapes comprueba que ningún prefijo de la tabla empiece
+// por otro: si no, un número internacional podría ser de dos países.
+func TestPrefijosSinSolapes(t *testing.T) {
+	for _, a := range Paises {
+		for _, b := range Paises {
+			if a.Codigo != b.Codigo && len(a.Prefijo) <= len(b.Prefijo) && b.Prefijo[:len(a.Prefijo)] == a.Prefijo {
+				t.Errorf("el prefijo +%s (%s) empieza por +%s (%s)", b.Prefijo, b.Codigo, a.Prefijo, a.Codigo)
+			}
+		}
+		if p, ok := BuscarPais(a.Codigo); !ok || p.Prefijo != a.Prefijo {
+			t.Errorf("Paises[%q] tiene el código %q", a.Codigo, p.Codigo)
+		}
+	}
+}
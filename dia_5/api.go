@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 )
 
@@ -19,27 +23,73 @@ type Task struct {
 	Title     string    `json:"title"`
 	Done      bool      `json:"done"`
 	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt es la hora del último cambio; cero si no ha cambiado desde
+	// que se creó. La pone el almacén (ver touchLocked).
+	UpdatedAt time.Time  `json:"updated_at,omitzero"`
+	DueDate   *time.Time `json:"due_date,omitempty"` // opcional
+	Owner     string     `json:"owner,omitempty"`    // usuario que la creó ("" = anónimo)
+	// Attachments son los ficheros adjuntos (ver attachments.go).
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// ProjectID, Column y Position la sitúan en un tablero (ver projects.go).
+	ProjectID int    `json:"project_id,omitempty"`
+	Column    string `json:"column,omitempty"`
+	Position  int    `json:"position,omitempty"` // 1 = primera de la columna
 }
 
-// Variables globales para almacenar las tareas en memoria.
-// Se usa un slice y un contador de IDs.
-// sync.Mutex asegura concurrencia segura cuando hay múltiples requests.
-var (
-	mu     sync.Mutex
-	tasks  = make([]Task, 0)
-	nextID = 1
-)
+// server agrupa los handlers HTTP y el almacén sobre el que trabajan.
+type server struct {
+	store    *taskStore
+	blobs    *blobStore // contenido de los adjuntos; nil los desactiva
+	follower *follower  // nil si la instancia es líder
+}
+
+// newServer crea un servidor sobre st.
+func newServer(st *taskStore) *server {
+	return &server{store: st}
+}
+
+// routes crea el router con todos los handlers. En un seguidor las
+// escrituras se redirigen al líder.
+func (srv *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", pingHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.HandleFunc("/tasks", srv.tasksHandler)
+	mux.HandleFunc("/tasks/", srv.taskByIDHandler)
+	mux.HandleFunc("/tasks/calendar.ics", srv.calendarHandler)
+	mux.HandleFunc("/tasks/changes", srv.changesHandler)
+	mux.HandleFunc("/search", srv.searchHandler)
+	mux.HandleFunc("/rpc", srv.rpcHandler)
+	mux.HandleFunc("/projects", srv.projectsHandler)
+	mux.HandleFunc("/projects/", srv.projectByIDHandler)
+	mux.HandleFunc("/whoami", whoamiHandler)
+	mux.HandleFunc("/", srv.uiHandler)
+	mux.HandleFunc("/ui/tasks", srv.uiCreateHandler)
+	mux.HandleFunc("/ui/tasks/", srv.uiTaskActionHandler)
+	mux.Handle("/ui/static/", uiStaticHandler())
+	mux.HandleFunc("/replication/snapshot", srv.replicationSnapshotHandler)
+	mux.HandleFunc("/replication/stream", srv.replicationStreamHandler)
+	mux.HandleFunc("/replication/status", srv.replicationStatusHandler)
+	mux.HandleFunc("/replication/blobs/", srv.replicationBlobHandler)
+	mux.HandleFunc("/admin/backup", srv.backupHandler)
+	mux.HandleFunc("/admin/restore", srv.restoreHandler)
+	if srv.follower != nil {
+		return withReadOnly(srv.follower.leader, mux)
+	}
+	return mux
+}
 
 // writeJSON serializa la respuesta en JSON y la envía con el status indicado.
 func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(v)
+	writeJSONAs(w, "application/json; charset=utf-8", status, v)
 }
 
-// writeError devuelve un error en formato JSON.
-func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]any{"error": msg})
+// writeJSONAs es como writeJSON pero con un Content-Type concreto.
+func writeJSONAs(w http.ResponseWriter, contentType string, status int, v any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
 }
 
 // pingHandler responde "pong" → sirve como chequeo rápido del servidor.
@@ -51,129 +101,336 @@ func pingHandler(w http.ResponseWriter, r *http.Request) {
 // tasksHandler maneja /tasks.
 // - GET: lista todas las tareas.
 // - POST: crea una nueva tarea.
-func tasksHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *server) tasksHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		listTasks(w, r)
+		srv.listTasks(w, r)
 	case http.MethodPost:
-		createTask(w, r)
+		srv.createTask(w, r)
 	default:
-		w.Header().Set("Allow", "GET, POST")
-		writeError(w, http.StatusMethodNotAllowed, "método no permitido")
+		writeMethodNotAllowed(w, r, "GET, POST")
 	}
 }
 
 // taskByIDHandler maneja /tasks/{id}.
 // - GET: devuelve una tarea específica según su ID.
-func taskByIDHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		w.Header().Set("Allow", "GET")
-		writeError(w, http.StatusMethodNotAllowed, "método no permitido")
-		return
-	}
-
+// Las rutas /tasks/{id}/attachments... las atiende attachmentsHandler y
+// POST /tasks/{id}/move, moveTaskHandler.
+func (srv *server) taskByIDHandler(w http.ResponseWriter, r *http.Request) {
 	// Extraer el ID desde la URL.
 	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/")
 	if len(parts) < 1 || parts[0] == "" {
-		writeError(w, http.StatusBadRequest, "ID requerido")
+		writeError(w, r, http.StatusBadRequest, codeIDRequired)
 		return
 	}
 	id, err := strconv.Atoi(parts[0])
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "ID inválido")
+		writeError(w, r, http.StatusBadRequest, codeInvalidID)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "attachments" {
+		srv.attachmentsHandler(w, r, id, parts[2:])
+		return
+	}
+	if len(parts) == 2 && parts[1] == "move" {
+		srv.moveTaskHandler(w, r, id)
+		return
+	}
+	if len(parts) > 1 {
+		// /tasks/1/lo-que-sea no es la tarea 1.
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
 		return
 	}
 
-	// Buscar la tarea con ese ID en el slice protegido por mutex.
-	mu.Lock()
-	defer mu.Unlock()
-	for _, t := range tasks {
-		if t.ID == id {
-			writeJSON(w, http.StatusOK, t)
-			return
-		}
+	// Buscar la tarea con ese ID en el almacén protegido por mutex; la
+	// respuesta se escribe ya sin el lock.
+	srv.store.mu.RLock()
+	t, ok := srv.store.getLocked(id)
+	srv.store.mu.RUnlock()
+	if ok {
+		writeJSON(w, http.StatusOK, t)
+		return
 	}
 
 	// Si no existe, devolver 404.
-	writeError(w, http.StatusNotFound, fmt.Sprintf("tarea con id %d no encontrada", id))
+	writeError(w, r, http.StatusNotFound, codeTaskNotFound, id)
 }
 
 // listTasks devuelve todas las tareas como un slice en JSON.
-func listTasks(w http.ResponseWriter, _ *http.Request) {
-	mu.Lock()
-	defer mu.Unlock()
+func (srv *server) listTasks(w http.ResponseWriter, _ *http.Request) {
+	// Copiar con el lock de lectura y codificar sin él, para no bloquear
+	// las escrituras mientras se genera el JSON.
+	srv.store.mu.RLock()
+	tasks, rev := srv.store.listLocked(), srv.store.revision
+	srv.store.mu.RUnlock()
+	// La revisión permite seguir los cambios con /tasks/changes?since=N.
+	w.Header().Set("X-Tasks-Revision", strconv.FormatInt(rev, 10))
 	writeJSON(w, http.StatusOK, tasks)
 }
 
 // taskInput define el payload para crear/actualizar una tarea.
 type taskInput struct {
-	Title string `json:"title"`
-	Done  *bool  `json:"done,omitempty"` // opcional al crear
+	Title   string     `json:"title"`
+	Done    *bool      `json:"done,omitempty"`     // opcional al crear
+	DueDate *time.Time `json:"due_date,omitempty"` // opcional, RFC 3339
+	// ProjectID y Column colocan la tarea al final de una columna de un
+	// proyecto (por defecto la primera). Solo al crear.
+	ProjectID *int   `json:"project_id,omitempty"`
+	Column    string `json:"column,omitempty"`
 }
 
+// maxTitleLen es la longitud máxima (en bytes) del título de una tarea.
+// Se configura con la opción max_title_len.
+var maxTitleLen = 200
+
 // validateTitle asegura que el título no esté vacío ni sea demasiado largo.
 func validateTitle(title string) error {
 	if strings.TrimSpace(title) == "" {
-		return errors.New("title no puede estar vacío")
+		return newFieldError("title", codeTitleEmpty)
 	}
-	if len(title) > 200 {
-		return errors.New("title demasiado largo (máx 200)")
+	if len(title) > maxTitleLen {
+		return newFieldError("title", codeTitleTooLong, maxTitleLen)
 	}
 	return nil
 }
 
-// createTask crea una nueva tarea y la agrega al slice global.
-func createTask(w http.ResponseWriter, r *http.Request) {
+// createTask crea una nueva tarea y la agrega al almacén.
+func (srv *server) createTask(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	var in taskInput
 
-	// Deserializar el JSON recibido.
-	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		writeError(w, http.StatusBadRequest, "JSON inválido")
+	// Deserializar el JSON recibido (con límite de tamaño y sin campos
+	// desconocidos).
+	if !decodeJSON(w, r, &in) {
 		return
 	}
-	if err := validateTitle(in.Title); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	newTask, err := srv.store.addTask(r.Context(), in, userFromRequest(r))
+	if err != nil {
+		writeStoreError(w, r, err)
 		return
 	}
 
-	// Proteger acceso concurrente al slice de tareas.
-	mu.Lock()
-	id := nextID
-	nextID++
+	// Responder con 201 Created y la tarea recién creada.
+	w.Header().Set("Location", "/tasks/"+strconv.Itoa(newTask.ID))
+	writeJSON(w, http.StatusCreated, newTask)
+}
+
+// errQuotaExceeded indica que el usuario ya tiene el máximo de tareas.
+var errQuotaExceeded = errors.New("cuota de tareas superada")
+
+// addTask valida in y crea la tarea de owner. Lo usan POST /tasks y
+// tasks.create de /rpc, para que ambos validen igual.
+func (s *taskStore) addTask(ctx context.Context, in taskInput, owner string) (Task, error) {
+	if err := validateTitle(in.Title); err != nil {
+		return Task{}, err
+	}
+	if in.Column != "" && in.ProjectID == nil {
+		return Task{}, newFieldError("project_id", codeProjectRequired)
+	}
+
 	newTask := Task{
-		ID:        id,
 		Title:     in.Title,
 		Done:      false,
 		CreatedAt: time.Now().UTC(),
+		Owner:     owner,
+	}
+	if in.DueDate != nil {
+		due := in.DueDate.UTC()
+		newTask.DueDate = &due
 	}
-	tasks = append(tasks, newTask)
-	mu.Unlock()
 
-	// Responder con 201 Created y la tarea recién creada.
-	w.Header().Set("Location", "/tasks/"+strconv.Itoa(id))
-	writeJSON(w, http.StatusCreated, newTask)
+	// Proteger acceso concurrente al almacén.
+	s.lockFor(ctx)
+	defer s.unlock()
+	if s.overQuotaLocked(owner) {
+		return Task{}, errQuotaExceeded
+	}
+	if in.ProjectID != nil {
+		if err := s.placeNewLocked(&newTask, *in.ProjectID, in.Column); err != nil {
+			return Task{}, err
+		}
+	}
+	return s.createLocked(newTask), nil
+}
+
+// writeStoreError responde a un error de addTask o de las operaciones de
+// proyectos.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var fe *fieldError
+	var wip *wipLimitError
+	switch {
+	case errors.Is(err, errQuotaExceeded):
+		writeError(w, r, http.StatusForbidden, codeQuotaExceeded, maxTasksPerUser)
+	case errors.As(err, &wip):
+		writeError(w, r, http.StatusConflict, codeWIPLimitExceeded, wip.column, wip.limit)
+	case errors.Is(err, errProjectNotEmpty):
+		writeError(w, r, http.StatusConflict, codeProjectNotEmpty)
+	case errors.As(err, &fe):
+		writeValidationError(w, r, err)
+	default:
+		writeError(w, r, http.StatusInternalServerError, codeInternalError)
+	}
 }
 
 func main() {
+	// Subcomandos: "verify" comprueba el registro de auditoría y "load"
+	// lanza el generador de carga.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			os.Exit(runVerify(os.Args[2:], os.Stdout))
+		case "load":
+			os.Exit(runLoad(os.Args[2:], os.Stdout))
+		}
+	}
+	cfg, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("configuración inválida: %v", err)
+	}
+	if cfg.PrintConfig {
+		_ = printConfig(os.Stdout, cfg)
+		return
+	}
+	st := newTaskStore()
+	if err := applyConfig(cfg, st); err != nil {
+		log.Fatalf("error al cargar datos: %v", err)
+	}
+	registerCheck("storage", 0, st.checkStorage)
+
 	// Crear router y asignar handlers.
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ping", pingHandler)
-	mux.HandleFunc("/tasks", tasksHandler)
-	mux.HandleFunc("/tasks/", taskByIDHandler)
+	srv := newServer(st)
+	srv.blobs = newBlobStore(cfg.BlobDir)
+	go srv.runBlobGC(cfg.BlobGCInterval)
+	if cfg.ReplicateFrom != "" {
+		srv.follower = newFollower(cfg.ReplicateFrom, st)
+		go srv.follower.run(context.Background())
+		registerCheck("replication", 0, srv.follower.check)
+		slog.Info("réplica de solo lectura", "leader", cfg.ReplicateFrom)
+	} else {
+		// Solo el líder envía recordatorios, para no duplicarlos.
+		startReminders(cfg, st)
+	}
 
 	// Configuración del servidor HTTP.
-	addr := ":8080"
+	tlsCfg, err := newTLSConfig(cfg)
+	if err != nil {
+		log.Fatalf("error configurando TLS: %v", err)
+	}
+	var handler http.Handler = srv.routes()
+	if cfg.AuditFile != "" {
+		audit, err := openAuditLog(cfg.AuditFile, []byte(cfg.AuditKey))
+		if err != nil {
+			log.Fatalf("error abriendo el registro de auditoría: %v", err)
+		}
+		defer audit.close()
+		registerCheck("audit", 0, audit.check)
+		handler = srv.withAudit(audit, handler)
+	}
+	if cfg.Compress {
+		handler = withCompression(cfg.CompressMinSize, handler)
+	}
+	if cfg.CORSOrigins != "" {
+		handler = withCORS(cfg.corsOptions(), handler)
+	}
 	server := &http.Server{
-		Addr:         addr,
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         cfg.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		TLSConfig:    tlsCfg,
 	}
 
-	log.Printf("Servidor escuchando en http://localhost%v\n", addr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	go shutdownOnSignal(server, cfg.ShutdownDrain, cfg.ShutdownTimeout)
+
+	if tlsCfg != nil {
+		slog.Info("servidor escuchando", "url", "https://"+cfg.Addr)
+		err = server.ListenAndServeTLS("", "") // el certificado viene de TLSConfig
+	} else {
+		slog.Info("servidor escuchando", "url", "http://"+cfg.Addr)
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("error al iniciar servidor: %v", err)
 	}
+	<-shutdownDone
+}
+
+// shutdownDone se cierra cuando el apagado ordenado ha terminado.
+var shutdownDone = make(chan struct{})
+
+// shutdownOnSignal espera SIGINT/SIGTERM y apaga el servidor en orden:
+// primero /readyz pasa a 503 durante drain para que el balanceador deje
+// de enviar tráfico, y después se esperan las peticiones en curso.
+func shutdownOnSignal(server *http.Server, drain, timeout time.Duration) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+
+	slog.Info("apagando: drenando conexiones", "drain", drain)
+	draining.Store(true)
+	close(shuttingDown)
+	time.Sleep(drain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("error en el apagado", "err", err)
+	}
+	close(shutdownDone)
+}
+
+// startReminders arranca el planificador de recordatorios (y el webhook si
+// está configurado). Se detienen al empezar el apagado.
+func startReminders(cfg config, st *taskStore) {
+	offsets, _ := parseOffsets(cfg.ReminderOffsets) // ya validado
+	if len(offsets) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-shuttingDown
+		cancel()
+	}()
+
+	notifiers := multiNotifier{logNotifier{}}
+	if cfg.ReminderWebhook != "" {
+		wh := newWebhookNotifier(cfg.ReminderWebhook, 100)
+		go wh.run(ctx)
+		registerCheck("webhook", 0, wh.check)
+		notifiers = append(notifiers, wh)
+	}
+	sch := newScheduler(st, offsets, realClock{}, notifiers)
+	if cfg.StorageBackend == "file" {
+		sch.statePath = cfg.StoragePath + ".reminders"
+	}
+	go sch.run(ctx)
+}
+
+// applyConfig traslada la configuración a las variables globales del
+// servidor y carga los datos en st si el almacenamiento es "file".
+func applyConfig(cfg config, st *taskStore) error {
+	level, _ := parseLogLevel(cfg.LogLevel) // ya validado
+	slog.SetLogLoggerLevel(level)
+
+	maxTitleLen = cfg.MaxTitleLen
+	legacyErrors = cfg.LegacyErrors
+	calendarTokens = parseCalendarTokens(cfg.CalendarTokens)
+	adminToken = cfg.AdminToken
+	replicationToken = cfg.ReplicationToken
+	maxAttachmentSize = int64(cfg.MaxAttachmentSize)
+	maxBodyBytes = int64(cfg.MaxBodyBytes)
+	maxTasksPerUser = cfg.MaxTasksPerUser
+
+	if cfg.StorageBackend == "file" {
+		return st.load(cfg.StoragePath)
+	}
+	return nil
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateTaskBodyLimits(t *testing.T) {
+	saved := maxBodyBytes
+	maxBodyBytes = 64
+	t.Cleanup(func() { maxBodyBytes = saved })
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"válido", `{"title":"Aprender Go"}`, http.StatusCreated, ""},
+		{"espacios detrás", "{\"title\":\"Aprender Go\"}\n\n", http.StatusCreated, ""},
+		{"demasiado grande", `{"title":"` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, codeBodyTooLarge},
+		{"campo desconocido", `{"title":"a","priority":1}`, http.StatusBadRequest, codeInvalidJSON},
+		{"dos objetos", `{"title":"a"}{"title":"b"}`, http.StatusBadRequest, codeInvalidJSON},
+		{"basura detrás", `{"title":"a"} x`, http.StatusBadRequest, codeInvalidJSON},
+		{"array", `[{"title":"a"}]`, http.StatusBadRequest, codeInvalidJSON},
+		{"vacío", ``, http.StatusBadRequest, codeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(newTaskStore())
+			w := httptest.NewRecorder()
+			srv.tasksHandler(w, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(tt.body)))
+
+			var p problem
+			_ = json.NewDecoder(w.Body).Decode(&p)
+			if w.Code != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("got %d %q; want %d %q", w.Code, p.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateTaskQuota(t *testing.T) {
+	saved := maxTasksPerUser
+	maxTasksPerUser = 2
+	t.Cleanup(func() { maxTasksPerUser = saved })
+
+	srv := newServer(newTaskStore())
+	// Tareas de otro usuario no cuentan para el anónimo.
+	srv.store.mu.Lock()
+	srv.store.createLocked(Task{Title: "de alice", Owner: "alice"})
+	srv.store.createLocked(Task{Title: "de alice", Owner: "alice"})
+	srv.store.mu.Unlock()
+
+	wantStatus := []int{http.StatusCreated, http.StatusCreated, http.StatusForbidden}
+	for i, want := range wantStatus {
+		w := httptest.NewRecorder()
+		srv.tasksHandler(w, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"mía"}`)))
+		if w.Code != want {
+			t.Fatalf("petición %d: status = %d; want %d", i+1, w.Code, want)
+		}
+		if want == http.StatusForbidden {
+			var p problem
+			_ = json.NewDecoder(w.Body).Decode(&p)
+			if p.Code != codeQuotaExceeded {
+				t.Errorf("code = %q; want %q", p.Code, codeQuotaExceeded)
+			}
+		}
+	}
+
+	// Al borrar una tarea vuelve a haber hueco.
+	srv.store.mu.Lock()
+	srv.store.removeLocked(3)
+	srv.store.mu.Unlock()
+	w := httptest.NewRecorder()
+	srv.tasksHandler(w, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"otra"}`)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("tras borrar: status = %d; want 201", w.Code)
+	}
+}
+
+func TestOwnerCounts(t *testing.T) {
+	// La cuota usa un contador por usuario que se mantiene en altas,
+	// cambios, bajas y restauraciones; debe coincidir siempre con las
+	// tareas.
+	st := newTaskStore()
+	check := func(step string) {
+		t.Helper()
+		want := map[string]int{}
+		for _, t := range st.tasks {
+			want[t.Owner]++
+		}
+		if !maps.Equal(st.owners, want) {
+			t.Errorf("%s: owners = %v; want %v", step, st.owners, want)
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.createLocked(Task{Title: "a", Owner: "alice"})
+	st.createLocked(Task{Title: "b", Owner: "alice"})
+	st.createLocked(Task{Title: "c"})
+	check("altas")
+	st.updateLocked(Task{ID: 2, Title: "b", Owner: "bob"})
+	check("cambio de dueño")
+	st.removeLocked(1)
+	check("baja")
+	st.replaceLocked(snapshot{NextID: 5, Tasks: []Task{{ID: 3}, {ID: 4}, {ID: 5, Owner: "carol"}}})
+	check("restauración")
+	if st.owners[""] != 2 {
+		t.Errorf("anónimos = %d; want 2 (comparten cuota)", st.owners[""])
+	}
+}
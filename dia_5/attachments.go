@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Adjuntos de las tareas (capturas, logs...):
+//   - GET    /tasks/{id}/attachments        lista los adjuntos.
+//   - POST   /tasks/{id}/attachments        sube uno (multipart, campo "file").
+//   - GET    /tasks/{id}/attachments/{aid}  lo descarga (admite Range).
+//   - DELETE /tasks/{id}/attachments/{aid}  lo quita de la tarea.
+//
+// El contenido se guarda en el blobStore; la tarea solo guarda los datos.
+
+// maxAttachmentSize es el tamaño máximo de un adjunto en bytes. Se
+// configura con la opción max_attachment_size.
+var maxAttachmentSize int64 = 10 << 20
+
+// Attachment es un fichero adjunto a una tarea.
+type Attachment struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// attachmentsHandler maneja /tasks/{id}/attachments[/{aid}]; rest es lo
+// que va detrás de "attachments" en la ruta.
+func (srv *server) attachmentsHandler(w http.ResponseWriter, r *http.Request, id int, rest []string) {
+	if srv.blobs == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if len(rest) == 0 || rest[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			srv.listAttachments(w, r, id)
+		case http.MethodPost:
+			srv.uploadAttachment(w, r, id)
+		default:
+			writeMethodNotAllowed(w, r, "GET, POST")
+		}
+		return
+	}
+
+	aid, err := strconv.Atoi(rest[0])
+	if err != nil || len(rest) > 1 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidID)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		srv.downloadAttachment(w, r, id, aid)
+	case http.MethodDelete:
+		srv.deleteAttachment(w, r, id, aid)
+	default:
+		writeMethodNotAllowed(w, r, "GET, HEAD, DELETE")
+	}
+}
+
+// listAttachments devuelve los adjuntos de la tarea.
+func (srv *server) listAttachments(w http.ResponseWriter, r *http.Request, id int) {
+	srv.store.mu.RLock()
+	t, ok := srv.store.getLocked(id)
+	srv.store.mu.RUnlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, codeTaskNotFound, id)
+		return
+	}
+	list := t.Attachments
+	if list == nil {
+		list = []Attachment{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// uploadAttachment guarda el fichero del campo "file" y lo añade a la tarea.
+func (srv *server) uploadAttachment(w http.ResponseWriter, r *http.Request, id int) {
+	// Comprobar la tarea antes de leer el cuerpo para no subir en balde.
+	srv.store.mu.RLock()
+	_, ok := srv.store.getLocked(id)
+	srv.store.mu.RUnlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, codeTaskNotFound, id)
+		return
+	}
+
+	// El límite del cuerpo deja margen para las cabeceras del multipart.
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidMultipart)
+		return
+	}
+
+	var (
+		info blobInfo
+		name string
+	)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			writeError(w, r, http.StatusBadRequest, codeFileRequired)
+			return
+		}
+		if err != nil {
+			writeUploadError(w, r, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		info, err = srv.blobs.put(part, maxAttachmentSize)
+		part.Close()
+		if err != nil {
+			writeUploadError(w, r, err)
+			return
+		}
+		name = attachmentName(part.FileName())
+		break
+	}
+
+	st := srv.store
+	st.lockFor(r.Context())
+	t, ok := st.getLocked(id)
+	if !ok { // borrada durante la subida; gc limpiará el blob
+		st.unlock()
+		writeError(w, r, http.StatusNotFound, codeTaskNotFound, id)
+		return
+	}
+	a := Attachment{
+		ID:          st.nextAttachmentID,
+		Name:        name,
+		ContentType: info.ContentType,
+		Size:        info.Size,
+		SHA256:      info.SHA256,
+		CreatedAt:   time.Now().UTC(),
+	}
+	// Clip obliga a copiar: el log de cambios guarda el slice anterior.
+	t.Attachments = append(slices.Clip(t.Attachments), a)
+	st.updateLocked(t)
+	st.unlock()
+
+	w.Header().Set("Location", "/tasks/"+strconv.Itoa(id)+"/attachments/"+strconv.Itoa(a.ID))
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// writeUploadError responde al error de leer o guardar una subida: el
+// cuerpo excede el límite (413), el multipart está mal formado o cortado
+// (400) o falló el disco (500).
+func writeUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	var fsErr *fs.PathError
+	switch {
+	case errors.Is(err, errBlobTooLarge), errors.As(err, &tooLarge):
+		writeError(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, maxAttachmentSize)
+	case errors.As(err, &fsErr):
+		slog.Error("error guardando adjunto", "err", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternalError)
+	default:
+		writeError(w, r, http.StatusBadRequest, codeInvalidMultipart)
+	}
+}
+
+// attachmentName limpia el nombre de fichero que manda el cliente.
+func attachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" || name == "" {
+		return "adjunto"
+	}
+	return name
+}
+
+// findAttachmentLocked busca el adjunto aid de la tarea id.
+func (s *taskStore) findAttachmentLocked(id, aid int) (Attachment, bool) {
+	t, ok := s.getLocked(id)
+	if !ok {
+		return Attachment{}, false
+	}
+	for _, a := range t.Attachments {
+		if a.ID == aid {
+			return a, true
+		}
+	}
+	return Attachment{}, false
+}
+
+// downloadAttachment sirve el contenido del adjunto. http.ServeContent se
+// encarga de Range, If-Range y de las peticiones condicionales por ETag.
+func (srv *server) downloadAttachment(w http.ResponseWriter, r *http.Request, id, aid int) {
+	srv.store.mu.RLock()
+	a, ok := srv.store.findAttachmentLocked(id, aid)
+	srv.store.mu.RUnlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, codeAttachmentNotFound, aid)
+		return
+	}
+	f, err := srv.openBlob(r.Context(), a.SHA256)
+	if err != nil {
+		slog.Warn("no se encuentra el contenido del adjunto", "task_id", id, "attachment_id", aid, "err", err)
+		writeError(w, r, http.StatusNotFound, codeAttachmentNotFound, aid)
+		return
+	}
+	defer f.Close()
+
+	// Solo las imágenes se muestran en el navegador; el resto se descarga
+	// para que un HTML subido no se ejecute en nuestro origen.
+	disposition := "attachment"
+	if strings.HasPrefix(a.ContentType, "image/") {
+		disposition = "inline"
+	}
+	h := w.Header()
+	h.Set("Content-Type", a.ContentType)
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, a.Name, a.CreatedAt, f)
+}
+
+// openBlob abre el blob sum. Un seguidor que aún no lo tiene lo trae
+// antes del líder.
+func (srv *server) openBlob(ctx context.Context, sum string) (*os.File, error) {
+	f, err := srv.blobs.open(sum)
+	if errors.Is(err, fs.ErrNotExist) && srv.follower != nil {
+		if err = srv.follower.fetchBlob(ctx, sum, srv.blobs); err == nil {
+			f, err = srv.blobs.open(sum)
+		}
+	}
+	return f, err
+}
+
+// deleteAttachment quita el adjunto de la tarea. El blob se borra en el
+// siguiente gc si ninguna otra tarea lo usa.
+func (srv *server) deleteAttachment(w http.ResponseWriter, r *http.Request, id, aid int) {
+	st := srv.store
+	st.lockFor(r.Context())
+	defer st.unlock()
+	if _, ok := st.findAttachmentLocked(id, aid); !ok {
+		writeError(w, r, http.StatusNotFound, codeAttachmentNotFound, aid)
+		return
+	}
+	t, _ := st.getLocked(id)
+	t.Attachments = slices.DeleteFunc(slices.Clone(t.Attachments), func(a Attachment) bool { return a.ID == aid })
+	if len(t.Attachments) == 0 {
+		t.Attachments = nil
+	}
+	st.updateLocked(t)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// blobsInUseLocked devuelve los hashes referenciados por alguna tarea.
+func (s *taskStore) blobsInUseLocked() map[string]bool {
+	used := make(map[string]bool)
+	for _, t := range s.tasks {
+		for _, a := range t.Attachments {
+			used[a.SHA256] = true
+		}
+	}
+	return used
+}
+
+// collectBlobs borra los blobs huérfanos.
+func (srv *server) collectBlobs() {
+	srv.store.mu.Lock()
+	used := srv.store.blobsInUseLocked()
+	srv.store.mu.Unlock()
+	n, err := srv.blobs.gc(used, blobGCGrace)
+	if err != nil {
+		slog.Error("gc de adjuntos", "err", err)
+	}
+	if n > 0 {
+		slog.Info("gc de adjuntos: blobs huérfanos borrados", "count", n)
+	}
+}
+
+// runBlobGC ejecuta collectBlobs cada interval hasta el apagado.
+func (srv *server) runBlobGC(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		srv.collectBlobs()
+		select {
+		case <-ticker.C:
+		case <-shuttingDown:
+			return
+		}
+	}
+}
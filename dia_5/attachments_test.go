@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newAttachmentServer crea un servidor con dos tareas y blobs en un
+// directorio temporal.
+func newAttachmentServer(t *testing.T) *server {
+	t.Helper()
+	srv := newServer(newTaskStore())
+	srv.blobs = newBlobStore(t.TempDir())
+	srv.store.mu.Lock()
+	srv.store.createLocked(Task{Title: "con captura"})
+	srv.store.createLocked(Task{Title: "otra"})
+	srv.store.mu.Unlock()
+	return srv
+}
+
+// upload sube content como fichero name a la tarea id.
+func upload(t *testing.T, h http.Handler, id, field, name string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile(field, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/tasks/"+id+"/attachments", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// countBlobs cuenta los blobs guardados en dir.
+func countBlobs(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, _ error) error {
+		if d != nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestAttachmentUploadDownload(t *testing.T) {
+	srv := newAttachmentServer(t)
+	h := srv.routes()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte("x"), 100)...)
+
+	w := upload(t, h, "1", "file", `C:\capturas\pantalla.png`, png)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status = %d; want 201 (%s)", w.Code, w.Body)
+	}
+	var a Attachment
+	if err := json.NewDecoder(w.Body).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "pantalla.png" || a.ContentType != "image/png" || a.Size != int64(len(png)) {
+		t.Errorf("adjunto = %+v", a)
+	}
+	if loc := w.Header().Get("Location"); loc != "/tasks/1/attachments/1" {
+		t.Errorf("Location = %q", loc)
+	}
+
+	// El mismo contenido en otra tarea no crea otro blob.
+	if w := upload(t, h, "2", "file", "copia.png", png); w.Code != http.StatusCreated {
+		t.Fatalf("segundo upload: status = %d", w.Code)
+	}
+	if n := countBlobs(t, srv.blobs.dir); n != 1 {
+		t.Errorf("blobs = %d; want 1 (deduplicado)", n)
+	}
+
+	tests := []struct {
+		name       string
+		rangeHdr   string
+		wantStatus int
+		wantBody   []byte
+	}{
+		{"completo", "", http.StatusOK, png},
+		{"rango", "bytes=0-7", http.StatusPartialContent, png[:8]},
+		{"sufijo", "bytes=-4", http.StatusPartialContent, png[len(png)-4:]},
+		{"fuera de rango", "bytes=5000-", http.StatusRequestedRangeNotSatisfiable, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/tasks/1/attachments/1", nil)
+			if tt.rangeHdr != "" {
+				r.Header.Set("Range", tt.rangeHdr)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != nil && !bytes.Equal(w.Body.Bytes(), tt.wantBody) {
+				t.Errorf("got %q; want %q", w.Body.Bytes(), tt.wantBody)
+			}
+		})
+	}
+
+	// Borrar de una tarea no afecta a la otra ni al blob compartido.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/tasks/1/attachments/1", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d; want 204", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/1/attachments/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("tras delete: status = %d; want 404", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/2/attachments/2", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("adjunto de la otra tarea: status = %d; want 200", w.Code)
+	}
+}
+
+func TestAttachmentUploadErrors(t *testing.T) {
+	saved := maxAttachmentSize
+	maxAttachmentSize = 16
+	t.Cleanup(func() { maxAttachmentSize = saved })
+
+	tests := []struct {
+		name       string
+		id, field  string
+		content    string
+		wantStatus int
+		wantCode   string
+	}{
+		{"demasiado grande", "1", "file", strings.Repeat("a", 17), http.StatusRequestEntityTooLarge, codeBodyTooLarge},
+		{"justo en el límite", "1", "file", strings.Repeat("a", 16), http.StatusCreated, ""},
+		{"sin campo file", "1", "otro", "hola", http.StatusBadRequest, codeFileRequired},
+		{"tarea inexistente", "9", "file", "hola", http.StatusNotFound, codeTaskNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newAttachmentServer(t)
+			w := upload(t, srv.routes(), tt.id, tt.field, "log.txt", []byte(tt.content))
+			var p problem
+			_ = json.NewDecoder(w.Body).Decode(&p)
+			if w.Code != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("got %d %q; want %d %q", w.Code, p.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestBlobGC(t *testing.T) {
+	b := newBlobStore(t.TempDir())
+	keep, err := b.put(strings.NewReader("se usa"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := b.put(strings.NewReader("huérfano"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Con margen de gracia no se borra nada recién subido.
+	if n, err := b.gc(map[string]bool{keep.SHA256: true}, blobGCGrace); err != nil || n != 0 {
+		t.Errorf("gc con gracia: got %d, %v; want 0", n, err)
+	}
+	if n, err := b.gc(map[string]bool{keep.SHA256: true}, 0); err != nil || n != 1 {
+		t.Errorf("gc: got %d, %v; want 1", n, err)
+	}
+	if _, err := b.open(orphan.SHA256); !os.IsNotExist(err) {
+		t.Errorf("el huérfano sigue ahí: %v", err)
+	}
+	f, err := b.open(keep.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, _ := io.ReadAll(f); string(got) != "se usa" {
+		t.Errorf("contenido = %q", got)
+	}
+}
+
+func TestBlobGCSharedDir(t *testing.T) {
+	// El directorio de blobs puede ser el mismo que el de los datos.
+	dir := t.TempDir()
+	b := newBlobStore(dir)
+	orphan, err := b.put(strings.NewReader("huérfano"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	others := []string{
+		"tasks.json",
+		"audit.log",
+		filepath.Join(orphan.SHA256[:2], "notas.txt"),
+		filepath.Join("backups", strings.Repeat("a", 64)),
+		filepath.Join("ab", strings.Repeat("c", 64)), // no empieza por ab
+	}
+	for _, name := range others {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("no es un blob"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmp := filepath.Join(dir, "tmp-123")
+	if err := os.WriteFile(tmp, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := b.gc(nil, 0); err != nil || n != 2 {
+		t.Errorf("gc: got %d, %v; want 2 (el huérfano y el temporal)", n, err)
+	}
+	for _, name := range others {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("gc borró %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("el temporal sigue ahí: %v", err)
+	}
+}
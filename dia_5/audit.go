@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registro de auditoría: cada petición POST/PUT/PATCH/DELETE añade una
+// línea JSON al fichero audit_file con quién hizo qué y cómo acabó. Cada
+// entrada incluye el hash de la anterior y su propio hash es un HMAC con
+// la clave audit_key, así que editar, borrar o reordenar entradas rompe la
+// cadena y, sin la clave, no se puede recalcular; "api verify <fichero>"
+// la comprueba. La última entrada se guarda además en <fichero>.head, para
+// detectar también que se hayan borrado las últimas.
+
+// auditEntry es una línea del registro.
+type auditEntry struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"` // CN del certificado, o "anonymous"
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	TaskID    int       `json:"task_id,omitempty"`
+	ProjectID int       `json:"project_id,omitempty"` // en los cambios de proyectos, en lugar de task_id
+	Before    string    `json:"before,omitempty"`     // sha256 de la tarea (o proyecto) antes ("" si no existía)
+	After     string    `json:"after,omitempty"`      // sha256 de la tarea (o proyecto) después ("" si no existe)
+	Revision  int64     `json:"revision,omitempty"`   // revisión del almacén tras el cambio
+	Status    int       `json:"status,omitempty"`     // solo en las peticiones que no cambian nada
+	Outcome   string    `json:"outcome"`              // "committed", o "ok", "redirected", "rejected" o "error"
+	Prev      string    `json:"prev"`                 // hash de la entrada anterior ("" en la primera)
+	Hash      string    `json:"hash"`
+}
+
+// hash calcula el hash de la entrada: HMAC-SHA256 con key de su JSON con
+// Hash vacío.
+func (e auditEntry) hash(key []byte) string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditHead es el ancla de la cadena: la última entrada escrita, guardada
+// en <fichero>.head. Sin ella, truncar el registro deja una cadena válida.
+type auditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// auditHeadPath es el fichero con la cabeza del registro path.
+func auditHeadPath(path string) string {
+	return path + ".head"
+}
+
+// auditLog añade entradas encadenadas a un fichero.
+type auditLog struct {
+	mu      sync.Mutex
+	f       *os.File
+	head    string // ruta de la cabeza
+	key     []byte // clave del HMAC
+	seq     int64
+	prev    string
+	lastErr error // último fallo de escritura, para /readyz
+}
+
+// openAuditLog abre (o crea) el registro en path, firmado con key. Si ya
+// existe, comprueba la cadena y su cabeza y continúa a partir de la última
+// entrada.
+func openAuditLog(path string, key []byte) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	last, err := verifyAudit(f, key)
+	if err == nil {
+		err = checkAuditHead(path, last)
+	}
+	if err == nil && last.Seq > 0 {
+		// Por si se cortó justo entre la entrada y la cabeza.
+		err = writeFileAtomic(auditHeadPath(path), auditHead{last.Seq, last.Hash})
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &auditLog{f: f, head: auditHeadPath(path), key: key, seq: last.Seq, prev: last.Hash}, nil
+}
+
+// append encadena e al registro, lo escribe a disco y después actualiza
+// la cabeza, todo antes de volver.
+func (a *auditLog) append(e auditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e.Seq, e.Prev = a.seq+1, a.prev
+	e.Hash = e.hash(a.key)
+	b, err := json.Marshal(e)
+	if err == nil {
+		_, err = a.f.Write(append(b, '\n'))
+	}
+	if err == nil {
+		err = a.f.Sync()
+	}
+	a.lastErr = err
+	if err != nil {
+		return err
+	}
+	a.seq, a.prev = e.Seq, e.Hash
+	a.lastErr = writeFileAtomic(a.head, auditHead{e.Seq, e.Hash})
+	return a.lastErr
+}
+
+// check es el chequeo de /readyz: falla si la última escritura falló.
+func (a *auditLog) check(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastErr
+}
+
+// close cierra el fichero.
+func (a *auditLog) close() error {
+	return a.f.Close()
+}
+
+// verifyAudit recorre el registro de r y comprueba la numeración y la
+// cadena de hashes con la clave key. Devuelve la última entrada válida.
+func verifyAudit(r io.Reader, key []byte) (auditEntry, error) {
+	var last auditEntry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return last, fmt.Errorf("línea %d: JSON inválido: %v", line, err)
+		}
+		switch {
+		case e.Seq != last.Seq+1:
+			return last, fmt.Errorf("línea %d: se esperaba la entrada %d y está la %d (falta o sobra alguna)", line, last.Seq+1, e.Seq)
+		case e.Prev != last.Hash:
+			return last, fmt.Errorf("línea %d: la entrada %d no encadena con la anterior", line, e.Seq)
+		case !hmac.Equal([]byte(e.Hash), []byte(e.hash(key))):
+			return last, fmt.Errorf("línea %d: la entrada %d fue modificada (o la clave no es la del registro)", line, e.Seq)
+		}
+		last = e
+	}
+	return last, sc.Err()
+}
+
+// checkAuditHead comprueba que el registro path, cuya última entrada es
+// last, llega hasta su cabeza. La cabeza puede ir una entrada por detrás:
+// es lo que queda si el proceso muere entre escribir una y otra.
+func checkAuditHead(path string, last auditEntry) error {
+	data, err := os.ReadFile(auditHeadPath(path))
+	if errors.Is(err, os.ErrNotExist) && last.Seq == 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cabeza del registro: %w", err)
+	}
+	var h auditHead
+	if err := json.Unmarshal(data, &h); err != nil {
+		return fmt.Errorf("cabeza del registro: JSON inválido: %v", err)
+	}
+	switch {
+	case h.Seq == last.Seq && h.Hash == last.Hash:
+	case h.Seq == last.Seq-1 && h.Hash == last.Prev:
+	case h.Seq > last.Seq:
+		return fmt.Errorf("el registro acaba en la entrada %d y la cabeza es la %d (faltan las últimas)", last.Seq, h.Seq)
+	default:
+		return fmt.Errorf("la cabeza (entrada %d) no coincide con el registro", h.Seq)
+	}
+	return nil
+}
+
+// runVerify implementa "api verify [-key clave] <fichero>": comprueba el
+// registro con la clave del HMAC y que llegue hasta su cabeza, y muestra
+// la última entrada.
+func runVerify(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(out)
+	key := fs.String("key", os.Getenv("TASKS_AUDIT_KEY"), "clave del HMAC (por defecto $TASKS_AUDIT_KEY)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	args = fs.Args()
+	if len(args) != 1 || *key == "" {
+		fmt.Fprintln(out, "uso: api verify [-key clave] <fichero de auditoría>")
+		return 2
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	defer f.Close()
+	last, err := verifyAudit(f, []byte(*key))
+	if err == nil {
+		err = checkAuditHead(args[0], last)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "registro dañado: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "ok: %d entradas, última %s\n", last.Seq, last.Hash)
+	return 0
+}
+
+// auditTaskID extrae el ID de tarea de rutas como /tasks/{id}/... o
+// /ui/tasks/{id}/...; 0 si no hay.
+func auditTaskID(path string) int {
+	rest, ok := strings.CutPrefix(path, "/tasks/")
+	if !ok {
+		rest, ok = strings.CutPrefix(path, "/ui/tasks/")
+	}
+	if !ok {
+		return 0
+	}
+	idStr, _, _ := strings.Cut(rest, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// auditProjectID extrae el ID de proyecto de /projects/{id}; 0 si no hay.
+func auditProjectID(path string) int {
+	rest, ok := strings.CutPrefix(path, "/projects/")
+	if !ok {
+		return 0
+	}
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// taskHash devuelve el sha256 del JSON de t, o "" si t es nil (la tarea
+// no existe).
+func taskHash(t *Task) string {
+	if t == nil {
+		return ""
+	}
+	return jsonHash(t)
+}
+
+// projectHash es taskHash para proyectos.
+func projectHash(p *Project) string {
+	if p == nil {
+		return ""
+	}
+	return jsonHash(p)
+}
+
+// jsonHash devuelve el sha256 del JSON de v.
+func jsonHash(v any) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditOp es lo que una operación hizo a una tarea o a un proyecto (solo
+// uno de los dos IDs no es cero): el hash de antes y el de después.
+type auditOp struct {
+	taskID, projectID int
+	before, after     string
+}
+
+// auditTrail es la auditoría de una petición. Los métodos *Locked del
+// almacén anotan cada cambio con el lock tomado y unlock escribe las
+// entradas antes de soltarlo, así que el registro sigue el orden de las
+// revisiones y los hashes son exactamente los de esa petición aunque otras
+// escriban la misma tarea a la vez.
+type auditTrail struct {
+	log   *auditLog
+	base  auditEntry // actor, request_id, método y ruta
+	ops   []auditOp  // cambios de la sección en curso, uno por tarea o proyecto
+	wrote bool       // si ya se escribió alguna entrada
+	err   error      // primer fallo al escribir
+}
+
+type auditTrailKey struct{}
+
+// auditTrailFrom devuelve el auditTrail de ctx, o nil.
+func auditTrailFrom(ctx context.Context) *auditTrail {
+	tr, _ := ctx.Value(auditTrailKey{}).(*auditTrail)
+	return tr
+}
+
+// note anota que la tarea id pasó de before a after (nil si no existe).
+// Si la sección ya la había cambiado, se conserva el antes de la primera
+// vez.
+func (tr *auditTrail) note(id int, before, after *Task) {
+	tr.noteOp(auditOp{taskID: id, before: taskHash(before), after: taskHash(after)})
+}
+
+// noteProject es note para el proyecto id.
+func (tr *auditTrail) noteProject(id int, before, after *Project) {
+	tr.noteOp(auditOp{projectID: id, before: projectHash(before), after: projectHash(after)})
+}
+
+func (tr *auditTrail) noteOp(op auditOp) {
+	for i := range tr.ops {
+		if tr.ops[i].taskID == op.taskID && tr.ops[i].projectID == op.projectID {
+			tr.ops[i].after = op.after
+			return
+		}
+	}
+	tr.ops = append(tr.ops, op)
+}
+
+// flush escribe una entrada por tarea o proyecto cambiado en la sección, con la
+// revisión a la que llegó el almacén, y empieza otra. Se llama con el lock
+// del almacén tomado.
+func (tr *auditTrail) flush(revision int64) {
+	for _, op := range tr.ops {
+		e := tr.base
+		e.Time = time.Now().UTC()
+		e.TaskID, e.ProjectID = op.taskID, op.projectID
+		e.Before, e.After = op.before, op.after
+		e.Revision = revision
+		e.Outcome = "committed"
+		if err := tr.log.append(e); err != nil && tr.err == nil {
+			tr.err = err
+		}
+		tr.wrote = true
+	}
+	tr.ops = tr.ops[:0]
+}
+
+// noteAuditLocked anota el cambio en el registro de la petición que tiene
+// el lock, si se audita.
+func (s *taskStore) noteAuditLocked(id int, before, after *Task) {
+	if s.trail != nil {
+		s.trail.note(id, before, after)
+	}
+}
+
+// noteProjectAuditLocked es noteAuditLocked para proyectos.
+func (s *taskStore) noteProjectAuditLocked(id int, before, after *Project) {
+	if s.trail != nil {
+		s.trail.noteProject(id, before, after)
+	}
+}
+
+// auditResponse guarda la respuesta de una petición auditada hasta saber
+// si la auditoría falló.
+type auditResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditResponse) Header() http.Header { return w.header }
+
+func (w *auditResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *auditResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// outcome resume un status para el registro.
+func outcome(status int) string {
+	switch {
+	case status >= 500:
+		return "error"
+	case status >= 400:
+		return "rejected"
+	case status >= 300:
+		return "redirected"
+	default:
+		return "ok"
+	}
+}
+
+// withAudit registra en a las peticiones que modifican datos: una entrada
+// por tarea o proyecto cambiado, escrita dentro de la mutación, o una con el status
+// si la petición no cambió nada. Si no se puede escribir responde 500 en
+// lugar de la respuesta del handler. Usa la cabecera X-Request-ID del
+// cliente o genera una, y la devuelve.
+func (srv *server) withAudit(a *auditLog, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reqID := r.Header.Get("X-Request-ID")
+		if reqID == "" {
+			reqID = hex.EncodeToString(randomBytes(8))
+		}
+		w.Header().Set("X-Request-ID", reqID)
+
+		actor := userFromRequest(r)
+		if actor == "" {
+			actor = "anonymous"
+		}
+		tr := &auditTrail{log: a, base: auditEntry{
+			Actor:     actor,
+			RequestID: reqID,
+			Method:    r.Method,
+			Route:     r.URL.Path,
+		}}
+		rec := &auditResponse{header: w.Header().Clone()}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditTrailKey{}, tr)))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if !tr.wrote && tr.err == nil {
+			e := tr.base
+			e.Time = time.Now().UTC()
+			e.TaskID = auditTaskID(r.URL.Path)
+			e.ProjectID = auditProjectID(r.URL.Path)
+			e.Status = rec.status
+			e.Outcome = outcome(rec.status)
+			tr.err = a.append(e)
+		}
+		if tr.err != nil {
+			slog.Error("auditoría: no se pudo registrar la petición", "method", r.Method, "path", r.URL.Path, "request_id", reqID, "err", tr.err)
+			writeError(w, r, http.StatusInternalServerError, codeAuditFailed)
+			return
+		}
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAuditKey es la clave del HMAC en los tests.
+const testAuditKey = "clave-de-prueba-1234"
+
+// auditedServer devuelve un handler con auditoría en un fichero temporal.
+func auditedServer(t *testing.T, path string) (*server, http.Handler, *auditLog) {
+	t.Helper()
+	a, err := openAuditLog(path, []byte(testAuditKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.close() })
+	srv := newServer(newTaskStore())
+	return srv, srv.withAudit(a, srv.routes()), a
+}
+
+// readAudit lee las entradas del registro.
+func readAudit(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []auditEntry
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var e auditEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAuditRecordsMutations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	_, h, _ := auditedServer(t, path)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"a"}`))
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("X-Request-ID = %q; want req-1", got)
+	}
+	do(t, h, http.MethodGet, "/tasks", "") // las lecturas no se registran
+	do(t, h, http.MethodPost, "/tasks", `{"title":""}`)
+	do(t, h, http.MethodPost, "/rpc", `{"jsonrpc":"2.0","method":"tasks.update","params":{"id":1,"done":true},"id":1}`)
+	do(t, h, http.MethodDelete, "/projects/9", "")
+	do(t, h, http.MethodPost, "/projects", `{"name":"Web"}`)
+	do(t, h, http.MethodPatch, "/projects/1", `{"name":"Web 2"}`)
+	do(t, h, http.MethodDelete, "/projects/1", "")
+
+	entries := readAudit(t, path)
+	tests := []struct {
+		method, route string
+		taskID        int
+		projectID     int
+		revision      int64
+		status        int
+		outcome       string
+		before, after bool // si hay hash
+	}{
+		// Los cambios se anotan al hacerse, con la revisión y sin status.
+		{http.MethodPost, "/tasks", 1, 0, 1, 0, "committed", false, true},
+		// Las peticiones que no cambian nada, con su status.
+		{http.MethodPost, "/tasks", 0, 0, 0, http.StatusBadRequest, "rejected", false, false},
+		{http.MethodPost, "/rpc", 1, 0, 2, 0, "committed", true, true},
+		{http.MethodDelete, "/projects/9", 0, 9, 0, http.StatusNotFound, "rejected", false, false},
+		// Los cambios de proyectos, con project_id.
+		{http.MethodPost, "/projects", 0, 1, 3, 0, "committed", false, true},
+		{http.MethodPatch, "/projects/1", 0, 1, 4, 0, "committed", true, true},
+		{http.MethodDelete, "/projects/1", 0, 1, 5, 0, "committed", true, false},
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("got %d entradas; want %d", len(entries), len(tests))
+	}
+	for i, tt := range tests {
+		e := entries[i]
+		if e.Method != tt.method || e.Route != tt.route || e.TaskID != tt.taskID || e.ProjectID != tt.projectID || e.Revision != tt.revision || e.Status != tt.status || e.Outcome != tt.outcome {
+			t.Errorf("entrada %d = %s %s tarea %d proyecto %d rev %d %d %s; want %s %s tarea %d proyecto %d rev %d %d %s", i+1,
+				e.Method, e.Route, e.TaskID, e.ProjectID, e.Revision, e.Status, e.Outcome,
+				tt.method, tt.route, tt.taskID, tt.projectID, tt.revision, tt.status, tt.outcome)
+		}
+		if (e.Before != "") != tt.before || (e.After != "") != tt.after {
+			t.Errorf("entrada %d: before %q after %q", i+1, e.Before, e.After)
+		}
+		if e.Actor != "anonymous" || e.RequestID == "" {
+			t.Errorf("entrada %d: actor %q request_id %q", i+1, e.Actor, e.RequestID)
+		}
+	}
+	if entries[0].RequestID != "req-1" {
+		t.Errorf("request_id = %q; want req-1", entries[0].RequestID)
+	}
+}
+
+func TestAuditBeforeAfterHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	srv, h, _ := auditedServer(t, path)
+	srv.store.mu.Lock()
+	srv.store.createLocked(Task{Title: "a"})
+	srv.store.mu.Unlock()
+	do(t, h, http.MethodPost, "/projects", `{"name":"Web"}`)
+	do(t, h, http.MethodPost, "/tasks/1/move", `{"project_id":1}`)
+
+	e := readAudit(t, path)[1]
+	if e.TaskID != 1 || e.Before == "" || e.After == "" || e.Before == e.After {
+		t.Errorf("move: tarea %d before %q after %q; want hashes distintos", e.TaskID, e.Before, e.After)
+	}
+}
+
+func TestAuditVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	_, h, a := auditedServer(t, path)
+	for range 3 {
+		do(t, h, http.MethodPost, "/tasks", `{"title":"a"}`)
+	}
+	a.close()
+
+	// Al reabrir se continúa la cadena.
+	_, h, a = auditedServer(t, path)
+	do(t, h, http.MethodPost, "/tasks", `{"title":"b"}`)
+	a.close()
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := os.ReadFile(auditHeadPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(original), "\n")
+
+	tests := []struct {
+		name    string
+		content string
+		head    bool // copiar también la cabeza
+		want    int
+	}{
+		{"intacto", string(original), true, 0},
+		{"entrada editada", strings.Replace(string(original), `"outcome":"committed"`, `"outcome":"ok"`, 1), true, 1},
+		{"entrada borrada", lines[0] + strings.Join(lines[2:], ""), true, 1},
+		{"entradas reordenadas", lines[1] + lines[0] + strings.Join(lines[2:], ""), true, 1},
+		{"últimas entradas borradas", lines[0] + lines[1], true, 1},
+		{"todo borrado", "", true, 1},
+		{"sin cabeza", string(original), false, 1},
+		{"vacío", "", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "audit.log")
+			if err := os.WriteFile(p, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.head {
+				if err := os.WriteFile(auditHeadPath(p), head, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var out bytes.Buffer
+			if got := runVerify([]string{"-key", testAuditKey, p}, &out); got != tt.want {
+				t.Errorf("runVerify = %d (%s); want %d", got, strings.TrimSpace(out.String()), tt.want)
+			}
+		})
+	}
+
+	// Un registro dañado o truncado impide arrancar.
+	for _, content := range []string{lines[1], lines[0] + lines[1]} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := openAuditLog(path, []byte(testAuditKey)); err == nil {
+			t.Errorf("openAuditLog con %d líneas: got nil; want error", strings.Count(content, "\n"))
+		}
+	}
+}
+
+func TestAuditVerifyRecomputedChain(t *testing.T) {
+	// Quien puede escribir el registro lo edita y recalcula toda la cadena
+	// y la cabeza, pero sin la clave del HMAC.
+	path := filepath.Join(t.TempDir(), "audit.log")
+	_, h, a := auditedServer(t, path)
+	for range 3 {
+		do(t, h, http.MethodPost, "/tasks", `{"title":"a"}`)
+	}
+	a.close()
+	entries := readAudit(t, path)
+
+	for _, key := range []string{"", "otra-clave-cualquiera"} {
+		var buf bytes.Buffer
+		prev := ""
+		for _, e := range entries {
+			e.Actor = "otro"
+			e.Prev = prev
+			if key == "" {
+				// sha256 simple, como antes de firmar la cadena.
+				e.Hash = ""
+				b, _ := json.Marshal(e)
+				sum := sha256.Sum256(b)
+				e.Hash = hex.EncodeToString(sum[:])
+			} else {
+				e.Hash = e.hash([]byte(key))
+			}
+			prev = e.Hash
+			b, _ := json.Marshal(e)
+			buf.Write(append(b, '\n'))
+		}
+		last := entries[len(entries)-1]
+		head, _ := json.Marshal(auditHead{Seq: last.Seq, Hash: prev})
+		if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(auditHeadPath(path), head, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if got := runVerify([]string{"-key", testAuditKey, path}, &out); got != 1 {
+			t.Errorf("clave %q: runVerify = %d (%s); want 1", key, got, strings.TrimSpace(out.String()))
+		}
+		if _, err := openAuditLog(path, []byte(testAuditKey)); err == nil {
+			t.Errorf("clave %q: openAuditLog = nil; want error", key)
+		}
+	}
+}
+
+func TestAuditHeadCrash(t *testing.T) {
+	// Si el proceso muere tras escribir una entrada y antes de la cabeza,
+	// la cabeza va una por detrás y el registro sigue siendo válido.
+	path := filepath.Join(t.TempDir(), "audit.log")
+	_, h, a := auditedServer(t, path)
+	do(t, h, http.MethodPost, "/tasks", `{"title":"a"}`)
+	head, err := os.ReadFile(auditHeadPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(t, h, http.MethodPost, "/tasks", `{"title":"b"}`)
+	a.close()
+	if err := os.WriteFile(auditHeadPath(path), head, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if got := runVerify([]string{"-key", testAuditKey, path}, &out); got != 0 {
+		t.Errorf("runVerify = %d (%s); want 0", got, strings.TrimSpace(out.String()))
+	}
+	a, err = openAuditLog(path, []byte(testAuditKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.close()
+	// Al abrir se pone al día.
+	if got, want := readHead(t, path), (auditHead{2, readAudit(t, path)[1].Hash}); got != want {
+		t.Errorf("cabeza = %+v; want %+v", got, want)
+	}
+}
+
+// readHead lee la cabeza del registro path.
+func readHead(t *testing.T, path string) auditHead {
+	t.Helper()
+	data, err := os.ReadFile(auditHeadPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h auditHead
+	if err := json.Unmarshal(data, &h); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestAuditRestore(t *testing.T) {
+	// Una restauración deja una entrada por tarea que cambia, aparece o
+	// desaparece.
+	withAdminToken(t, "secreto")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	srv, h, _ := auditedServer(t, path)
+	st := srv.store
+	st.mu.Lock()
+	for _, title := range []string{"igual", "cambia", "se va"} {
+		st.createLocked(Task{Title: title})
+	}
+	snap := st.snapshotLocked()
+	before := map[int]string{}
+	for _, t := range snap.Tasks {
+		before[t.ID] = taskHash(&t)
+	}
+	st.mu.Unlock()
+
+	snap.Tasks[1].Title = "cambiada"
+	snap.Tasks = append(snap.Tasks[:2], Task{ID: 4, Title: "nueva"})
+	snap.NextID = 5
+	var archive bytes.Buffer
+	if err := writeBackup(&archive, snap, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), adminRequest(http.MethodPost, "/admin/restore", archive.Bytes()))
+
+	st.mu.RLock()
+	rev := st.revision
+	changed, _ := st.getLocked(2)
+	added, _ := st.getLocked(4)
+	st.mu.RUnlock()
+	want := map[int][2]string{
+		2: {before[2], taskHash(&changed)},
+		3: {before[3], ""},
+		4: {"", taskHash(&added)},
+	}
+	got := map[int][2]string{}
+	for _, e := range readAudit(t, path) {
+		if e.Route != "/admin/restore" || e.Revision != rev || e.Outcome != "committed" {
+			t.Errorf("entrada %d = %s rev %d %s; want /admin/restore rev %d committed", e.Seq, e.Route, e.Revision, e.Outcome, rev)
+		}
+		got[e.TaskID] = [2]string{e.Before, e.After}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entradas = %v; want %v", got, want)
+	}
+}
+
+func TestAuditRPCAndUI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	srv, h, _ := auditedServer(t, path)
+	hash := func(id int) string {
+		srv.store.mu.RLock()
+		defer srv.store.mu.RUnlock()
+		t, ok := srv.store.getLocked(id)
+		if !ok {
+			return ""
+		}
+		return taskHash(&t)
+	}
+
+	do(t, h, http.MethodPost, "/rpc", `{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"a"},"id":1}`)
+	created := hash(1)
+	do(t, h, http.MethodPost, "/rpc", `[
+		{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"b"},"id":1},
+		{"jsonrpc":"2.0","method":"tasks.update","params":{"id":1,"done":true},"id":2},
+		{"jsonrpc":"2.0","method":"tasks.get","params":{"id":1},"id":3}
+	]`)
+	updated := hash(1)
+	cookie, token := uiSession(t, h)
+	uiPost(h, "/ui/tasks", cookie, url.Values{"title": {"c"}, csrfField: {token}}, nil)
+	third := hash(3)
+	uiPost(h, "/ui/tasks/1/delete", cookie, url.Values{csrfField: {token}}, nil)
+
+	tests := []struct {
+		route         string
+		taskID        int
+		before, after string
+	}{
+		{"/rpc", 1, "", created},
+		// Un lote: una entrada por llamada que cambia algo.
+		{"/rpc", 2, "", hash(2)},
+		{"/rpc", 1, created, updated},
+		{"/ui/tasks", 3, "", third},
+		{"/ui/tasks/1/delete", 1, updated, ""},
+	}
+	entries := readAudit(t, path)
+	if len(entries) != len(tests) {
+		t.Fatalf("got %d entradas; want %d", len(entries), len(tests))
+	}
+	for i, tt := range tests {
+		e := entries[i]
+		if e.Route != tt.route || e.TaskID != tt.taskID || e.Before != tt.before || e.After != tt.after {
+			t.Errorf("entrada %d = %s tarea %d %q → %q; want %s tarea %d %q → %q", i+1,
+				e.Route, e.TaskID, e.Before, e.After, tt.route, tt.taskID, tt.before, tt.after)
+		}
+	}
+	if entries[1].RequestID != entries[2].RequestID {
+		t.Error("las entradas del lote tienen request_id distintos")
+	}
+}
+
+func TestAuditConcurrentWrites(t *testing.T) {
+	// Con escrituras simultáneas sobre la misma tarea, cada entrada lleva
+	// el antes y el después de su propia petición: juntas forman una sola
+	// cadena de estados sin saltos.
+	path := filepath.Join(t.TempDir(), "audit.log")
+	srv, h, _ := auditedServer(t, path)
+	srv.store.mu.Lock()
+	first := srv.store.createLocked(Task{Title: "x"})
+	srv.store.mu.Unlock()
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"jsonrpc":"2.0","method":"tasks.update","params":{"id":1,"title":"t%d"},"id":1}`, i)
+			r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+	}
+	wg.Wait()
+
+	next := map[string]string{}
+	var rev int64
+	for _, e := range readAudit(t, path) {
+		// El registro sigue el orden de las revisiones.
+		if e.Revision <= rev {
+			t.Errorf("entrada %d: revisión %d tras la %d", e.Seq, e.Revision, rev)
+		}
+		rev = e.Revision
+		if _, dup := next[e.Before]; dup || e.TaskID != 1 {
+			t.Fatalf("entrada %d: tarea %d, before %q repetido o ajeno", e.Seq, e.TaskID, e.Before)
+		}
+		next[e.Before] = e.After
+	}
+	h0 := taskHash(&first)
+	for range n {
+		after, ok := next[h0]
+		if !ok {
+			t.Fatalf("la cadena de estados se corta en %q", h0)
+		}
+		h0 = after
+	}
+	srv.store.mu.RLock()
+	last, _ := srv.store.getLocked(1)
+	srv.store.mu.RUnlock()
+	if h0 != taskHash(&last) {
+		t.Error("la cadena no acaba en el estado final de la tarea")
+	}
+}
+
+func TestAuditAppendFailure(t *testing.T) {
+	// Si no se puede escribir en el registro, la petición falla aunque el
+	// handler haya respondido bien.
+	path := filepath.Join(t.TempDir(), "audit.log")
+	_, h, a := auditedServer(t, path)
+	a.f.Close()
+
+	for _, tt := range []struct{ method, path, body string }{
+		{http.MethodPost, "/tasks", `{"title":"a"}`},
+		{http.MethodPost, "/tasks", `{"title":""}`},
+	} {
+		w := do(t, h, tt.method, tt.path, tt.body)
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Location") != "" {
+			t.Errorf("%s %s %s: status %d Location %q; want 500 sin Location", tt.method, tt.path, tt.body, w.Code, w.Header().Get("Location"))
+		}
+		var p problem
+		_ = json.NewDecoder(w.Body).Decode(&p)
+		if p.Code != codeAuditFailed {
+			t.Errorf("%s %s %s: code %q; want %q", tt.method, tt.path, tt.body, p.Code, codeAuditFailed)
+		}
+	}
+}
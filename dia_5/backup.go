@@ -0,0 +1,511 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Copias de seguridad:
+//   - GET /admin/backup descarga un snapshot consistente (tareas y
+//     contadores) junto con el contenido de los adjuntos, como un tar que
+//     se escribe según se lee del disco.
+//   - POST /admin/restore valida un archivo y reemplaza el almacén de una
+//     vez; nunca queda a medias. Los adjuntos van directos del cuerpo de
+//     la petición al blobStore, comprobando su hash mientras se copian.
+//
+// Ambos exigen "Authorization: Bearer <admin_token>".
+
+const (
+	// backupFormat identifica el tipo de archivo.
+	backupFormat = "tasks-backup"
+	// backupSchemaVersion es la versión del formato que escribe este
+	// servidor; se aceptan archivos de esta versión o anteriores. La 2
+	// añade los blobs y la 3 pasa de un JSON a un tar.
+	backupSchemaVersion = 3
+	// maxBackupSize limita el cuerpo de /admin/restore. No se guarda en
+	// memoria, pero los adjuntos ocupan disco antes de validar el resto.
+	maxBackupSize = 1 << 30
+	// maxBackupDataSize limita lo que sí se lee entero en memoria: el
+	// data.json del tar y las copias JSON de las versiones 1 y 2.
+	maxBackupDataSize = 64 << 20
+)
+
+// Entradas del tar, en este orden: el manifiesto, el snapshot y un
+// fichero por blob con su SHA-256 como nombre.
+const (
+	backupManifestName = "manifest.json"
+	backupDataName     = "data.json"
+	backupBlobPrefix   = "blobs/"
+)
+
+// adminToken protege /admin/*; vacío las desactiva. Se configura con la
+// opción admin_token.
+var adminToken string
+
+// backupManifest describe el archivo. Checksum es el SHA-256 de los bytes
+// exactos de data.json; los blobs no lo necesitan porque su nombre ya es
+// el SHA-256 de su contenido.
+type backupManifest struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Checksum      string    `json:"checksum"` // "sha256:<hex>"
+}
+
+// legacyBackup es el archivo JSON de las versiones 1 y 2: el manifiesto
+// con el snapshot y los blobs dentro.
+type legacyBackup struct {
+	backupManifest
+	Data  json.RawMessage   `json:"data"`            // un snapshot
+	Blobs map[string][]byte `json:"blobs,omitempty"` // contenido de los adjuntos por hash
+}
+
+// backupError es un archivo rechazado, con el status y code de la respuesta.
+type backupError struct {
+	status int
+	code   string
+	args   []any
+}
+
+func (e *backupError) Error() string { return localize(defaultLang, e.code, e.args...).Detail }
+
+// invalidBackup crea el error de un archivo mal formado.
+func invalidBackup(format string, args ...any) *backupError {
+	return &backupError{http.StatusBadRequest, codeInvalidBackup, []any{fmt.Sprintf(format, args...)}}
+}
+
+// readError traduce un error al leer el cuerpo: demasiado grande o
+// cortado son culpa del archivo; el resto (el disco) no.
+func readError(err error) error {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return &backupError{http.StatusRequestEntityTooLarge, codeBodyTooLarge, []any{tooLarge.Limit}}
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, tar.ErrHeader):
+		return invalidBackup("archivo incompleto o dañado: %v", err)
+	}
+	return err
+}
+
+// checksum devuelve el checksum de data en el formato de backupManifest.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeBackup escribe snap como archivo de copia de seguridad, con el
+// contenido de sus adjuntos que encuentre open. Los que falten no se
+// incluyen; la restauración los echará en falta.
+func writeBackup(w io.Writer, snap snapshot, open func(sum string) (*os.File, error), now time.Time) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(backupManifest{
+		Format:        backupFormat,
+		SchemaVersion: backupSchemaVersion,
+		CreatedAt:     now.UTC(),
+		Checksum:      checksum(data),
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{{backupManifestName, manifest}, {backupDataName, data}} {
+		hdr := &tar.Header{Name: e.name, Mode: 0o600, Size: int64(len(e.data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	if open != nil {
+		for _, sum := range snapshotBlobs(snap) {
+			if err := writeBackupBlob(tw, sum, open); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// writeBackupBlob añade al tar el blob sum, si existe.
+func writeBackupBlob(tw *tar.Writer, sum string, open func(string) (*os.File, error)) error {
+	f, err := open(sum)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("copia de seguridad: falta un blob", "sha256", sum)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: backupBlobPrefix + sum, Mode: 0o600, Size: fi.Size(), ModTime: fi.ModTime()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// checkManifest comprueba el formato y la versión del archivo.
+func checkManifest(m backupManifest) error {
+	if m.Format != backupFormat {
+		return invalidBackup("format %q desconocido", m.Format)
+	}
+	if m.SchemaVersion < 1 {
+		return invalidBackup("schema_version %d inválida", m.SchemaVersion)
+	}
+	if m.SchemaVersion > backupSchemaVersion {
+		return &backupError{http.StatusUnprocessableEntity, codeBackupTooNew, []any{m.SchemaVersion, backupSchemaVersion}}
+	}
+	return nil
+}
+
+// decodeSnapshot comprueba el checksum de data y lo decodifica y valida.
+func decodeSnapshot(data []byte, want string) (snapshot, error) {
+	if subtle.ConstantTimeCompare([]byte(checksum(data)), []byte(want)) != 1 {
+		return snapshot{}, &backupError{http.StatusUnprocessableEntity, codeBackupChecksum, nil}
+	}
+	var snap snapshot
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&snap); err != nil {
+		return snapshot{}, invalidBackup("data: %v", err)
+	}
+	if err := validateSnapshot(snap); err != nil {
+		return snapshot{}, err
+	}
+	return snap, nil
+}
+
+// readBackup lee y valida un archivo de copia de seguridad, tar o JSON de
+// las versiones anteriores, y devuelve su snapshot. Los blobs que usa se
+// guardan en srv.blobs según llegan; si al final el archivo se rechaza,
+// gc se llevará los que sobren. Los errores del archivo son *backupError.
+func (srv *server) readBackup(r io.Reader) (snapshot, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return snapshot{}, invalidBackup("archivo vacío")
+		}
+		if err != nil {
+			return snapshot{}, readError(err)
+		}
+		if b[0] == '{' {
+			return srv.readLegacyBackup(br)
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			break
+		}
+		br.ReadByte()
+	}
+
+	tr := tar.NewReader(br)
+	next := func(name string) ([]byte, error) {
+		hdr, err := tr.Next()
+		if err == io.EOF || err == nil && hdr.Name != name {
+			return nil, invalidBackup("falta %s al principio del archivo", name)
+		}
+		if err != nil {
+			return nil, readError(err)
+		}
+		if hdr.Size > maxBackupDataSize {
+			return nil, invalidBackup("%s demasiado grande", name)
+		}
+		data, err := io.ReadAll(tr)
+		return data, readError(err)
+	}
+	data, err := next(backupManifestName)
+	if err != nil {
+		return snapshot{}, err
+	}
+	var m backupManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return snapshot{}, invalidBackup("%s: %v", backupManifestName, err)
+	}
+	if err := checkManifest(m); err != nil {
+		return snapshot{}, err
+	}
+	if data, err = next(backupDataName); err != nil {
+		return snapshot{}, err
+	}
+	snap, err := decodeSnapshot(data, m.Checksum)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	used := make(map[string]bool)
+	for _, sum := range snapshotBlobs(snap) {
+		used[sum] = true
+	}
+	received := make(map[string]bool)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return snapshot{}, readError(err)
+		}
+		sum, ok := strings.CutPrefix(hdr.Name, backupBlobPrefix)
+		if !ok || !isHex(sum, sha256.Size*2) {
+			return snapshot{}, invalidBackup("entrada %q desconocida", hdr.Name)
+		}
+		if !used[sum] || srv.blobs == nil {
+			continue // tar.Reader se salta el contenido
+		}
+		info, err := srv.blobs.put(tr, hdr.Size)
+		if err != nil {
+			return snapshot{}, readError(err)
+		}
+		if info.SHA256 != sum {
+			return snapshot{}, invalidBackup("el contenido del blob %q no coincide con su hash", sum)
+		}
+		received[sum] = true
+	}
+	return snap, srv.checkBlobs(snap, received)
+}
+
+// readLegacyBackup lee un archivo JSON de las versiones 1 y 2.
+func (srv *server) readLegacyBackup(r io.Reader) (snapshot, error) {
+	lr := &io.LimitedReader{R: r, N: maxBackupDataSize + 1}
+	var a legacyBackup
+	if err := json.NewDecoder(lr).Decode(&a); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case lr.N <= 0:
+			return snapshot{}, &backupError{http.StatusRequestEntityTooLarge, codeBodyTooLarge, []any{maxBackupDataSize}}
+		case errors.As(err, &tooLarge):
+			return snapshot{}, readError(err)
+		}
+		return snapshot{}, invalidBackup("%v", err)
+	}
+	if err := checkManifest(a.backupManifest); err != nil {
+		return snapshot{}, err
+	}
+	snap, err := decodeSnapshot(a.Data, a.Checksum)
+	if err != nil {
+		return snapshot{}, err
+	}
+	for sum, data := range a.Blobs {
+		got := sha256.Sum256(data)
+		if hex.EncodeToString(got[:]) != sum {
+			return snapshot{}, invalidBackup("el contenido del blob %q no coincide con su hash", sum)
+		}
+	}
+	received := make(map[string]bool)
+	for _, sum := range snapshotBlobs(snap) {
+		if data, ok := a.Blobs[sum]; ok && srv.blobs != nil {
+			if _, err := srv.blobs.put(bytes.NewReader(data), int64(len(data))); err != nil {
+				return snapshot{}, err
+			}
+			received[sum] = true
+		}
+	}
+	return snap, srv.checkBlobs(snap, received)
+}
+
+// checkBlobs comprueba que no falte ningún blob de snap: los que no trae
+// el archivo (received) tienen que estar ya en este servidor.
+func (srv *server) checkBlobs(snap snapshot, received map[string]bool) error {
+	for _, sum := range snapshotBlobs(snap) {
+		if received[sum] {
+			continue
+		}
+		if srv.blobs == nil {
+			return &backupError{http.StatusUnprocessableEntity, codeBackupMissingBlob, []any{sum}}
+		}
+		f, err := srv.blobs.open(sum)
+		if err != nil {
+			return &backupError{http.StatusUnprocessableEntity, codeBackupMissingBlob, []any{sum}}
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// validateSnapshot comprueba que los contadores y los IDs sean coherentes
+// y que cada tarea esté en una columna de su proyecto.
+func validateSnapshot(snap snapshot) error {
+	if snap.Revision < 0 {
+		return invalidBackup("revision %d negativa", snap.Revision)
+	}
+	seen := make(map[int]bool, len(snap.Tasks))
+	for _, t := range snap.Tasks {
+		switch {
+		case t.ID < 1:
+			return invalidBackup("tarea con id %d inválido", t.ID)
+		case seen[t.ID]:
+			return invalidBackup("id %d repetido", t.ID)
+		case t.ID >= snap.NextID:
+			return invalidBackup("next_id %d no es mayor que el id %d", snap.NextID, t.ID)
+		}
+		seen[t.ID] = true
+	}
+	if snap.NextID < 1 {
+		return invalidBackup("next_id %d inválido", snap.NextID)
+	}
+	projects := make(map[int]Project, len(snap.Projects))
+	for _, p := range snap.Projects {
+		_, dup := projects[p.ID]
+		switch {
+		case p.ID < 1:
+			return invalidBackup("proyecto con id %d inválido", p.ID)
+		case dup:
+			return invalidBackup("id de proyecto %d repetido", p.ID)
+		case p.ID >= snap.NextProjectID:
+			return invalidBackup("next_project_id %d no es mayor que el id %d", snap.NextProjectID, p.ID)
+		case len(p.Columns) == 0:
+			return invalidBackup("proyecto %d sin columnas", p.ID)
+		}
+		projects[p.ID] = p
+	}
+	for _, t := range snap.Tasks {
+		if t.ProjectID == 0 {
+			if t.Column != "" {
+				return invalidBackup("la tarea %d está en la columna %q sin proyecto", t.ID, t.Column)
+			}
+			continue
+		}
+		p, ok := projects[t.ProjectID]
+		if !ok {
+			return invalidBackup("la tarea %d es de un proyecto %d que no existe", t.ID, t.ProjectID)
+		}
+		if _, ok := p.column(t.Column); !ok {
+			return invalidBackup("la tarea %d está en la columna %q, que no existe en el proyecto %d", t.ID, t.Column, p.ID)
+		}
+	}
+	return nil
+}
+
+// snapshotBlobs devuelve, ordenados, los hashes que usan las tareas de snap.
+func snapshotBlobs(snap snapshot) []string {
+	used := make(map[string]bool)
+	for _, t := range snap.Tasks {
+		for _, a := range t.Attachments {
+			used[a.SHA256] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(used))
+}
+
+// checkAdmin comprueba el token de administración. Si falla ya ha
+// respondido con 401.
+func checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	return checkBearer(w, r, "admin", codeAdminUnauthorized, adminToken)
+}
+
+// checkBearer comprueba que la petición traiga uno de los tokens (los
+// vacíos no cuentan). Si no, responde 401 con code y devuelve false.
+func checkBearer(w http.ResponseWriter, r *http.Request, realm, code string, tokens ...string) bool {
+	if got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, want := range tokens {
+			if want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+				return true
+			}
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+	writeError(w, r, http.StatusUnauthorized, code)
+	return false
+}
+
+// backupHandler maneja GET /admin/backup.
+func (srv *server) backupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	if !checkAdmin(w, r) {
+		return
+	}
+
+	// Copia con el lock tomado; se escribe después sin bloquear a nadie.
+	// Los blobs no cambian nunca (su nombre es su hash) y gc no borra los
+	// recientes, así que se pueden leer ya sin el lock.
+	srv.store.mu.RLock()
+	snap := srv.store.snapshotLocked()
+	srv.store.mu.RUnlock()
+	var open func(string) (*os.File, error)
+	if srv.blobs != nil {
+		open = func(sum string) (*os.File, error) { return srv.openBlob(r.Context(), sum) }
+	}
+
+	now := time.Now()
+	name := "tasks-backup-" + now.UTC().Format("20060102T150405Z") + ".tar"
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if err := writeBackup(w, snap, open, now); err != nil {
+		// Ya se envió parte: se corta la conexión para que el cliente no
+		// tome por buena una copia incompleta.
+		slog.Error("copia de seguridad", "err", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// restoreHandler maneja POST /admin/restore con un archivo de
+// /admin/backup como cuerpo.
+func (srv *server) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "POST")
+		return
+	}
+	if !checkAdmin(w, r) {
+		return
+	}
+
+	snap, err := srv.readBackup(http.MaxBytesReader(w, r.Body, maxBackupSize))
+	var be *backupError
+	if errors.As(err, &be) {
+		writeError(w, r, be.status, be.code, be.args...)
+		return
+	}
+	if err != nil { // solo del disco de blobs
+		slog.Error("restauración", "err", err)
+		writeError(w, r, http.StatusInternalServerError, codeInternalError)
+		return
+	}
+
+	st := srv.store
+	st.lockFor(r.Context())
+	// La revisión sigue creciendo aunque el archivo sea antiguo: así los
+	// clientes de /tasks/changes y las réplicas ven que todo cambió (410)
+	// y vuelven a leer el estado completo.
+	snap.Revision = max(snap.Revision, st.revision) + 1
+	st.replaceLocked(snap)
+	st.unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"restored": len(snap.Tasks),
+		"next_id":  snap.NextID,
+		"revision": snap.Revision,
+	})
+}
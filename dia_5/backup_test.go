@@ -0,0 +1,263 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withAdminToken configura adminToken durante un test.
+func withAdminToken(t *testing.T, token string) {
+	t.Helper()
+	saved := adminToken
+	adminToken = token
+	t.Cleanup(func() { adminToken = saved })
+}
+
+// adminRequest crea una petición con el token de administración.
+func adminRequest(method, target string, body []byte) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secreto")
+	return r
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	withAdminToken(t, "secreto")
+	due := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	src := newServer(newTaskStore())
+	src.store.mu.Lock()
+	src.store.createLocked(Task{Title: "uno", CreatedAt: due})
+	src.store.createLocked(Task{Title: "dos", Done: true, CreatedAt: due, DueDate: &due})
+	src.store.removeLocked(1)
+	want := src.store.snapshotLocked()
+	src.store.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	src.backupHandler(w, adminRequest(http.MethodGet, "/admin/backup", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("backup: status = %d; want 200", w.Code)
+	}
+	archive := w.Body.Bytes()
+
+	// Restaurar en otro servidor con contenido previo distinto.
+	dst := newServer(newTaskStore())
+	dst.store.mu.Lock()
+	for range 5 {
+		dst.store.createLocked(Task{Title: "viejo"})
+	}
+	dst.store.mu.Unlock()
+
+	w = httptest.NewRecorder()
+	dst.restoreHandler(w, adminRequest(http.MethodPost, "/admin/restore", archive))
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: status = %d; want 200 (%s)", w.Code, w.Body)
+	}
+
+	dst.store.mu.Lock()
+	got := dst.store.snapshotLocked()
+	dst.store.mu.Unlock()
+	if !reflect.DeepEqual(got.Tasks, want.Tasks) || got.NextID != want.NextID {
+		t.Errorf("restaurado = %+v; want %+v", got, want)
+	}
+	// La revisión nunca retrocede: 5 cambios previos + la restauración.
+	if got.Revision != 6 {
+		t.Errorf("revision = %d; want 6", got.Revision)
+	}
+}
+
+// tarEntry es una entrada de un archivo armado a mano.
+type tarEntry struct {
+	name string
+	data []byte
+}
+
+// tarArchive arma un archivo tar con entries, en orden.
+func tarArchive(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o600, Size: int64(len(e.data))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(e.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// manifestEntry es la entrada del manifiesto para data, después de
+// pasarlo por edit.
+func manifestEntry(data string, edit func(m *backupManifest)) tarEntry {
+	m := backupManifest{Format: backupFormat, SchemaVersion: backupSchemaVersion, Checksum: checksum([]byte(data))}
+	if edit != nil {
+		edit(&m)
+	}
+	b, _ := json.Marshal(m)
+	return tarEntry{backupManifestName, b}
+}
+
+func TestRestoreRejects(t *testing.T) {
+	withAdminToken(t, "secreto")
+	var good bytes.Buffer
+	if err := writeBackup(&good, snapshot{NextID: 2, Revision: 1, Tasks: []Task{{ID: 1, Title: "a"}}}, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	data := `{"next_id":2,"revision":1,"tasks":[{"id":1,"title":"a"}]}`
+	// withManifest arma un archivo con data y el manifiesto retocado.
+	withManifest := func(edit func(m *backupManifest)) []byte {
+		return tarArchive(t, manifestEntry(data, edit), tarEntry{backupDataName, []byte(data)})
+	}
+	// withData arma un archivo válido salvo por data.
+	withData := func(data string, blobs ...tarEntry) []byte {
+		entries := append([]tarEntry{manifestEntry(data, nil), {backupDataName, []byte(data)}}, blobs...)
+		return tarArchive(t, entries...)
+	}
+	project := `"projects":[{"id":1,"name":"Web","columns":[{"name":"todo"}]}]`
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("hola")))
+	withBlob := `{"next_id":2,"revision":1,"tasks":[{"id":1,"attachments":[{"id":1,"sha256":"` + sum + `"}]}]}`
+	legacy := func(a legacyBackup) []byte {
+		b, _ := json.Marshal(a)
+		return b
+	}
+
+	tests := []struct {
+		name       string
+		body       []byte
+		token      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"sin token", good.Bytes(), "", http.StatusUnauthorized, codeAdminUnauthorized},
+		{"token incorrecto", good.Bytes(), "otro", http.StatusUnauthorized, codeAdminUnauthorized},
+		{"vacío", nil, "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"no es un archivo", []byte("hola"), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"cortado", good.Bytes()[:1600], "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"sin manifiesto", tarArchive(t, tarEntry{backupDataName, []byte(data)}), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"sin data", tarArchive(t, manifestEntry(data, nil)), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"entrada desconocida", withData(data, tarEntry{"otra.txt", []byte("x")}), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"otro formato", withManifest(func(m *backupManifest) { m.Format = "zip" }), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"versión más nueva", withManifest(func(m *backupManifest) { m.SchemaVersion = backupSchemaVersion + 1 }), "secreto", http.StatusUnprocessableEntity, codeBackupTooNew},
+		{"checksum alterado", withManifest(func(m *backupManifest) { m.Checksum = checksum([]byte("x")) }), "secreto", http.StatusUnprocessableEntity, codeBackupChecksum},
+		{"ids repetidos", withData(`{"next_id":3,"revision":2,"tasks":[{"id":1},{"id":1}]}`), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"next_id pequeño", withData(`{"next_id":1,"revision":1,"tasks":[{"id":1}]}`), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"columna inexistente", withData(`{"next_id":2,"next_project_id":2,"revision":1,"tasks":[{"id":1,"project_id":1,"column":"hecho"}],` + project + `}`), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"columna sin proyecto", withData(`{"next_id":2,"revision":1,"tasks":[{"id":1,"column":"todo"}]}`), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"blob alterado", withData(withBlob, tarEntry{backupBlobPrefix + sum, []byte("otro")}), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"falta un blob", withData(withBlob), "secreto", http.StatusUnprocessableEntity, codeBackupMissingBlob},
+		{"JSON inválido (versión 2)", []byte("{hola"), "secreto", http.StatusBadRequest, codeInvalidBackup},
+		{"checksum alterado (versión 2)", legacy(legacyBackup{backupManifest: backupManifest{Format: backupFormat, SchemaVersion: 2, Checksum: checksum([]byte("x"))}, Data: json.RawMessage(data)}), "secreto", http.StatusUnprocessableEntity, codeBackupChecksum},
+		{"blob alterado (versión 2)", legacy(legacyBackup{backupManifest: backupManifest{Format: backupFormat, SchemaVersion: 2, Checksum: checksum([]byte(withBlob))}, Data: json.RawMessage(withBlob), Blobs: map[string][]byte{sum: []byte("otro")}}), "secreto", http.StatusBadRequest, codeInvalidBackup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(newTaskStore())
+			srv.blobs = newBlobStore(t.TempDir())
+			r := httptest.NewRequest(http.MethodPost, "/admin/restore", bytes.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			srv.restoreHandler(w, r)
+
+			var p problem
+			_ = json.NewDecoder(w.Body).Decode(&p)
+			if w.Code != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("got %d %q (%s); want %d %q", w.Code, p.Code, p.Detail, tt.wantStatus, tt.wantCode)
+			}
+			srv.store.mu.Lock()
+			n := len(srv.store.tasks)
+			srv.store.mu.Unlock()
+			if n != 0 {
+				t.Errorf("el almacén cambió tras un restore rechazado")
+			}
+		})
+	}
+}
+
+func TestRestoreLegacyJSON(t *testing.T) {
+	// Las copias JSON de la versión 2, con los blobs en base64, se siguen
+	// aceptando.
+	withAdminToken(t, "secreto")
+	content := []byte("contenido del adjunto")
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+	data := `{"next_id":2,"next_attachment_id":2,"revision":3,"tasks":[{"id":1,"title":"a","attachments":[{"id":1,"name":"a.txt","sha256":"` + sum + `","content_type":"text/plain"}]}]}`
+	body, _ := json.Marshal(legacyBackup{
+		backupManifest: backupManifest{Format: backupFormat, SchemaVersion: 2, Checksum: checksum([]byte(data))},
+		Data:           json.RawMessage(data),
+		Blobs:          map[string][]byte{sum: content},
+	})
+
+	dst := newServer(newTaskStore())
+	dst.blobs = newBlobStore(t.TempDir())
+	w := httptest.NewRecorder()
+	dst.restoreHandler(w, adminRequest(http.MethodPost, "/admin/restore", append([]byte("\n "), body...)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: status = %d; want 200 (%s)", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	dst.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/1/attachments/1", nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("descarga tras restaurar: %d %q; want 200 %q", w.Code, w.Body, content)
+	}
+}
+
+func TestBackupRestoreBlobs(t *testing.T) {
+	withAdminToken(t, "secreto")
+	src := newAttachmentServer(t)
+	content := []byte("contenido del adjunto")
+	if w := upload(t, src.routes(), "1", "file", "notas.txt", content); w.Code != http.StatusCreated {
+		t.Fatalf("upload: status = %d", w.Code)
+	}
+	w := httptest.NewRecorder()
+	src.backupHandler(w, adminRequest(http.MethodGet, "/admin/backup", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("backup: status = %d; want 200", w.Code)
+	}
+	archive := w.Body.Bytes()
+
+	// Otro servidor con su propio directorio de blobs, vacío.
+	dst := newServer(newTaskStore())
+	dst.blobs = newBlobStore(t.TempDir())
+	w = httptest.NewRecorder()
+	dst.restoreHandler(w, adminRequest(http.MethodPost, "/admin/restore", archive))
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: status = %d; want 200 (%s)", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	dst.routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/1/attachments/1", nil))
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("descarga tras restaurar: %d %q; want 200 %q", w.Code, w.Body, content)
+	}
+
+	// Sin adjuntos activados no se puede restaurar una copia que los usa.
+	off := newServer(newTaskStore())
+	w = httptest.NewRecorder()
+	off.restoreHandler(w, adminRequest(http.MethodPost, "/admin/restore", archive))
+	var p problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusUnprocessableEntity || p.Code != codeBackupMissingBlob {
+		t.Errorf("sin blob_dir: got %d %q; want 422 %q", w.Code, p.Code, codeBackupMissingBlob)
+	}
+}
+
+func TestBackupRequiresAdminToken(t *testing.T) {
+	withAdminToken(t, "")
+	w := httptest.NewRecorder()
+	newServer(newTaskStore()).backupHandler(w, adminRequest(http.MethodGet, "/admin/backup", nil))
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+		t.Errorf("got %d %q; want 401 Bearer", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchServer devuelve un servidor con n tareas (las 100 primeras en un
+// proyecto) y su handler.
+func benchServer(b *testing.B, n int) (*server, http.Handler) {
+	b.Helper()
+	srv := newServer(newTaskStore())
+	st := srv.store
+	st.mu.Lock()
+	p := st.createProjectLocked(Project{Name: "bench", Columns: defaultColumns})
+	due := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i := range n {
+		t := Task{Title: fmt.Sprintf("tarea %d de prueba con go", i%1000), CreatedAt: due, DueDate: &due}
+		if i < 100 {
+			_ = st.placeNewLocked(&t, p.ID, "")
+		}
+		st.createLocked(t)
+	}
+	st.mu.Unlock()
+	return srv, srv.routes()
+}
+
+// handlerBenchmarks son las peticiones que se miden, una por handler.
+var handlerBenchmarks = []struct {
+	name, method, path, body string
+}{
+	{"ping", http.MethodGet, "/ping", ""},
+	{"list", http.MethodGet, "/tasks", ""},
+	{"get", http.MethodGet, "/tasks/500", ""},
+	{"create", http.MethodPost, "/tasks", `{"title":"nueva"}`},
+	{"search", http.MethodGet, "/search?q=go", ""},
+	{"calendar", http.MethodGet, "/tasks/calendar.ics", ""},
+	{"changes", http.MethodGet, "/tasks/changes?since=1000&wait=0s", ""},
+	{"project", http.MethodGet, "/projects/1", ""},
+	{"move", http.MethodPost, "/tasks/1/move", `{"position":1}`},
+	{"rpc_get", http.MethodPost, "/rpc", `{"jsonrpc":"2.0","method":"tasks.get","params":{"id":500},"id":1}`},
+	{"ui", http.MethodGet, "/", ""},
+}
+
+func BenchmarkHandlers(b *testing.B) {
+	for _, bb := range handlerBenchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			_, h := benchServer(b, 1000)
+			body := []byte(bb.body)
+			b.ReportAllocs()
+			for b.Loop() {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(bb.method, bb.path, bytes.NewReader(body)))
+				if w.Code >= 400 {
+					b.Fatalf("%s %s: status %d", bb.method, bb.path, w.Code)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkHandlersParallel mide list, get y create con peticiones
+// concurrentes, donde se nota la contención del mutex del almacén.
+func BenchmarkHandlersParallel(b *testing.B) {
+	for _, bb := range handlerBenchmarks[1:4] {
+		b.Run(bb.name, func(b *testing.B) {
+			_, h := benchServer(b, 1000)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, httptest.NewRequest(bb.method, bb.path, strings.NewReader(bb.body)))
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkLoadMix mide la mezcla por defecto del generador de carga en
+// proceso, sin ritmo fijo.
+func BenchmarkLoadMix(b *testing.B) {
+	mix, _ := parseMix("list=20,get=70,create=10")
+	lr := newLoadRunner(loadOptions{Mix: mix})
+	for range 1000 {
+		lr.do(context.Background(), "create")
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lr.do(context.Background(), lr.pick())
+		}
+	})
+}
+
+// BenchmarkLookup100k compara la búsqueda por ID en el índice con el
+// recorrido lineal del slice que se usaba antes.
+func BenchmarkLookup100k(b *testing.B) {
+	srv, _ := benchServer(b, 100_000)
+	st := srv.store
+	st.mu.RLock()
+	tasks := st.listLocked()
+	st.mu.RUnlock()
+
+	b.Run("index", func(b *testing.B) {
+		n := 0
+		for b.Loop() {
+			n++
+			id := n*7919%100_000 + 1 // IDs repartidos por todo el rango
+			st.mu.RLock()
+			_, ok := st.getLocked(id)
+			st.mu.RUnlock()
+			if !ok {
+				b.Fatalf("tarea %d no encontrada", id)
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		n := 0
+		for b.Loop() {
+			n++
+			id := n*7919%100_000 + 1 // IDs repartidos por todo el rango
+			st.mu.RLock()
+			found := false
+			for _, t := range tasks {
+				if t.ID == id {
+					found = true
+					break
+				}
+			}
+			st.mu.RUnlock()
+			if !found {
+				b.Fatalf("tarea %d no encontrada", id)
+			}
+		}
+	})
+}
+
+// BenchmarkGetDuringList100k mide GET /tasks/{id} mientras otra goroutine
+// lista las 100k tareas sin parar. Con "outside" el JSON se codifica sin el
+// lock, como hace listTasks; con "inside" se codifica con el lock de
+// escritura tomado, como antes, y cada lectura espera a que termine.
+func BenchmarkGetDuringList100k(b *testing.B) {
+	for _, inside := range []bool{false, true} {
+		name := "outside"
+		if inside {
+			name = "inside"
+		}
+		b.Run(name, func(b *testing.B) {
+			srv, h := benchServer(b, 100_000)
+			st := srv.store
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if inside {
+						st.mu.Lock()
+						_ = json.NewEncoder(io.Discard).Encode(st.listLocked())
+						st.mu.Unlock()
+					} else {
+						h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tasks", nil))
+					}
+				}
+			}()
+			for b.Loop() {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/50000", nil))
+				if w.Code != http.StatusOK {
+					b.Fatalf("status %d", w.Code)
+				}
+			}
+			close(stop)
+			<-done
+		})
+	}
+}
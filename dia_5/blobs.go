@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Almacén de blobs direccionado por contenido: cada fichero se guarda con
+// el nombre de su SHA-256 (dir/ab/abcdef...), así dos subidas iguales
+// ocupan un solo fichero. Los blobs que ya no usa ninguna tarea se borran
+// con gc.
+
+// blobGCGrace es la edad mínima de un blob huérfano para borrarlo: una
+// subida escribe el blob antes de que la tarea lo referencie.
+const blobGCGrace = 10 * time.Minute
+
+// errBlobTooLarge indica que el contenido supera el límite de put.
+var errBlobTooLarge = errors.New("blob demasiado grande")
+
+// blobStore guarda blobs bajo dir.
+type blobStore struct {
+	dir string
+}
+
+// blobInfo describe un blob guardado.
+type blobInfo struct {
+	SHA256      string
+	Size        int64
+	ContentType string // detectado con http.DetectContentType
+}
+
+// newBlobStore crea un almacén en dir; el directorio se crea al guardar.
+func newBlobStore(dir string) *blobStore {
+	return &blobStore{dir: dir}
+}
+
+// path devuelve la ruta del blob con ese hash.
+func (b *blobStore) path(sum string) string {
+	return filepath.Join(b.dir, sum[:2], sum)
+}
+
+// put guarda el contenido de r (como mucho limit bytes) y devuelve su
+// hash, tamaño y tipo. Si ya existía un blob igual se reutiliza.
+func (b *blobStore) put(r io.Reader, limit int64) (blobInfo, error) {
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return blobInfo{}, err
+	}
+	tmp, err := os.CreateTemp(b.dir, "tmp-*")
+	if err != nil {
+		return blobInfo{}, err
+	}
+	defer os.Remove(tmp.Name()) // no-op si el rename tuvo éxito
+
+	// Se escribe al fichero temporal calculando el hash a la vez, y se
+	// guardan los primeros bytes para detectar el tipo.
+	h := sha256.New()
+	sniff := &prefixWriter{max: 512}
+	n, err := io.Copy(io.MultiWriter(tmp, h, sniff), io.LimitReader(r, limit+1))
+	if err == nil && n > limit {
+		err = errBlobTooLarge
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return blobInfo{}, err
+	}
+
+	info := blobInfo{
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		Size:        n,
+		ContentType: http.DetectContentType(sniff.buf),
+	}
+	dst := b.path(info.SHA256)
+	if _, err := os.Stat(dst); err == nil {
+		// Duplicado: se renueva la fecha para que gc no lo borre mientras
+		// la tarea todavía no lo referencia.
+		now := time.Now()
+		return info, os.Chtimes(dst, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return blobInfo{}, err
+	}
+	return info, os.Rename(tmp.Name(), dst)
+}
+
+// open abre el blob con ese hash.
+func (b *blobStore) open(sum string) (*os.File, error) {
+	if len(sum) != sha256.Size*2 {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(b.path(sum))
+}
+
+// gc borra los blobs que no están en used y los temporales abandonados,
+// siempre que tengan al menos grace de antigüedad. Devuelve cuántos borró.
+// Solo toca ficheros con la forma de un blob (dir/ab/abcdef...) o de un
+// temporal de put (dir/tmp-*): dir puede ser compartido con otros datos.
+func (b *blobStore) gc(used map[string]bool, grace time.Duration) (int, error) {
+	cutoff := time.Now().Add(-grace)
+	removed := 0
+	err := filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // todavía no se ha subido nada
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(b.dir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Solo se baja a los subdirectorios de dos cifras hex.
+			if rel != "." && (filepath.Dir(rel) != "." || !isHex(rel, 2)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		switch dir := filepath.Dir(rel); {
+		case dir == "." && strings.HasPrefix(name, "tmp-"):
+		case dir != "." && isHex(name, sha256.Size*2) && name[:2] == dir:
+			if used[name] {
+				return nil
+			}
+		default:
+			return nil // no es nuestro
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// isHex dice si s tiene n caracteres y todos son cifras hex en minúscula,
+// como las que escribe hex.EncodeToString.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixWriter guarda los primeros max bytes que recibe.
+type prefixWriter struct {
+	buf []byte
+	max int
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	if room := p.max - len(p.buf); room > 0 {
+		p.buf = append(p.buf, b[:min(room, len(b))]...)
+	}
+	return len(b), nil
+}
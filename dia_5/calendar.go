@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Feed iCalendar (RFC 5545) con las tareas que tienen fecha límite.
+// Cada tarea se publica como un VTODO con un UID estable derivado de su ID,
+// para que los clientes de calendario actualicen en lugar de duplicar.
+
+const (
+	icsTimeFormat = "20060102T150405Z"
+	icsLineLimit  = 75 // octetos por línea antes de plegar (RFC 5545 §3.1)
+	icsProdID     = "-//golang-101//dia_5 tasks//ES"
+)
+
+// calendarTokens asocia un token secreto con el usuario dueño de esa URL.
+// Se carga de la opción calendar_tokens ("usuario:token,usuario2:token2").
+// Si está vacío, el feed es público.
+var calendarTokens = map[string]string{}
+
+// parseCalendarTokens interpreta la lista "usuario:token" separada por comas.
+func parseCalendarTokens(s string) map[string]string {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		user, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && user != "" && token != "" {
+			tokens[token] = user
+		}
+	}
+	return tokens
+}
+
+// calendarUser devuelve el usuario asociado al token, comparando en tiempo
+// constante para no filtrar información por temporización.
+func calendarUser(token string) (string, bool) {
+	for t, user := range calendarTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+// calendarHandler maneja /tasks/calendar.ics[?token=...].
+func (srv *server) calendarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+
+	// Con tokens, cada URL es de un usuario y solo lleva sus tareas.
+	name, user := "Tareas", ""
+	if len(calendarTokens) > 0 {
+		var ok bool
+		user, ok = calendarUser(r.URL.Query().Get("token"))
+		if !ok {
+			writeError(w, r, http.StatusNotFound, codeCalendarNotFound)
+			return
+		}
+		name += " de " + user
+	}
+
+	// Copiar las tareas con fecha límite para no escribir con el lock tomado.
+	srv.store.mu.RLock()
+	due := make([]Task, 0, len(srv.store.tasks))
+	for t := range srv.store.allLocked() {
+		if t.DueDate != nil && (user == "" || t.Owner == user) {
+			due = append(due, t)
+		}
+	}
+	srv.store.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	_ = writeCalendar(w, name, due, time.Now())
+}
+
+// writeCalendar escribe un VCALENDAR con un VTODO por tarea. now es la hora
+// de generación, que va en DTSTAMP; LAST-MODIFIED es la del último cambio
+// de cada tarea.
+func writeCalendar(out io.Writer, name string, ts []Task, now time.Time) error {
+	stamp := now.UTC().Format(icsTimeFormat)
+	w := bufio.NewWriter(out)
+	line := func(name, value string) {
+		writeICSLine(w, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", icsProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("X-WR-CALNAME", escapeICSText(name))
+	for _, t := range ts {
+		status := "NEEDS-ACTION"
+		if t.Done {
+			status = "COMPLETED"
+		}
+		line("BEGIN", "VTODO")
+		line("UID", taskUID(t.ID))
+		modified := t.UpdatedAt
+		if modified.IsZero() {
+			modified = t.CreatedAt
+		}
+		line("DTSTAMP", stamp)
+		line("CREATED", t.CreatedAt.UTC().Format(icsTimeFormat))
+		line("LAST-MODIFIED", modified.UTC().Format(icsTimeFormat))
+		line("SUMMARY", escapeICSText(t.Title))
+		if t.DueDate != nil {
+			line("DUE", t.DueDate.UTC().Format(icsTimeFormat))
+		}
+		line("STATUS", status)
+		line("END", "VTODO")
+	}
+	line("END", "VCALENDAR")
+	return w.Flush()
+}
+
+// taskUID genera un UID estable y globalmente único para la tarea.
+func taskUID(id int) string {
+	return "task-" + strconv.Itoa(id) + "@golang-101.dia5"
+}
+
+// escapeICSText escapa un valor TEXT según RFC 5545 §3.3.11.
+func escapeICSText(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// writeICSLine escribe una línea de contenido terminada en CRLF, plegándola
+// cada 75 octetos sin partir caracteres UTF-8. Las líneas de continuación
+// empiezan con un espacio, que cuenta dentro del límite.
+func writeICSLine(w *bufio.Writer, s string) {
+	limit := icsLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = icsLineLimit - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// icsComponent es un componente iCalendar parseado (propiedad -> valor).
+type icsComponent map[string]string
+
+// parseICS es un parser mínimo de RFC 5545: despliega las líneas, separa
+// nombre y valor y desescapa TEXT. Devuelve las propiedades del VCALENDAR y
+// los VTODO encontrados.
+func parseICS(t *testing.T, data string) (icsComponent, []icsComponent) {
+	t.Helper()
+	if !strings.HasSuffix(data, "\r\n") {
+		t.Fatalf("el feed debe terminar en CRLF")
+	}
+
+	// Desplegar: CRLF seguido de espacio continúa la línea anterior.
+	var lines []string
+	for _, l := range strings.Split(strings.TrimSuffix(data, "\r\n"), "\r\n") {
+		if len(l) > icsLineLimit {
+			t.Errorf("línea de %d octetos sin plegar: %q", len(l), l)
+		}
+		if strings.HasPrefix(l, " ") {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	cal := icsComponent{}
+	var todos []icsComponent
+	var cur icsComponent
+	unescape := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	for _, l := range lines {
+		name, value, ok := strings.Cut(l, ":")
+		if !ok {
+			t.Fatalf("línea sin ':': %q", l)
+		}
+		switch {
+		case name == "BEGIN" && value == "VTODO":
+			cur = icsComponent{}
+		case name == "END" && value == "VTODO":
+			todos = append(todos, cur)
+			cur = nil
+		case cur != nil:
+			cur[name] = unescape.Replace(value)
+		default:
+			cal[name] = unescape.Replace(value)
+		}
+	}
+	return cal, todos
+}
+
+func TestWriteCalendarRoundTrip(t *testing.T) {
+	created := time.Date(2025, 9, 4, 15, 0, 0, 0, time.UTC)
+	updated := time.Date(2025, 9, 5, 8, 0, 0, 0, time.UTC)
+	due := time.Date(2025, 9, 10, 9, 30, 0, 0, time.UTC)
+	now := time.Date(2025, 9, 6, 12, 0, 0, 0, time.UTC)
+	input := []Task{
+		{ID: 1, Title: "Aprender Go", CreatedAt: created, UpdatedAt: updated, DueDate: &due},
+		{ID: 2, Title: "Comprar pan, leche; y \\ huevos\nsin olvidar nada", Done: true, CreatedAt: created, DueDate: &due},
+		{ID: 3, Title: strings.Repeat("canción larga ñ ", 20), CreatedAt: created, DueDate: &due},
+	}
+
+	var buf bytes.Buffer
+	if err := writeCalendar(&buf, "Tareas", input, now); err != nil {
+		t.Fatal(err)
+	}
+	cal, todos := parseICS(t, buf.String())
+
+	if cal["VERSION"] != "2.0" || cal["PRODID"] == "" {
+		t.Errorf("cabecera VCALENDAR incompleta: %v", cal)
+	}
+	if len(todos) != len(input) {
+		t.Fatalf("got %d VTODO; want %d", len(todos), len(input))
+	}
+	for i, tt := range input {
+		got := todos[i]
+		if got["UID"] != taskUID(tt.ID) {
+			t.Errorf("UID = %q; want %q", got["UID"], taskUID(tt.ID))
+		}
+		if got["SUMMARY"] != tt.Title {
+			t.Errorf("SUMMARY = %q; want %q", got["SUMMARY"], tt.Title)
+		}
+		gotDue, err := time.Parse(icsTimeFormat, got["DUE"])
+		if err != nil || !gotDue.Equal(*tt.DueDate) {
+			t.Errorf("DUE = %q; want %v", got["DUE"], tt.DueDate)
+		}
+		if got["DTSTAMP"] != now.Format(icsTimeFormat) {
+			t.Errorf("DTSTAMP = %q; want la hora de generación %v", got["DTSTAMP"], now)
+		}
+		wantModified := tt.UpdatedAt
+		if wantModified.IsZero() {
+			wantModified = tt.CreatedAt
+		}
+		if got["LAST-MODIFIED"] != wantModified.Format(icsTimeFormat) {
+			t.Errorf("LAST-MODIFIED = %q; want %v", got["LAST-MODIFIED"], wantModified)
+		}
+		wantStatus := "NEEDS-ACTION"
+		if tt.Done {
+			wantStatus = "COMPLETED"
+		}
+		if got["STATUS"] != wantStatus {
+			t.Errorf("STATUS = %q; want %q", got["STATUS"], wantStatus)
+		}
+	}
+}
+
+func TestTaskUIDStable(t *testing.T) {
+	if taskUID(42) != taskUID(42) || taskUID(1) == taskUID(2) {
+		t.Errorf("taskUID debe ser estable y único por ID")
+	}
+}
+
+func TestWriteICSLineFoldsUTF8(t *testing.T) {
+	var buf bytes.Buffer
+	cal := []Task{{ID: 1, Title: strings.Repeat("ñ", 100), DueDate: &time.Time{}}}
+	if err := writeCalendar(&buf, "x", cal, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range strings.Split(buf.String(), "\r\n") {
+		if !utf8.ValidString(l) {
+			t.Errorf("línea con UTF-8 partido: %q", l)
+		}
+	}
+}
+
+func TestParseCalendarTokens(t *testing.T) {
+	got := parseCalendarTokens("ana:s3cret, luis:otro,malformado,:sinusuario")
+	if len(got) != 2 || got["s3cret"] != "ana" || got["otro"] != "luis" {
+		t.Errorf("parseCalendarTokens = %v", got)
+	}
+}
+
+func TestCalendarPerUser(t *testing.T) {
+	saved := calendarTokens
+	calendarTokens = parseCalendarTokens("ana:t-ana,luis:t-luis")
+	t.Cleanup(func() { calendarTokens = saved })
+
+	srv := newServer(newTaskStore())
+	due := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	srv.store.mu.Lock()
+	srv.store.createLocked(Task{Title: "de Ana", Owner: "ana", DueDate: &due})
+	srv.store.createLocked(Task{Title: "de Luis", Owner: "luis", DueDate: &due})
+	srv.store.createLocked(Task{Title: "anónima", DueDate: &due})
+	srv.store.mu.Unlock()
+	h := srv.routes()
+
+	tests := []struct {
+		token      string
+		wantStatus int
+		wantName   string
+		wantTitles []string
+	}{
+		{"t-ana", http.StatusOK, "Tareas de ana", []string{"de Ana"}},
+		{"t-luis", http.StatusOK, "Tareas de luis", []string{"de Luis"}},
+		{"", http.StatusNotFound, "", nil},
+		{"otro", http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/tasks/calendar.ics?token="+tt.token, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("token %q: status = %d; want %d", tt.token, w.Code, tt.wantStatus)
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		cal, todos := parseICS(t, w.Body.String())
+		if cal["X-WR-CALNAME"] != tt.wantName {
+			t.Errorf("token %q: X-WR-CALNAME = %q; want %q", tt.token, cal["X-WR-CALNAME"], tt.wantName)
+		}
+		var titles []string
+		for _, todo := range todos {
+			titles = append(titles, todo["SUMMARY"])
+		}
+		if !reflect.DeepEqual(titles, tt.wantTitles) {
+			t.Errorf("token %q: tareas = %q; want %q", tt.token, titles, tt.wantTitles)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Registro de cambios para long-polling. Cada mutación incrementa la
+// revisión del almacén (monótona, como nextID) y guarda el cambio en un log
+// acotado. GET /tasks/changes?since=N&wait=30s espera hasta que haya una
+// revisión mayor que N o venza el tiempo.
+
+const (
+	// maxChangeLog es cuántos cambios se conservan; un cliente que se quede
+	// más atrás recibe 410 y debe volver a leer /tasks completo.
+	maxChangeLog = 1000
+
+	defaultPollWait = 30 * time.Second
+	maxPollWait     = 60 * time.Second
+)
+
+// Tipos de cambio.
+const (
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+	// opReorder cambia a la vez la posición de varias tareas (en Tasks),
+	// para que un movimiento sea un solo cambio.
+	opReorder = "reorder"
+
+	// Cambios de proyectos (ver projects.go).
+	opProjectCreate = "project.create"
+	opProjectUpdate = "project.update"
+	opProjectDelete = "project.delete"
+)
+
+// change es una mutación del almacén: de una tarea o, en los op
+// "project.*", de un proyecto.
+type change struct {
+	Revision  int64     `json:"revision"`
+	Op        string    `json:"op"`
+	TaskID    int       `json:"task_id,omitempty"`
+	Task      *Task     `json:"task,omitempty"`  // estado tras el cambio (no en delete)
+	Tasks     []Task    `json:"tasks,omitempty"` // tareas tras un reorder, o renumeradas por un delete
+	ProjectID int       `json:"project_id,omitempty"`
+	Project   *Project  `json:"project,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// shuttingDown se cierra al empezar el apagado para liberar las esperas.
+var shuttingDown = make(chan struct{})
+
+// changesHandler maneja GET /tasks/changes?since=N&wait=30s.
+func (srv *server) changesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+
+	q := r.URL.Query()
+	since, err := strconv.ParseInt(q.Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRevision)
+		return
+	}
+	wait := defaultPollWait
+	if s := q.Get("wait"); s != "" {
+		wait, err = time.ParseDuration(s)
+		if err != nil || wait < 0 || wait > maxPollWait {
+			writeError(w, r, http.StatusBadRequest, codeInvalidWait, maxPollWait.String())
+			return
+		}
+	}
+
+	// La espera puede superar el WriteTimeout del servidor.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 5*time.Second))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	st := srv.store
+	st.mu.RLock()
+	if since > st.revision {
+		current := st.revision
+		st.mu.RUnlock()
+		writeError(w, r, http.StatusBadRequest, codeRevisionAhead, current)
+		return
+	}
+	for {
+		changes, ok := st.changesSinceLocked(since)
+		current, wake := st.revision, st.changed
+		st.mu.RUnlock()
+
+		if !ok {
+			writeError(w, r, http.StatusGone, codeRevisionTooOld, since)
+			return
+		}
+		if len(changes) > 0 {
+			writeJSON(w, http.StatusOK, map[string]any{"revision": current, "changes": changes})
+			return
+		}
+
+		select {
+		case <-wake:
+			st.mu.RLock()
+		case <-timer.C:
+			writeJSON(w, http.StatusOK, map[string]any{"revision": current, "changes": changes})
+			return
+		case <-shuttingDown:
+			writeJSON(w, http.StatusOK, map[string]any{"revision": current, "changes": changes})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChangesLongPollWakesOnMutation(t *testing.T) {
+	srv := newServer(newTaskStore())
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		srv.changesHandler(w, httptest.NewRequest(http.MethodGet, "/tasks/changes?since=0&wait=5s", nil))
+		done <- w
+	}()
+
+	time.Sleep(50 * time.Millisecond) // dejar que el cliente quede esperando
+	srv.store.mu.Lock()
+	srv.store.createLocked(Task{Title: "nueva"})
+	srv.store.mu.Unlock()
+
+	select {
+	case w := <-done:
+		var body struct {
+			Revision int64
+			Changes  []change
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Revision != 1 || len(body.Changes) != 1 || body.Changes[0].Op != opCreate {
+			t.Errorf("respuesta inesperada: %+v", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("el long-poll no se despertó con la mutación")
+	}
+}
+
+func TestChangesStatusCodes(t *testing.T) {
+	srv := newServer(newTaskStore())
+	srv.store.mu.Lock()
+	for range maxChangeLog + 5 {
+		srv.store.createLocked(Task{Title: "t"})
+	}
+	srv.store.mu.Unlock()
+
+	tests := []struct {
+		query      string
+		wantStatus int
+	}{
+		{"since=abc", http.StatusBadRequest},
+		{"since=-1", http.StatusBadRequest},
+		{"since=0&wait=10m", http.StatusBadRequest},
+		{"since=99999", http.StatusBadRequest},
+		{"since=1", http.StatusGone}, // fuera del log
+		{"since=1004", http.StatusOK},
+		{"since=1005&wait=10ms", http.StatusOK}, // sin cambios: vence la espera
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.changesHandler(w, httptest.NewRequest(http.MethodGet, "/tasks/changes?"+tt.query, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d; want %d", tt.query, w.Code, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configuración del servidor. Cada opción se puede dar en un fichero
+// (JSON o TOML), en una variable de entorno o con un flag; la precedencia
+// es flags > entorno > fichero > valores por defecto.
+
+// config contiene todas las opciones del servidor.
+type config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	StorageBackend    string // "memory" o "file"
+	StoragePath       string // fichero JSON cuando StorageBackend es "file"
+	LogLevel          string // "debug", "info", "warn" o "error"
+	MaxTitleLen       int
+	MaxBodyBytes      int // tamaño máximo del cuerpo JSON
+	MaxTasksPerUser   int // 0 = sin límite
+	LegacyErrors      bool
+	CalendarTokens    string // "usuario:token,usuario2:token2"
+	AdminToken        string // protege /admin/*; vacío las desactiva
+	ReplicationToken  string // protege /replication/snapshot y /stream; el seguidor lo envía al líder
+	TLSCert           string // certificado PEM del servidor
+	TLSKey            string // clave privada PEM del servidor
+	TLSClientCA       string // CA para exigir certificado de cliente (mTLS)
+	DevSelfSigned     bool   // certificado autofirmado efímero (desarrollo)
+	CORSOrigins       string // lista separada por comas; vacío desactiva CORS
+	CORSMethods       string
+	CORSHeaders       string
+	CORSCredentials   bool
+	CORSMaxAge        time.Duration
+	Compress          bool
+	CompressMinSize   int           // bytes a partir de los cuales se comprime
+	ShutdownDrain     time.Duration // tiempo en 503 antes de cerrar conexiones
+	ShutdownTimeout   time.Duration // espera máxima a las peticiones en curso
+	ReplicateFrom     string        // URL del líder; vacío = la instancia es líder
+	BlobDir           string        // directorio de los adjuntos
+	MaxAttachmentSize int           // bytes por adjunto
+	BlobGCInterval    time.Duration // cada cuánto se borran blobs huérfanos
+	ReminderOffsets   string        // "1h,15m": avisos antes de la fecha límite
+	ReminderWebhook   string        // URL a la que se envían los avisos
+	AuditFile         string        // registro de auditoría; vacío lo desactiva
+	AuditKey          string        // clave HMAC de la cadena de auditoría
+
+	ConfigFile  string // solo flag/entorno: fichero de configuración
+	PrintConfig bool   // solo flag: imprimir la configuración y salir
+}
+
+// defaultConfig devuelve los valores que se usan si nadie dice otra cosa.
+func defaultConfig() config {
+	return config{
+		Addr:              ":8080",
+		ReadTimeout:       5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		StorageBackend:    "memory",
+		LogLevel:          "info",
+		MaxTitleLen:       200,
+		MaxBodyBytes:      1 << 20,
+		CORSMethods:       "GET, POST, PATCH, DELETE, OPTIONS",
+		CORSHeaders:       "Content-Type, Accept-Language",
+		CORSMaxAge:        10 * time.Minute,
+		Compress:          true,
+		CompressMinSize:   1024,
+		ShutdownDrain:     5 * time.Second,
+		ShutdownTimeout:   10 * time.Second,
+		BlobDir:           "blobs",
+		MaxAttachmentSize: 10 << 20,
+		BlobGCInterval:    time.Hour,
+		ReminderOffsets:   "1h",
+	}
+}
+
+// setting describe una opción: su clave en el fichero (el flag es la misma
+// clave con guiones), su variable de entorno y el campo de config.
+type setting struct {
+	key    string
+	env    string
+	usage  string
+	field  func(c *config) any // puntero al campo
+	secret bool                // se oculta en -print-config
+}
+
+var settings = []setting{
+	{key: "addr", env: "TASKS_ADDR", usage: "dirección de escucha", field: func(c *config) any { return &c.Addr }},
+	{key: "read_timeout", env: "TASKS_READ_TIMEOUT", usage: "timeout de lectura", field: func(c *config) any { return &c.ReadTimeout }},
+	{key: "write_timeout", env: "TASKS_WRITE_TIMEOUT", usage: "timeout de escritura", field: func(c *config) any { return &c.WriteTimeout }},
+	{key: "idle_timeout", env: "TASKS_IDLE_TIMEOUT", usage: "timeout de conexiones inactivas", field: func(c *config) any { return &c.IdleTimeout }},
+	{key: "storage", env: "TASKS_STORAGE", usage: `almacenamiento: "memory" o "file"`, field: func(c *config) any { return &c.StorageBackend }},
+	{key: "storage_path", env: "TASKS_STORAGE_PATH", usage: `fichero de datos para storage "file"`, field: func(c *config) any { return &c.StoragePath }},
+	{key: "log_level", env: "TASKS_LOG_LEVEL", usage: "nivel de log: debug, info, warn o error", field: func(c *config) any { return &c.LogLevel }},
+	{key: "max_title_len", env: "TASKS_MAX_TITLE_LEN", usage: "longitud máxima del título", field: func(c *config) any { return &c.MaxTitleLen }},
+	{key: "max_body_bytes", env: "TASKS_MAX_BODY_BYTES", usage: "tamaño máximo del cuerpo JSON de una petición", field: func(c *config) any { return &c.MaxBodyBytes }},
+	{key: "max_tasks_per_user", env: "TASKS_MAX_TASKS_PER_USER", usage: "máximo de tareas por usuario (0 = sin límite)", field: func(c *config) any { return &c.MaxTasksPerUser }},
+	{key: "legacy_errors", env: "TASKS_LEGACY_ERRORS", usage: `devolver errores con el formato antiguo {"error": "..."}`, field: func(c *config) any { return &c.LegacyErrors }},
+	{key: "calendar_tokens", env: "TASKS_CALENDAR_TOKENS", usage: `tokens del calendario ("usuario:token,...")`, field: func(c *config) any { return &c.CalendarTokens }, secret: true},
+	{key: "admin_token", env: "TASKS_ADMIN_TOKEN", usage: "token Bearer para /admin/backup y /admin/restore", field: func(c *config) any { return &c.AdminToken }, secret: true},
+	{key: "replication_token", env: "TASKS_REPLICATION_TOKEN", usage: "token Bearer de /replication/snapshot y /replication/stream (el admin_token también vale)", field: func(c *config) any { return &c.ReplicationToken }, secret: true},
+	{key: "tls_cert", env: "TASKS_TLS_CERT", usage: "certificado TLS (PEM); se recarga al cambiar", field: func(c *config) any { return &c.TLSCert }},
+	{key: "tls_key", env: "TASKS_TLS_KEY", usage: "clave privada TLS (PEM)", field: func(c *config) any { return &c.TLSKey }},
+	{key: "tls_client_ca", env: "TASKS_TLS_CLIENT_CA", usage: "CA de clientes para mTLS (el CN del cliente es el usuario)", field: func(c *config) any { return &c.TLSClientCA }},
+	{key: "dev_self_signed", env: "TASKS_DEV_SELF_SIGNED", usage: "servir TLS con un certificado autofirmado efímero (solo desarrollo)", field: func(c *config) any { return &c.DevSelfSigned }},
+	{key: "cors_origins", env: "TASKS_CORS_ORIGINS", usage: `orígenes permitidos para CORS, separados por comas ("*" = todos)`, field: func(c *config) any { return &c.CORSOrigins }},
+	{key: "cors_methods", env: "TASKS_CORS_METHODS", usage: "métodos permitidos en CORS", field: func(c *config) any { return &c.CORSMethods }},
+	{key: "cors_headers", env: "TASKS_CORS_HEADERS", usage: "cabeceras permitidas en CORS", field: func(c *config) any { return &c.CORSHeaders }},
+	{key: "cors_credentials", env: "TASKS_CORS_CREDENTIALS", usage: "permitir credenciales (cookies) en CORS", field: func(c *config) any { return &c.CORSCredentials }},
+	{key: "cors_max_age", env: "TASKS_CORS_MAX_AGE", usage: "tiempo de caché de las respuestas preflight", field: func(c *config) any { return &c.CORSMaxAge }},
+	{key: "compress", env: "TASKS_COMPRESS", usage: "comprimir respuestas con gzip/deflate", field: func(c *config) any { return &c.Compress }},
+	{key: "compress_min_size", env: "TASKS_COMPRESS_MIN_SIZE", usage: "tamaño mínimo (bytes) para comprimir", field: func(c *config) any { return &c.CompressMinSize }},
+	{key: "shutdown_drain", env: "TASKS_SHUTDOWN_DRAIN", usage: "tiempo que /readyz responde 503 antes de apagar", field: func(c *config) any { return &c.ShutdownDrain }},
+	{key: "shutdown_timeout", env: "TASKS_SHUTDOWN_TIMEOUT", usage: "espera máxima a las peticiones en curso al apagar", field: func(c *config) any { return &c.ShutdownTimeout }},
+	{key: "blob_dir", env: "TASKS_BLOB_DIR", usage: "directorio donde se guardan los adjuntos", field: func(c *config) any { return &c.BlobDir }},
+	{key: "max_attachment_size", env: "TASKS_MAX_ATTACHMENT_SIZE", usage: "tamaño máximo de un adjunto en bytes", field: func(c *config) any { return &c.MaxAttachmentSize }},
+	{key: "blob_gc_interval", env: "TASKS_BLOB_GC_INTERVAL", usage: "cada cuánto se borran los adjuntos huérfanos", field: func(c *config) any { return &c.BlobGCInterval }},
+	{key: "reminder_offsets", env: "TASKS_REMINDER_OFFSETS", usage: `avisos antes de la fecha límite, p. ej. "1h,15m" (vacío los desactiva)`, field: func(c *config) any { return &c.ReminderOffsets }},
+	{key: "reminder_webhook", env: "TASKS_REMINDER_WEBHOOK", usage: "URL que recibe los avisos por POST", field: func(c *config) any { return &c.ReminderWebhook }},
+	{key: "audit_file", env: "TASKS_AUDIT_FILE", usage: "fichero del registro de auditoría de las escrituras (vacío = desactivado)", field: func(c *config) any { return &c.AuditFile }},
+	{key: "audit_key", env: "TASKS_AUDIT_KEY", usage: "clave secreta con la que se firma (HMAC) la cadena de auditoría", field: func(c *config) any { return &c.AuditKey }, secret: true},
+	{key: "replicate_from", env: "TASKS_REPLICATE_FROM", usage: "URL del líder; la instancia pasa a ser una réplica de solo lectura", field: func(c *config) any { return &c.ReplicateFrom }},
+}
+
+// flagName convierte la clave del fichero en nombre de flag.
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// set interpreta v según el tipo del campo y lo asigna.
+func (s setting) set(c *config, v string) error {
+	var err error
+	switch p := s.field(c).(type) {
+	case *string:
+		*p = v
+	case *int:
+		*p, err = strconv.Atoi(v)
+	case *bool:
+		*p, err = strconv.ParseBool(v)
+	case *time.Duration:
+		*p, err = time.ParseDuration(v)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: valor %q inválido", s.key, v)
+	}
+	return nil
+}
+
+// get devuelve el valor del campo como texto.
+func (s setting) get(c *config) string {
+	switch p := s.field(c).(type) {
+	case *string:
+		return *p
+	case *int:
+		return strconv.Itoa(*p)
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *time.Duration:
+		return p.String()
+	}
+	return ""
+}
+
+// loadConfig construye la configuración a partir de los argumentos de la
+// línea de comandos, el entorno (lookupEnv, como os.LookupEnv) y el
+// fichero de configuración. Una variable definida pero vacía también
+// cuenta: TASKS_REMINDER_OFFSETS= desactiva los recordatorios.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (config, error) {
+	cfg := defaultConfig()
+
+	// 1. Flags: se guardan aparte para aplicarlos al final.
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		store := func(v string) error { flagValues[s.key] = v; return nil }
+		if _, ok := s.field(&cfg).(*bool); ok {
+			fs.BoolFunc(s.flagName(), s.usage, store)
+		} else {
+			fs.Func(s.flagName(), s.usage, store)
+		}
+	}
+	configFile, _ := lookupEnv("TASKS_CONFIG")
+	fs.StringVar(&cfg.ConfigFile, "config", configFile, "fichero de configuración (.json o .toml)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "imprimir la configuración efectiva y salir")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	// 2. Fichero.
+	if cfg.ConfigFile != "" {
+		values, err := readConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return cfg, err
+		}
+		if err := applySettings(&cfg, values); err != nil {
+			return cfg, fmt.Errorf("%s: %w", cfg.ConfigFile, err)
+		}
+	}
+
+	// 3. Entorno.
+	envValues := make(map[string]string)
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env); ok {
+			envValues[s.key] = v
+		}
+	}
+	if err := applySettings(&cfg, envValues); err != nil {
+		return cfg, fmt.Errorf("entorno: %w", err)
+	}
+
+	// 4. Flags.
+	if err := applySettings(&cfg, flagValues); err != nil {
+		return cfg, fmt.Errorf("flags: %w", err)
+	}
+
+	return cfg, cfg.validate()
+}
+
+// applySettings asigna los valores por clave; una clave desconocida es error.
+func applySettings(cfg *config, values map[string]string) error {
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // errores deterministas
+	for _, k := range keys {
+		s, ok := byKey[k]
+		if !ok {
+			return fmt.Errorf("opción desconocida %q", k)
+		}
+		if err := s.set(cfg, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate comprueba que la configuración tiene sentido antes de arrancar.
+func (c config) validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q inválida: %v", c.Addr, err))
+	}
+	for _, d := range []struct {
+		name string
+		v    time.Duration
+	}{{"read_timeout", c.ReadTimeout}, {"write_timeout", c.WriteTimeout}, {"idle_timeout", c.IdleTimeout}} {
+		if d.v <= 0 {
+			errs = append(errs, fmt.Errorf("%s debe ser mayor que 0", d.name))
+		}
+	}
+	switch c.StorageBackend {
+	case "memory":
+	case "file":
+		if c.StoragePath == "" {
+			errs = append(errs, errors.New(`storage "file" requiere storage_path`))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(`storage %q desconocido (usa "memory" o "file")`, c.StorageBackend))
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if c.MaxTitleLen < 1 || c.MaxTitleLen > 10000 {
+		errs = append(errs, errors.New("max_title_len debe estar entre 1 y 10000"))
+	}
+	if c.MaxBodyBytes < 1 {
+		errs = append(errs, errors.New("max_body_bytes debe ser positivo"))
+	}
+	if c.MaxTasksPerUser < 0 {
+		errs = append(errs, errors.New("max_tasks_per_user no puede ser negativo"))
+	}
+	for _, pair := range strings.Split(c.CalendarTokens, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		if user, token, ok := strings.Cut(pair, ":"); !ok || user == "" || token == "" {
+			errs = append(errs, fmt.Errorf("calendar_tokens: entrada %q inválida (usa usuario:token)", pair))
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls_cert y tls_key deben darse juntos"))
+	}
+	if c.DevSelfSigned && c.TLSCert != "" {
+		errs = append(errs, errors.New("dev_self_signed no se puede combinar con tls_cert"))
+	}
+	if c.CORSMaxAge < 0 {
+		errs = append(errs, errors.New("cors_max_age no puede ser negativo"))
+	}
+	if c.ShutdownDrain < 0 || c.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("shutdown_drain y shutdown_timeout no pueden ser negativos"))
+	}
+	if c.CompressMinSize < 0 {
+		errs = append(errs, errors.New("compress_min_size no puede ser negativo"))
+	}
+	if c.BlobDir == "" {
+		errs = append(errs, errors.New("blob_dir no puede estar vacío"))
+	}
+	if c.MaxAttachmentSize < 1 {
+		errs = append(errs, errors.New("max_attachment_size debe ser positivo"))
+	}
+	if c.BlobGCInterval <= 0 {
+		errs = append(errs, errors.New("blob_gc_interval debe ser positivo"))
+	}
+	if _, err := parseOffsets(c.ReminderOffsets); err != nil {
+		errs = append(errs, fmt.Errorf("reminder_offsets: %w", err))
+	}
+	if c.ReminderWebhook != "" && !isHTTPURL(c.ReminderWebhook) {
+		errs = append(errs, fmt.Errorf("reminder_webhook %q no es una URL http(s) válida", c.ReminderWebhook))
+	}
+	if c.ReplicateFrom != "" && !isHTTPURL(c.ReplicateFrom) {
+		errs = append(errs, fmt.Errorf("replicate_from %q no es una URL http(s) válida", c.ReplicateFrom))
+	}
+	if c.AuditFile != "" && len(c.AuditKey) < 16 {
+		errs = append(errs, errors.New("audit_file requiere audit_key de al menos 16 caracteres"))
+	}
+	if c.TLSClientCA != "" && !c.tlsEnabled() {
+		errs = append(errs, errors.New("tls_client_ca requiere TLS (tls_cert/tls_key o dev_self_signed)"))
+	}
+	return errors.Join(errs...)
+}
+
+// isHTTPURL indica si s es una URL http(s) absoluta.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// corsOptions convierte las opciones CORS de la configuración.
+func (c config) corsOptions() corsOptions {
+	return corsOptions{
+		Origins:     splitList(c.CORSOrigins),
+		Methods:     splitList(c.CORSMethods),
+		Headers:     splitList(c.CORSHeaders),
+		Credentials: c.CORSCredentials,
+		MaxAge:      c.CORSMaxAge,
+	}
+}
+
+// splitList separa una lista por comas ignorando espacios y vacíos.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// tlsEnabled indica si el servidor debe servir HTTPS.
+func (c config) tlsEnabled() bool {
+	return c.TLSCert != "" || c.DevSelfSigned
+}
+
+// parseLogLevel traduce el nombre del nivel de log.
+func parseLogLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return l, fmt.Errorf("log_level %q inválido (usa debug, info, warn o error)", s)
+	}
+	return l, nil
+}
+
+// printConfig escribe la configuración efectiva en JSON, ocultando secretos.
+func printConfig(w io.Writer, c config) error {
+	out := make(map[string]any, len(settings))
+	for _, s := range settings {
+		var v any = s.get(&c)
+		switch p := s.field(&c).(type) {
+		case *int:
+			v = *p
+		case *bool:
+			v = *p
+		}
+		if s.secret && v != "" {
+			v = "***"
+		}
+		out[s.key] = v
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// readConfigFile lee un fichero .json o .toml y devuelve sus valores como
+// texto, listos para applySettings.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("leyendo configuración: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSONConfig(data)
+	case ".toml":
+		return parseTOMLConfig(data)
+	default:
+		return nil, fmt.Errorf("%s: formato desconocido (usa .json o .toml)", path)
+	}
+}
+
+// parseJSONConfig acepta un objeto plano con valores string, número o bool.
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("JSON inválido: %w", err)
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case json.Number:
+			values[k] = v.String()
+		case bool:
+			values[k] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("%s: tipo no soportado", k)
+		}
+	}
+	return values, nil
+}
+
+// parseTOMLConfig entiende el subconjunto de TOML que necesita la
+// configuración: pares clave = valor en el nivel superior, con strings,
+// enteros y booleanos, y comentarios con #. No admite tablas.
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("línea %d: las tablas TOML no están soportadas", n)
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("línea %d: se esperaba clave = valor", n)
+		}
+		key = strings.TrimSpace(key)
+		v, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", n, err)
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("línea %d: clave %q repetida", n, key)
+		}
+		values[key] = v
+	}
+	return values, sc.Err()
+}
+
+// parseTOMLValue interpreta un valor TOML escalar (con comentario opcional).
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		// String básico: buscar la comilla de cierre no escapada.
+		for i := 1; i < len(raw); i++ {
+			if raw[i] == '\\' {
+				i++
+				continue
+			}
+			if raw[i] == '"' {
+				if rest := strings.TrimSpace(raw[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return "", fmt.Errorf("texto inesperado tras el string: %q", rest)
+				}
+				return strconv.Unquote(raw[:i+1])
+			}
+		}
+		return "", errors.New("string sin cerrar")
+	case strings.HasPrefix(raw, "'"):
+		// String literal: sin escapes.
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", errors.New("string sin cerrar")
+		}
+		return raw[1 : end+1], nil
+	default:
+		v, _, _ := strings.Cut(raw, "#")
+		v = strings.TrimSpace(v)
+		if v == "true" || v == "false" {
+			return v, nil
+		}
+		if _, err := strconv.ParseInt(strings.ReplaceAll(v, "_", ""), 10, 64); err == nil {
+			return strings.ReplaceAll(v, "_", ""), nil
+		}
+		return "", fmt.Errorf("valor %q no soportado", v)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lookup devuelve una función como os.LookupEnv que lee de env.
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "api.toml")
+	data := "addr = \":9000\"\nread_timeout = \"3s\"\nmax_title_len = 50\nlog_level = 'debug'\n"
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"TASKS_CONFIG":        file,
+		"TASKS_READ_TIMEOUT":  "4s",
+		"TASKS_MAX_TITLE_LEN": "80",
+	}
+
+	cfg, err := loadConfig([]string{"-max-title-len", "120"}, lookup(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"addr (fichero)", cfg.Addr, ":9000"},
+		{"log_level (fichero)", cfg.LogLevel, "debug"},
+		{"read_timeout (entorno > fichero)", cfg.ReadTimeout, 4 * time.Second},
+		{"max_title_len (flag > entorno)", cfg.MaxTitleLen, 120},
+		{"write_timeout (por defecto)", cfg.WriteTimeout, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v; want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigEmptyEnv(t *testing.T) {
+	// Una variable vacía pero definida pisa el valor por defecto.
+	cfg, err := loadConfig(nil, lookup(map[string]string{"TASKS_REMINDER_OFFSETS": ""}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReminderOffsets != "" {
+		t.Errorf("ReminderOffsets = %q; want vacío", cfg.ReminderOffsets)
+	}
+	cfg, err = loadConfig(nil, lookup(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReminderOffsets != "1h" {
+		t.Errorf("ReminderOffsets sin variable = %q; want 1h", cfg.ReminderOffsets)
+	}
+}
+
+func TestApplyConfigLogLevel(t *testing.T) {
+	defer slog.SetLogLoggerLevel(slog.LevelInfo)
+	for _, tt := range []struct {
+		level       string
+		debug, warn bool
+	}{
+		{"debug", true, true},
+		{"warn", false, true},
+		{"error", false, false},
+	} {
+		cfg := defaultConfig()
+		cfg.LogLevel = tt.level
+		if err := applyConfig(cfg, newTaskStore()); err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		if got := slog.Default().Enabled(ctx, slog.LevelDebug); got != tt.debug {
+			t.Errorf("%s: debug activo = %v; want %v", tt.level, got, tt.debug)
+		}
+		if got := slog.Default().Enabled(ctx, slog.LevelWarn); got != tt.warn {
+			t.Errorf("%s: warn activo = %v; want %v", tt.level, got, tt.warn)
+		}
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := [][]string{
+		{"-addr", "sin-puerto"},
+		{"-read-timeout", "0s"},
+		{"-read-timeout", "mucho"},
+		{"-storage", "disk"},
+		{"-storage", "file"}, // sin storage-path
+		{"-log-level", "verbose"},
+		{"-max-title-len", "0"},
+		{"-calendar-tokens", "sin-dos-puntos"},
+	}
+
+	for _, args := range tests {
+		if _, err := loadConfig(args, lookup(nil)); err == nil {
+			t.Errorf("loadConfig(%q) no devolvió error", args)
+		}
+	}
+}
+
+func TestSettingsEnvPrefix(t *testing.T) {
+	// Todas las variables de entorno llevan el prefijo TASKS_.
+	for _, s := range settings {
+		if !strings.HasPrefix(s.env, "TASKS_") {
+			t.Errorf("%s: variable de entorno %q sin el prefijo TASKS_", s.key, s.env)
+		}
+	}
+	env := map[string]string{"TASKS_CALENDAR_TOKENS": "ana:s3cret"}
+	cfg, err := loadConfig(nil, lookup(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CalendarTokens != "ana:s3cret" {
+		t.Errorf("CalendarTokens = %q; want ana:s3cret", cfg.CalendarTokens)
+	}
+}
+
+func TestParseConfigFiles(t *testing.T) {
+	jsonValues, err := parseJSONConfig([]byte(`{"addr": ":1", "max_title_len": 10, "legacy_errors": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tomlValues, err := parseTOMLConfig([]byte("# comentario\naddr = \":1\" # fin\nmax_title_len = 1_0\nlegacy_errors = true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range []map[string]string{jsonValues, tomlValues} {
+		if values["addr"] != ":1" || values["max_title_len"] != "10" || values["legacy_errors"] != "true" {
+			t.Errorf("valores = %v", values)
+		}
+	}
+
+	for _, bad := range []string{"[server]\naddr = \":1\"", "addr", "addr = \"sin cerrar", "addr = [1, 2]"} {
+		if _, err := parseTOMLConfig([]byte(bad)); err == nil {
+			t.Errorf("parseTOMLConfig(%q) no devolvió error", bad)
+		}
+	}
+}
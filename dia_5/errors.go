@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Errores en formato RFC 9457 (application/problem+json).
+// Cada error lleva un code estable para que los clientes no dependan
+// del texto del mensaje.
+
+// Códigos de error estables de la API.
+const (
+	codeMethodNotAllowed        = "method_not_allowed"
+	codeIDRequired              = "id_required"
+	codeInvalidID               = "invalid_id"
+	codeTaskNotFound            = "task_not_found"
+	codeInvalidJSON             = "invalid_json"
+	codeValidationFailed        = "validation_failed"
+	codeTitleEmpty              = "title_empty"
+	codeTitleTooLong            = "title_too_long"
+	codeQueryRequired           = "query_required"
+	codeInvalidLimit            = "invalid_limit"
+	codeCalendarNotFound        = "calendar_not_found"
+	codeInvalidRevision         = "invalid_revision"
+	codeRevisionAhead           = "revision_ahead"
+	codeRevisionTooOld          = "revision_too_old"
+	codeInvalidWait             = "invalid_wait"
+	codeReadOnlyReplica         = "read_only_replica"
+	codeAdminUnauthorized       = "admin_unauthorized"
+	codeReplicationUnauthorized = "replication_unauthorized"
+	codeInvalidBackup           = "invalid_backup"
+	codeBackupTooNew            = "backup_too_new"
+	codeBackupChecksum          = "backup_checksum_mismatch"
+	codeBackupMissingBlob       = "backup_missing_blob"
+	codeBodyTooLarge            = "body_too_large"
+	codeInvalidMultipart        = "invalid_multipart"
+	codeFileRequired            = "file_required"
+	codeAttachmentNotFound      = "attachment_not_found"
+	codeInternalError           = "internal_error"
+	codeAuditFailed             = "audit_failed"
+	codeQuotaExceeded           = "quota_exceeded"
+	codeProjectNotFound         = "project_not_found"
+	codeProjectRequired         = "project_required"
+	codeNameEmpty               = "name_empty"
+	codeInvalidColumns          = "invalid_columns"
+	codeUnknownColumn           = "unknown_column"
+	codeInvalidPosition         = "invalid_position"
+	codeWIPLimitExceeded        = "wip_limit_exceeded"
+	codeInvalidRPCRequest       = "invalid_rpc_request"
+	codeRPCMethodNotFound       = "rpc_method_not_found"
+	codeInvalidParams           = "invalid_params"
+	codeProjectNotEmpty         = "project_not_empty"
+	codeCSRFInvalid             = "csrf_invalid"
+)
+
+const (
+	// problemTypeBase es el prefijo del campo type; se completa con el code.
+	problemTypeBase    = "/problems/"
+	problemContentType = "application/problem+json"
+)
+
+// legacyErrors activa el formato antiguo {"error": "..."} para clientes
+// que todavía no migraron. Se controla con el flag -legacy-errors.
+var legacyErrors bool
+
+// problem es el cuerpo de una respuesta de error.
+type problem struct {
+	Type     string        `json:"type"`
+	Title    string        `json:"title"`
+	Status   int           `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Instance string        `json:"instance,omitempty"`
+	Code     string        `json:"code"`
+	Errors   []*fieldError `json:"errors,omitempty"`
+}
+
+// fieldError describe un error de validación en un campo concreto.
+// Implementa error para que las funciones de validación lo devuelvan;
+// Detail está en el idioma por defecto y se traduce al responder.
+type fieldError struct {
+	Field  string `json:"field,omitempty"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+	args   []any  // argumentos del mensaje, para poder traducirlo
+}
+
+// newFieldError crea un error de validación para field con el mensaje de code.
+func newFieldError(field, code string, args ...any) *fieldError {
+	return &fieldError{
+		Field:  field,
+		Code:   code,
+		Detail: localize(defaultLang, code, args...).Detail,
+		args:   args,
+	}
+}
+
+func (e *fieldError) Error() string { return e.Detail }
+
+// newProblem construye un problema para el code dado, con los textos en el
+// idioma que pide la petición. args completa el detalle del catálogo.
+func newProblem(r *http.Request, status int, code string, args ...any) problem {
+	msg := localize(requestLang(r), code, args...)
+	return problem{
+		Type:     problemTypeBase + code,
+		Title:    msg.Title,
+		Status:   status,
+		Detail:   msg.Detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	}
+}
+
+// writeProblem envía p como application/problem+json (o en el formato
+// antiguo si legacyErrors está activo).
+func writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	w.Header().Set("Content-Language", requestLang(r))
+	w.Header().Add("Vary", "Accept-Language")
+	if legacyErrors {
+		writeJSON(w, p.Status, map[string]any{"error": p.Detail})
+		return
+	}
+	writeJSONAs(w, problemContentType, p.Status, p)
+}
+
+// writeError devuelve un error con su code estable y el detalle del
+// catálogo, formateado con args.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string, args ...any) {
+	writeProblem(w, r, newProblem(r, status, code, args...))
+}
+
+// writeMethodNotAllowed responde 405 indicando los métodos permitidos.
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed)
+}
+
+// writeValidationError responde 400 con un error por campo. Los errores
+// que no sean *fieldError se reportan sin campo.
+func writeValidationError(w http.ResponseWriter, r *http.Request, errs ...error) {
+	p := newProblem(r, http.StatusBadRequest, codeValidationFailed)
+	p.Errors = localizeFieldErrors(requestLang(r), errs...)
+	if len(p.Errors) > 0 {
+		p.Detail = p.Errors[0].Detail
+	}
+	writeProblem(w, r, p)
+}
+
+// localizeFieldErrors traduce errs a lang. Los errores que no sean
+// *fieldError se reportan sin campo.
+func localizeFieldErrors(lang string, errs ...error) []*fieldError {
+	var out []*fieldError
+	for _, err := range errs {
+		var fe *fieldError
+		if !errors.As(err, &fe) {
+			out = append(out, &fieldError{Code: codeValidationFailed, Detail: err.Error()})
+			continue
+		}
+		localized := *fe
+		localized.Detail = localize(lang, fe.Code, fe.args...).Detail
+		out = append(out, &localized)
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeBody decodifica el cuerpo como un objeto JSON genérico, para ver
+// exactamente qué campos lleva.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cuerpo %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func TestProblemFields(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+	tests := []struct {
+		name, method, path, body string
+		want                     map[string]any
+	}{
+		{"no encontrada", "GET", "/tasks/99", "", map[string]any{
+			"type": "/problems/task_not_found", "title": "Tarea no encontrada", "status": 404.0,
+			"detail": "tarea con id 99 no encontrada", "instance": "/tasks/99", "code": "task_not_found",
+		}},
+		{"ID inválido", "GET", "/tasks/abc", "", map[string]any{
+			"type": "/problems/invalid_id", "title": "ID inválido", "status": 400.0,
+			"detail": "ID inválido", "instance": "/tasks/abc", "code": "invalid_id",
+		}},
+		{"JSON inválido", "POST", "/tasks", "{", map[string]any{
+			"type": "/problems/invalid_json", "title": "JSON inválido", "status": 400.0,
+			"detail": "JSON inválido", "instance": "/tasks", "code": "invalid_json",
+		}},
+		{"método", "DELETE", "/search", "", map[string]any{
+			"type": "/problems/method_not_allowed", "title": "Método no permitido", "status": 405.0,
+			"detail": "método no permitido", "instance": "/search", "code": "method_not_allowed",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if got := w.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("Content-Type = %q; want %q", got, problemContentType)
+			}
+			if w.Code != int(tt.want["status"].(float64)) {
+				t.Errorf("status HTTP = %d; want %v", w.Code, tt.want["status"])
+			}
+			// Sin errores por campo, "errors" no aparece.
+			if got := decodeBody(t, w); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problema = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationProblem(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+	tests := []struct {
+		name, lang, body string
+		wantErrors       []*fieldError
+	}{
+		{"título vacío", "", `{"title":"  "}`, []*fieldError{
+			{Field: "title", Code: codeTitleEmpty, Detail: "title no puede estar vacío"},
+		}},
+		{"título vacío en inglés", "en", `{"title":""}`, []*fieldError{
+			{Field: "title", Code: codeTitleEmpty, Detail: "title must not be empty"},
+		}},
+		{"título largo", "", `{"title":"` + strings.Repeat("a", 300) + `"}`, []*fieldError{
+			{Field: "title", Code: codeTitleTooLong, Detail: "title demasiado largo (máx 200)"},
+		}},
+		{"columna sin proyecto", "", `{"title":"a","column":"todo"}`, []*fieldError{
+			{Field: "project_id", Code: codeProjectRequired, Detail: "la tarea no pertenece a ningún proyecto; indica project_id"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/tasks", strings.NewReader(tt.body))
+			r.Header.Set("Accept-Language", tt.lang)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d; want 400", w.Code)
+			}
+			var p problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != codeValidationFailed || p.Type != problemTypeBase+codeValidationFailed || p.Status != http.StatusBadRequest {
+				t.Errorf("problema = %+v; want code %q", p, codeValidationFailed)
+			}
+			if !reflect.DeepEqual(p.Errors, tt.wantErrors) {
+				t.Errorf("errors = %+v; want %+v", p.Errors, tt.wantErrors)
+			}
+			// El detalle general es el del primer campo.
+			if p.Detail != tt.wantErrors[0].Detail {
+				t.Errorf("detail = %q; want %q", p.Detail, tt.wantErrors[0].Detail)
+			}
+		})
+	}
+}
+
+func TestWriteValidationErrorMixed(t *testing.T) {
+	// Un error que no es *fieldError se informa sin campo.
+	w := httptest.NewRecorder()
+	writeValidationError(w, httptest.NewRequest("POST", "/tasks", nil),
+		newFieldError("title", codeTitleEmpty), errors.New("otra cosa"))
+	var p problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := []*fieldError{
+		{Field: "title", Code: codeTitleEmpty, Detail: "title no puede estar vacío"},
+		{Code: codeValidationFailed, Detail: "otra cosa"},
+	}
+	if !reflect.DeepEqual(p.Errors, want) {
+		t.Errorf("errors = %+v; want %+v", p.Errors, want)
+	}
+}
+
+func TestLegacyErrors(t *testing.T) {
+	saved := legacyErrors
+	legacyErrors = true
+	t.Cleanup(func() { legacyErrors = saved })
+
+	h := newServer(newTaskStore()).routes()
+	tests := []struct {
+		name, method, path, body, lang string
+		wantStatus                     int
+		wantError                      string
+	}{
+		{"no encontrada", "GET", "/tasks/99", "", "", http.StatusNotFound, "tarea con id 99 no encontrada"},
+		{"no encontrada en inglés", "GET", "/tasks/99", "", "en", http.StatusNotFound, "task with id 99 not found"},
+		{"validación", "POST", "/tasks", `{"title":""}`, "", http.StatusBadRequest, "title no puede estar vacío"},
+		{"método", "PUT", "/tasks", "", "", http.StatusMethodNotAllowed, "método no permitido"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Accept-Language", tt.lang)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q; want JSON normal", got)
+			}
+			// Solo {"error": "..."}: ni code ni los campos de RFC 9457.
+			if got, want := decodeBody(t, w), map[string]any{"error": tt.wantError}; !reflect.DeepEqual(got, want) {
+				t.Errorf("cuerpo = %v; want %v", got, want)
+			}
+		})
+	}
+}
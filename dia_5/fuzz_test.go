@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Objetivos de fuzzing; sin -fuzz solo se ejecutan las semillas:
+//
+//	go test -fuzz FuzzCreateTaskBody -fuzztime 30s
+//	go test -fuzz FuzzTaskIDPath -fuzztime 30s
+
+// FuzzCreateTaskBody manda cuerpos arbitrarios a POST /tasks y comprueba
+// que la respuesta sea coherente con lo que el cuerpo decía.
+func FuzzCreateTaskBody(f *testing.F) {
+	seeds := []string{
+		`{"title":"Aprender Go"}`,
+		`{"title":"a","done":true,"due_date":"2026-05-01T09:00:00Z"}`,
+		`{"title":"a","project_id":1,"column":"doing"}`,
+		`{"title":"a","column":"doing"}`,
+		`{"title":"   "}`,
+		`{"title":"a","priority":1}`,
+		`{"title":"a"}{"title":"b"}`,
+		`{"title":"` + strings.Repeat("é", 150) + `"}`,
+		`{"due_date":"mañana"}`,
+		`[]`, `null`, ``, `{`, "\xff",
+	}
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		srv := newServer(newTaskStore())
+		srv.store.mu.Lock()
+		srv.store.createProjectLocked(Project{Name: "Web", Columns: defaultColumns})
+		srv.store.mu.Unlock()
+
+		w := httptest.NewRecorder()
+		srv.tasksHandler(w, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(string(body))))
+
+		switch w.Code {
+		case http.StatusCreated:
+			var got Task
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("201 con cuerpo no JSON: %v", err)
+			}
+			var in taskInput
+			if err := json.Unmarshal(body, &in); err != nil {
+				t.Fatalf("201 para un cuerpo que no es un taskInput: %v", err)
+			}
+			if got.Title != in.Title || validateTitle(got.Title) != nil {
+				t.Errorf("título = %q; want %q válido", got.Title, in.Title)
+			}
+			if got.ID != 1 || got.Done || w.Header().Get("Location") != "/tasks/1" {
+				t.Errorf("tarea %+v, Location %q", got, w.Header().Get("Location"))
+			}
+			if (in.ProjectID != nil) != (got.ProjectID != 0) || (got.ProjectID != 0 && got.Column == "") {
+				t.Errorf("proyecto %d columna %q para project_id %v", got.ProjectID, got.Column, in.ProjectID)
+			}
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+			var p problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil || p.Code == "" || p.Status != w.Code {
+				t.Errorf("problema %+v (%v)", p, err)
+			}
+			srv.store.mu.RLock()
+			n := len(srv.store.tasks)
+			srv.store.mu.RUnlock()
+			if n != 0 {
+				t.Errorf("rechazada con %d, pero se creó la tarea", w.Code)
+			}
+		default:
+			t.Fatalf("status = %d; want 201, 400 o 413\ncuerpo: %q", w.Code, body)
+		}
+	})
+}
+
+// FuzzTaskIDPath manda rutas /tasks/{lo que sea} y comprueba que el ID se
+// interprete igual en el handler y en el registro de auditoría.
+func FuzzTaskIDPath(f *testing.F) {
+	for _, s := range []string{"1", "2", "0", "-1", "abc", "", "1/", "1/move", "1/attachments", "+1", "01", " 1", "99999999999999999999", "1/x/y"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, rest string) {
+		srv := newServer(newTaskStore())
+		srv.store.mu.Lock()
+		srv.store.createLocked(Task{Title: "a"})
+		srv.store.mu.Unlock()
+
+		// La ruta se pone a mano: httptest.NewRequest no acepta cualquier
+		// texto como URL.
+		r := httptest.NewRequest(http.MethodGet, "/tasks/", nil)
+		r.URL.Path = "/tasks/" + rest
+		w := httptest.NewRecorder()
+		srv.taskByIDHandler(w, r)
+
+		idStr, sub, _ := strings.Cut(rest, "/")
+		id, err := strconv.Atoi(idStr)
+		if got := auditTaskID(r.URL.Path); err == nil && got != id || err != nil && got != 0 {
+			t.Errorf("auditTaskID(%q) = %d; want %d", r.URL.Path, got, id)
+		}
+		var want int
+		switch {
+		case idStr == "" || err != nil:
+			want = http.StatusBadRequest
+		case sub == "move":
+			want = http.StatusMethodNotAllowed
+		case strings.Contains(rest, "/"):
+			want = http.StatusNotFound // subrutas desconocidas, o adjuntos sin blobs
+		case id == 1:
+			want = http.StatusOK
+		default:
+			want = http.StatusNotFound
+		}
+		if w.Code != want {
+			t.Fatalf("GET /tasks/%s: status = %d; want %d", rest, w.Code, want)
+		}
+		if want == http.StatusOK {
+			var got Task
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got.ID != 1 {
+				t.Errorf("tarea %+v (%v); want la 1", got, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Chequeos de salud:
+//   - /healthz (liveness): el proceso está vivo y responde.
+//   - /readyz (readiness): se ejecutan los chequeos registrados; si alguno
+//     falla, o el servidor se está apagando, responde 503 para que el
+//     balanceador deje de enviarle tráfico.
+
+// defaultCheckTimeout es el tiempo máximo de un chequeo si no se indica otro.
+const defaultCheckTimeout = 2 * time.Second
+
+// healthCheck es una comprobación de una dependencia.
+type healthCheck struct {
+	name    string
+	timeout time.Duration
+	check   func(ctx context.Context) error
+}
+
+var (
+	checksMu     sync.Mutex
+	healthChecks []healthCheck
+
+	// draining se activa al recibir la señal de apagado.
+	draining atomic.Bool
+)
+
+// registerCheck añade un chequeo a /readyz.
+func registerCheck(name string, timeout time.Duration, check func(ctx context.Context) error) {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	healthChecks = append(healthChecks, healthCheck{name: name, timeout: timeout, check: check})
+}
+
+// checkResult es el resultado de un chequeo en la respuesta de /readyz.
+type checkResult struct {
+	Status     string `json:"status"` // "ok" o "fail"
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// runChecks ejecuta todos los chequeos en paralelo, cada uno con su timeout.
+func runChecks(ctx context.Context) (map[string]checkResult, bool) {
+	checksMu.Lock()
+	checks := append([]healthCheck(nil), healthChecks...)
+	checksMu.Unlock()
+
+	results := make(map[string]checkResult, len(checks))
+	var (
+		wg      sync.WaitGroup
+		resMu   sync.Mutex
+		healthy = true
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := runCheck(ctx, c)
+			resMu.Lock()
+			defer resMu.Unlock()
+			results[c.name] = res
+			if res.Status != "ok" {
+				healthy = false
+			}
+		}()
+	}
+	wg.Wait()
+	return results, healthy
+}
+
+// runCheck ejecuta un chequeo y lo da por fallido si supera su timeout,
+// aunque la función no respete el contexto.
+func runCheck(ctx context.Context, c healthCheck) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New("timeout tras " + c.timeout.String())
+	}
+	res := checkResult{Status: "ok", DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = "fail"
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// healthzHandler maneja /healthz: si responde, el proceso está vivo.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// readyzHandler maneja /readyz con el detalle de cada chequeo.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w, r, "GET, HEAD")
+		return
+	}
+
+	results, healthy := runChecks(r.Context())
+	status, code := "ok", http.StatusOK
+	switch {
+	case draining.Load():
+		status, code = "draining", http.StatusServiceUnavailable
+	case !healthy:
+		status, code = "fail", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": results})
+}
+
+// checkStorage comprueba que el almacenamiento acepta escrituras: la
+// última persistencia no falló y se puede crear un fichero en su directorio.
+func (s *taskStore) checkStorage(ctx context.Context) error {
+	s.mu.RLock()
+	path, lastErr := s.path, s.lastPersistErr
+	s.mu.RUnlock()
+	if path == "" {
+		return nil // solo memoria
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// withChecks reemplaza los chequeos registrados durante un test.
+func withChecks(t *testing.T, checks ...healthCheck) {
+	t.Helper()
+	checksMu.Lock()
+	saved := healthChecks
+	healthChecks = checks
+	checksMu.Unlock()
+	t.Cleanup(func() {
+		checksMu.Lock()
+		healthChecks = saved
+		checksMu.Unlock()
+		draining.Store(false)
+	})
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	broken := func(context.Context) error { return errors.New("disco lleno") }
+	stuck := func(context.Context) error { time.Sleep(time.Second); return nil }
+
+	tests := []struct {
+		name       string
+		checks     []healthCheck
+		draining   bool
+		wantStatus int
+		wantState  string
+		wantFailed string
+	}{
+		{"todo bien", []healthCheck{{"a", time.Second, ok}}, false, http.StatusOK, "ok", ""},
+		{"chequeo que falla", []healthCheck{{"a", time.Second, ok}, {"storage", time.Second, broken}}, false, http.StatusServiceUnavailable, "fail", "storage"},
+		{"chequeo que no responde", []healthCheck{{"lento", 20 * time.Millisecond, stuck}}, false, http.StatusServiceUnavailable, "fail", "lento"},
+		{"drenando", []healthCheck{{"a", time.Second, ok}}, true, http.StatusServiceUnavailable, "draining", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withChecks(t, tt.checks...)
+			draining.Store(tt.draining)
+
+			w := httptest.NewRecorder()
+			readyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var body struct {
+				Status string
+				Checks map[string]checkResult
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.wantStatus || body.Status != tt.wantState {
+				t.Errorf("got %d %q; want %d %q", w.Code, body.Status, tt.wantStatus, tt.wantState)
+			}
+			if len(body.Checks) != len(tt.checks) {
+				t.Errorf("checks = %v", body.Checks)
+			}
+			if tt.wantFailed != "" && body.Checks[tt.wantFailed].Status != "fail" {
+				t.Errorf("%s debería fallar: %v", tt.wantFailed, body.Checks)
+			}
+		})
+	}
+}
+
+func TestHealthzAlwaysOK(t *testing.T) {
+	withChecks(t, healthCheck{"roto", time.Second, func(context.Context) error { return errors.New("x") }})
+	w := httptest.NewRecorder()
+	healthzHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d; want 200", w.Code)
+	}
+}
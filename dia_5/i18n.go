@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catálogo de mensajes de la API en español e inglés, indexado por el code
+// estable de cada error. El idioma se elige con la cabecera Accept-Language.
+
+// defaultLang es el idioma por defecto cuando el cliente no pide otro.
+const defaultLang = "es"
+
+// message es el texto localizado de un code: un título fijo y un detalle
+// que puede llevar verbos de fmt (%d, %s...).
+type message struct {
+	Title  string
+	Detail string
+}
+
+// catalog contiene los mensajes por idioma y code. Todo code debe existir
+// en todos los idiomas (lo comprueba i18n_test.go).
+var catalog = map[string]map[string]message{
+	"es": {
+		codeMethodNotAllowed:        {"Método no permitido", "método no permitido"},
+		codeIDRequired:              {"ID requerido", "ID requerido"},
+		codeInvalidID:               {"ID inválido", "ID inválido"},
+		codeTaskNotFound:            {"Tarea no encontrada", "tarea con id %d no encontrada"},
+		codeInvalidJSON:             {"JSON inválido", "JSON inválido"},
+		codeValidationFailed:        {"Datos inválidos", "la petición contiene campos inválidos"},
+		codeTitleEmpty:              {"Título vacío", "title no puede estar vacío"},
+		codeTitleTooLong:            {"Título demasiado largo", "title demasiado largo (máx %d)"},
+		codeQueryRequired:           {"Consulta requerida", "parámetro q requerido"},
+		codeInvalidLimit:            {"Límite inválido", "limit inválido"},
+		codeCalendarNotFound:        {"Calendario no encontrado", "calendario no encontrado"},
+		codeInvalidRevision:         {"Revisión inválida", "since debe ser un entero mayor o igual que 0"},
+		codeRevisionAhead:           {"Revisión futura", "since es mayor que la revisión actual (%d)"},
+		codeRevisionTooOld:          {"Revisión demasiado antigua", "los cambios desde la revisión %d ya no están disponibles; vuelve a leer /tasks"},
+		codeInvalidWait:             {"Espera inválida", "wait debe ser una duración entre 0 y %s"},
+		codeReadOnlyReplica:         {"Réplica de solo lectura", "esta instancia es una réplica de solo lectura; envía las escrituras al líder %s"},
+		codeAdminUnauthorized:       {"No autorizado", "se requiere el token de administración (Authorization: Bearer ...)"},
+		codeReplicationUnauthorized: {"No autorizado", "se requiere el token de replicación (Authorization: Bearer ...)"},
+		codeInvalidBackup:           {"Copia de seguridad inválida", "archivo de copia de seguridad inválido: %s"},
+		codeBackupTooNew:            {"Formato de copia no soportado", "la copia usa el formato %d y este servidor solo entiende hasta el %d"},
+		codeBackupChecksum:          {"Checksum incorrecto", "el checksum no coincide: el archivo está dañado o fue modificado"},
+		codeBackupMissingBlob:       {"Falta un adjunto", "la copia no incluye el contenido del adjunto %s y este servidor tampoco lo tiene"},
+		codeBodyTooLarge:            {"Cuerpo demasiado grande", "el cuerpo de la petición supera el máximo de %d bytes"},
+		codeInvalidMultipart:        {"Formulario inválido", "el cuerpo debe ser multipart/form-data válido"},
+		codeFileRequired:            {"Fichero requerido", "falta el fichero en el campo file"},
+		codeAttachmentNotFound:      {"Adjunto no encontrado", "adjunto con id %d no encontrado"},
+		codeInternalError:           {"Error interno", "error interno del servidor"},
+		codeAuditFailed:             {"Error de auditoría", "el cambio no se pudo registrar en la auditoría"},
+		codeQuotaExceeded:           {"Cuota superada", "has alcanzado el máximo de %d tareas por usuario"},
+		codeProjectNotFound:         {"Proyecto no encontrado", "proyecto con id %d no encontrado"},
+		codeProjectRequired:         {"Proyecto requerido", "la tarea no pertenece a ningún proyecto; indica project_id"},
+		codeNameEmpty:               {"Nombre vacío", "name no puede estar vacío"},
+		codeInvalidColumns:          {"Columnas inválidas", "las columnas necesitan nombres distintos y no vacíos, y wip_limit no puede ser negativo"},
+		codeUnknownColumn:           {"Columna desconocida", "la columna %q no existe en el proyecto"},
+		codeInvalidPosition:         {"Posición inválida", "position debe ser 0 (al final) o mayor"},
+		codeWIPLimitExceeded:        {"Límite WIP alcanzado", "la columna %q ya tiene el máximo de %d tareas"},
+		codeInvalidRPCRequest:       {"Petición JSON-RPC inválida", "la petición no es un objeto JSON-RPC 2.0 válido"},
+		codeRPCMethodNotFound:       {"Método desconocido", "método %q desconocido"},
+		codeInvalidParams:           {"Parámetros inválidos", "params debe ser un objeto con los campos del método"},
+		codeProjectNotEmpty:         {"Proyecto con tareas", "el proyecto tiene tareas; muévelas o bórralas antes de borrarlo"},
+		codeCSRFInvalid:             {"Token CSRF inválido", "el formulario caducó o no viene de esta página; recárgala y vuelve a intentarlo"},
+	},
+	"en": {
+		codeMethodNotAllowed:        {"Method not allowed", "method not allowed"},
+		codeIDRequired:              {"ID required", "ID required"},
+		codeInvalidID:               {"Invalid ID", "invalid ID"},
+		codeTaskNotFound:            {"Task not found", "task with id %d not found"},
+		codeInvalidJSON:             {"Invalid JSON", "invalid JSON"},
+		codeValidationFailed:        {"Invalid data", "the request contains invalid fields"},
+		codeTitleEmpty:              {"Empty title", "title must not be empty"},
+		codeTitleTooLong:            {"Title too long", "title too long (max %d)"},
+		codeQueryRequired:           {"Query required", "query parameter q is required"},
+		codeInvalidLimit:            {"Invalid limit", "invalid limit"},
+		codeCalendarNotFound:        {"Calendar not found", "calendar not found"},
+		codeInvalidRevision:         {"Invalid revision", "since must be an integer greater than or equal to 0"},
+		codeRevisionAhead:           {"Revision ahead", "since is greater than the current revision (%d)"},
+		codeRevisionTooOld:          {"Revision too old", "changes since revision %d are no longer available; reload /tasks"},
+		codeInvalidWait:             {"Invalid wait", "wait must be a duration between 0 and %s"},
+		codeReadOnlyReplica:         {"Read-only replica", "this instance is a read-only replica; send writes to the leader at %s"},
+		codeAdminUnauthorized:       {"Unauthorized", "the admin token is required (Authorization: Bearer ...)"},
+		codeReplicationUnauthorized: {"Unauthorized", "the replication token is required (Authorization: Bearer ...)"},
+		codeInvalidBackup:           {"Invalid backup", "invalid backup archive: %s"},
+		codeBackupTooNew:            {"Unsupported backup format", "the backup uses format %d but this server only understands up to %d"},
+		codeBackupChecksum:          {"Checksum mismatch", "the checksum does not match: the archive is damaged or was modified"},
+		codeBackupMissingBlob:       {"Missing attachment", "the backup does not include the content of attachment %s and this server does not have it either"},
+		codeBodyTooLarge:            {"Body too large", "the request body exceeds the maximum of %d bytes"},
+		codeInvalidMultipart:        {"Invalid form", "the body must be valid multipart/form-data"},
+		codeFileRequired:            {"File required", "the file field is missing"},
+		codeAttachmentNotFound:      {"Attachment not found", "attachment with id %d not found"},
+		codeInternalError:           {"Internal error", "internal server error"},
+		codeAuditFailed:             {"Audit failure", "the change could not be recorded in the audit log"},
+		codeQuotaExceeded:           {"Quota exceeded", "you have reached the maximum of %d tasks per user"},
+		codeProjectNotFound:         {"Project not found", "project with id %d not found"},
+		codeProjectRequired:         {"Project required", "the task does not belong to any project; set project_id"},
+		codeNameEmpty:               {"Empty name", "name must not be empty"},
+		codeInvalidColumns:          {"Invalid columns", "columns need distinct, non-empty names and wip_limit must not be negative"},
+		codeUnknownColumn:           {"Unknown column", "column %q does not exist in the project"},
+		codeInvalidPosition:         {"Invalid position", "position must be 0 (at the end) or greater"},
+		codeWIPLimitExceeded:        {"WIP limit reached", "column %q already has the maximum of %d tasks"},
+		codeInvalidRPCRequest:       {"Invalid JSON-RPC request", "the request is not a valid JSON-RPC 2.0 object"},
+		codeRPCMethodNotFound:       {"Unknown method", "unknown method %q"},
+		codeInvalidParams:           {"Invalid params", "params must be an object with the method's fields"},
+		codeProjectNotEmpty:         {"Project has tasks", "the project has tasks; move or delete them before deleting it"},
+		codeCSRFInvalid:             {"Invalid CSRF token", "the form expired or did not come from this page; reload it and try again"},
+	},
+}
+
+// localize devuelve el mensaje de code en lang, con el detalle ya
+// formateado con args. Si falta la traducción usa el idioma por defecto.
+func localize(lang, code string, args ...any) message {
+	m, ok := catalog[lang][code]
+	if !ok {
+		m = catalog[defaultLang][code]
+	}
+	if len(args) > 0 {
+		m.Detail = fmt.Sprintf(m.Detail, args...)
+	}
+	return m
+}
+
+// requestLang elige el idioma de la respuesta según Accept-Language.
+func requestLang(r *http.Request) string {
+	return negotiateLang(r.Header.Get("Accept-Language"))
+}
+
+// negotiateLang interpreta una cabecera Accept-Language (RFC 9110 §12.5.4)
+// y devuelve el idioma soportado con mayor q. Acepta etiquetas con región
+// ("en-US" → "en"); "*" o nada compatible devuelven defaultLang.
+func negotiateLang(header string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || f > 1 {
+				continue
+			}
+			q = f
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{strings.ToLower(tag), q})
+		}
+	}
+	// Orden estable: a igual q se respeta el orden del cliente.
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		if p.tag == "*" {
+			return defaultLang
+		}
+		primary, _, _ := strings.Cut(p.tag, "-")
+		if _, ok := catalog[primary]; ok {
+			return primary
+		}
+	}
+	return defaultLang
+}
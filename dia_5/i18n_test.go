@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCatalogHasEveryKeyInEveryLanguage(t *testing.T) {
+	base := catalog[defaultLang]
+	for lang, msgs := range catalog {
+		for code := range base {
+			if _, ok := msgs[code]; !ok {
+				t.Errorf("falta %q en el idioma %q", code, lang)
+			}
+		}
+		for code := range msgs {
+			if _, ok := base[code]; !ok {
+				t.Errorf("%q existe en %q pero no en %q", code, lang, defaultLang)
+			}
+		}
+	}
+}
+
+func TestCatalogVerbsMatchAcrossLanguages(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for code, want := range catalog[defaultLang] {
+		wantVerbs := strings.Join(verbs.FindAllString(want.Detail, -1), "")
+		for lang, msgs := range catalog {
+			m := msgs[code]
+			if m.Title == "" || m.Detail == "" {
+				t.Errorf("%s/%s: título o detalle vacío", lang, code)
+			}
+			if got := strings.Join(verbs.FindAllString(m.Detail, -1), ""); got != wantVerbs {
+				t.Errorf("%s/%s: verbos %q; want %q", lang, code, got, wantVerbs)
+			}
+		}
+	}
+}
+
+func TestNegotiateLang(t *testing.T) {
+	tests := []struct {
+		header, expected string
+	}{
+		{"", "es"},
+		{"en", "en"},
+		{"en-US,en;q=0.9", "en"},
+		{"fr-FR, en;q=0.8, es;q=0.9", "es"},
+		{"fr, de", "es"},
+		{"es;q=0.1, en;q=0.5", "en"},
+		{"en;q=0", "es"},
+		{"en;q=abc, es", "es"},
+		{"*", "es"},
+		{"EN-gb", "en"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateLang(tt.header); got != tt.expected {
+			t.Errorf("negotiateLang(%q) = %q; want %q", tt.header, got, tt.expected)
+		}
+	}
+}
+
+func TestWriteErrorLocalized(t *testing.T) {
+	tests := []struct {
+		acceptLanguage, expected string
+	}{
+		{"", "tarea con id 7 no encontrada"},
+		{"en-US", "task with id 7 not found"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/tasks/7", nil)
+		r.Header.Set("Accept-Language", tt.acceptLanguage)
+		w := httptest.NewRecorder()
+		writeError(w, r, http.StatusNotFound, codeTaskNotFound, 7)
+
+		var p problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Detail != tt.expected || p.Code != codeTaskNotFound {
+			t.Errorf("Accept-Language %q: detail %q code %q; want %q", tt.acceptLanguage, p.Detail, p.Code, tt.expected)
+		}
+	}
+}
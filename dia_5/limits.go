@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Límites de uso: tamaño máximo del cuerpo JSON de las peticiones y número
+// máximo de tareas por usuario.
+
+var (
+	// maxBodyBytes es el tamaño máximo del cuerpo JSON. Se configura con
+	// la opción max_body_bytes.
+	maxBodyBytes int64 = 1 << 20
+	// maxTasksPerUser es cuántas tareas puede crear cada usuario; 0 es sin
+	// límite. Se configura con la opción max_tasks_per_user.
+	maxTasksPerUser = 0
+)
+
+// errTrailingData indica que hay algo más después del objeto JSON.
+var errTrailingData = errors.New("datos después del objeto JSON")
+
+// decodeJSON lee del cuerpo exactamente un valor JSON en v: sin campos
+// desconocidos, sin datos detrás y como mucho maxBodyBytes. Si falla ya ha
+// respondido (413 o 400) y devuelve false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		switch extra := dec.Decode(&struct{}{}); extra {
+		case io.EOF:
+		case nil:
+			err = errTrailingData
+		default:
+			err = extra
+		}
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &tooLarge):
+		writeError(w, r, http.StatusRequestEntityTooLarge, codeBodyTooLarge, tooLarge.Limit)
+	default:
+		writeError(w, r, http.StatusBadRequest, codeInvalidJSON)
+	}
+	return false
+}
+
+// overQuotaLocked indica si owner ya tiene el máximo de tareas permitido.
+// Los anónimos (owner "") comparten una sola cuota: sin mTLS eso la
+// convierte en un límite global. Requiere mu tomado.
+func (s *taskStore) overQuotaLocked(owner string) bool {
+	return maxTasksPerUser > 0 && s.owners[owner] >= maxTasksPerUser
+}
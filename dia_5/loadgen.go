@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Generador de carga: "api load" lanza una mezcla de list/get/create a un
+// ritmo fijo contra una URL o contra los handlers en el mismo proceso (sin
+// red) y muestra percentiles de latencia, throughput y errores.
+//
+//	go run . load -rps 500 -duration 30s -mix list=20,get=70,create=10
+//	go run . load -url http://localhost:8080 -format json
+
+// loadOptions configura una ejecución del generador.
+type loadOptions struct {
+	URL         string         // vacío = handlers en proceso
+	RPS         int            // peticiones por segundo objetivo
+	Duration    time.Duration  // duración de la prueba
+	Concurrency int            // peticiones en vuelo como máximo
+	Mix         map[string]int // operación → peso
+	Seed        int            // tareas que se crean antes de empezar
+}
+
+const (
+	// maxLoadRPS es el ritmo máximo que admite -rps.
+	maxLoadRPS = 1_000_000
+	// minLoadTick es el intervalo mínimo entre lanzamientos: por encima de
+	// 1000 req/s se lanzan en tandas.
+	minLoadTick = time.Millisecond
+)
+
+// loadOps son las operaciones que sabe lanzar el generador.
+var loadOps = []string{"list", "get", "create"}
+
+// parseMix interpreta "list=20,get=70,create=10".
+func parseMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	total := 0
+	for _, part := range splitList(s) {
+		op, w, ok := strings.Cut(part, "=")
+		weight, err := strconv.Atoi(w)
+		if !ok || err != nil || weight < 0 || !slices.Contains(loadOps, op) {
+			return nil, fmt.Errorf("mezcla: entrada %q inválida (usa op=peso con op en %s)", part, strings.Join(loadOps, ", "))
+		}
+		mix[op] = weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, errors.New("mezcla: algún peso debe ser positivo")
+	}
+	return mix, nil
+}
+
+// handlerTransport sirve las peticiones con un http.Handler en el mismo
+// proceso, sin abrir conexiones: llama al handler y convierte lo que
+// escribe en la respuesta.
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// El handler recibe la petición como la vería el servidor.
+	sreq := req.Clone(req.Context())
+	sreq.RequestURI = req.URL.RequestURI()
+	sreq.RemoteAddr = "127.0.0.1:0"
+	if sreq.Body == nil {
+		sreq.Body = http.NoBody
+	}
+	w := &loadResponse{header: make(http.Header)}
+	t.h.ServeHTTP(w, sreq)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(w.status) + " " + http.StatusText(w.status),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.header,
+		Body:          io.NopCloser(&w.body),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// loadResponse es el http.ResponseWriter de handlerTransport: guarda la
+// respuesta en memoria.
+type loadResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *loadResponse) Header() http.Header { return w.header }
+
+func (w *loadResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *loadResponse) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// opStats son las estadísticas de una operación.
+type opStats struct {
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	P50    float64 `json:"p50_ms"`
+	P95    float64 `json:"p95_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// loadReport es el resultado de una ejecución.
+type loadReport struct {
+	Target     string             `json:"target"`
+	Duration   float64            `json:"duration_s"`
+	TargetRPS  int                `json:"target_rps"`
+	Requests   int                `json:"requests"`
+	Errors     int                `json:"errors"`
+	Dropped    int                `json:"dropped"` // no lanzadas por tener Concurrency en vuelo cuando les tocaba
+	Throughput float64            `json:"throughput_rps"`
+	ErrorRate  float64            `json:"error_rate"`
+	Ops        map[string]opStats `json:"ops"`
+}
+
+// percentile devuelve el percentil p (0-100) de lat, que debe estar ordenado.
+func percentile(lat []time.Duration, p float64) time.Duration {
+	if len(lat) == 0 {
+		return 0
+	}
+	i := int(p/100*float64(len(lat))+0.5) - 1
+	return lat[min(max(i, 0), len(lat)-1)]
+}
+
+// ms pasa una duración a milisegundos.
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// sample es el resultado de una petición.
+type sample struct {
+	op      string
+	latency time.Duration
+	failed  bool
+}
+
+// loadRunner lanza las peticiones de una ejecución.
+type loadRunner struct {
+	opts   loadOptions
+	base   string
+	client *http.Client
+	maxID  atomic.Int64 // último ID de tarea conocido, para get
+}
+
+// newLoadRunner prepara un generador contra opts.URL o, si está vacía,
+// contra un servidor en proceso con un almacén en memoria.
+func newLoadRunner(opts loadOptions) *loadRunner {
+	lr := &loadRunner{opts: opts, base: strings.TrimSuffix(opts.URL, "/")}
+	if opts.URL == "" {
+		lr.base = "http://in-process"
+		lr.client = &http.Client{Transport: handlerTransport{newServer(newTaskStore()).routes()}}
+	} else {
+		lr.client = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency},
+		}
+	}
+	return lr
+}
+
+// do lanza una operación y mide su latencia.
+func (lr *loadRunner) do(ctx context.Context, op string) sample {
+	var req *http.Request
+	switch op {
+	case "list":
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, lr.base+"/tasks", nil)
+	case "get":
+		id := int64(1)
+		if n := lr.maxID.Load(); n > 1 {
+			id = rand.Int64N(n) + 1
+		}
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, lr.base+"/tasks/"+strconv.FormatInt(id, 10), nil)
+	case "create":
+		req, _ = http.NewRequestWithContext(ctx, http.MethodPost, lr.base+"/tasks", strings.NewReader(`{"title":"carga"}`))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := lr.client.Do(req)
+	s := sample{op: op, failed: err != nil}
+	if err == nil {
+		if op == "create" && resp.StatusCode == http.StatusCreated {
+			var t Task
+			if json.NewDecoder(resp.Body).Decode(&t) == nil {
+				lr.noteID(int64(t.ID))
+			}
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		s.failed = resp.StatusCode >= 400
+	}
+	s.latency = time.Since(start)
+	return s
+}
+
+// noteID actualiza el último ID conocido.
+func (lr *loadRunner) noteID(id int64) {
+	for {
+		cur := lr.maxID.Load()
+		if id <= cur || lr.maxID.CompareAndSwap(cur, id) {
+			return
+		}
+	}
+}
+
+// pick elige una operación según los pesos de la mezcla.
+func (lr *loadRunner) pick() string {
+	total := 0
+	for _, w := range lr.opts.Mix {
+		total += w
+	}
+	n := rand.IntN(total)
+	for _, op := range loadOps {
+		if n < lr.opts.Mix[op] {
+			return op
+		}
+		n -= lr.opts.Mix[op]
+	}
+	return loadOps[0]
+}
+
+// run ejecuta la prueba: primero crea Seed tareas y después lanza RPS
+// peticiones por segundo durante Duration (bucle abierto: el ritmo no
+// depende de lo que tarde el servidor).
+//
+// En cada tick se lanzan las que tocan según el tiempo transcurrido, no una
+// por tick: así se pueden pedir más de 1000 req/s, y si el ticker pierde
+// ticks (el ticker los descarta cuando el bucle va con retraso) esas
+// peticiones se lanzan en el siguiente o cuentan como descartadas.
+func (lr *loadRunner) run(ctx context.Context) (loadReport, error) {
+	for range lr.opts.Seed {
+		if s := lr.do(ctx, "create"); s.failed {
+			return loadReport{}, errors.New("no se pudieron crear las tareas iniciales")
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		samples []sample
+		wg      sync.WaitGroup
+		dropped int
+	)
+	slots := make(chan struct{}, lr.opts.Concurrency)
+	tick := time.NewTicker(max(time.Second/time.Duration(lr.opts.RPS), minLoadTick))
+	defer tick.Stop()
+	ctx, cancel := context.WithTimeout(ctx, lr.opts.Duration)
+	defer cancel()
+
+	start := time.Now()
+	launched := 0 // lanzadas o descartadas
+loop:
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			break loop
+		case now = <-tick.C:
+		}
+		due := int(now.Sub(start).Seconds()*float64(lr.opts.RPS)) - launched
+		for range due {
+			launched++
+			select {
+			case slots <- struct{}{}:
+			default:
+				dropped++
+				continue
+			}
+			wg.Add(1)
+			go func(op string) {
+				defer wg.Done()
+				s := lr.do(context.Background(), op)
+				<-slots
+				mu.Lock()
+				samples = append(samples, s)
+				mu.Unlock()
+			}(lr.pick())
+		}
+	}
+	wg.Wait()
+	return lr.report(samples, dropped, time.Since(start)), nil
+}
+
+// report agrega las muestras.
+func (lr *loadRunner) report(samples []sample, dropped int, elapsed time.Duration) loadReport {
+	target := lr.opts.URL
+	if target == "" {
+		target = "en proceso"
+	}
+	rep := loadReport{
+		Target:    target,
+		Duration:  elapsed.Seconds(),
+		TargetRPS: lr.opts.RPS,
+		Requests:  len(samples),
+		Dropped:   dropped,
+		Ops:       make(map[string]opStats),
+	}
+	byOp := make(map[string][]time.Duration)
+	for _, s := range samples {
+		st := rep.Ops[s.op]
+		st.Count++
+		if s.failed {
+			st.Errors++
+			rep.Errors++
+		}
+		rep.Ops[s.op] = st
+		byOp[s.op] = append(byOp[s.op], s.latency)
+	}
+	for op, lat := range byOp {
+		slices.Sort(lat)
+		st := rep.Ops[op]
+		st.P50, st.P95, st.P99 = ms(percentile(lat, 50)), ms(percentile(lat, 95)), ms(percentile(lat, 99))
+		st.Max = ms(lat[len(lat)-1])
+		rep.Ops[op] = st
+	}
+	if elapsed > 0 {
+		rep.Throughput = float64(rep.Requests) / elapsed.Seconds()
+	}
+	if rep.Requests > 0 {
+		rep.ErrorRate = float64(rep.Errors) / float64(rep.Requests)
+	}
+	return rep
+}
+
+// writeText escribe el informe en formato legible.
+func (rep loadReport) writeText(w io.Writer) {
+	fmt.Fprintf(w, "objetivo: %s, %.1fs a %d req/s\n", rep.Target, rep.Duration, rep.TargetRPS)
+	fmt.Fprintf(w, "peticiones: %d (%.1f req/s), errores: %d (%.2f%%), descartadas: %d\n",
+		rep.Requests, rep.Throughput, rep.Errors, rep.ErrorRate*100, rep.Dropped)
+	fmt.Fprintf(w, "%-8s %8s %8s %10s %10s %10s %10s\n", "op", "n", "errores", "p50 ms", "p95 ms", "p99 ms", "max ms")
+	for _, op := range loadOps {
+		st, ok := rep.Ops[op]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%-8s %8d %8d %10.2f %10.2f %10.2f %10.2f\n", op, st.Count, st.Errors, st.P50, st.P95, st.P99, st.Max)
+	}
+}
+
+// runLoad implementa "api load".
+func runLoad(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	fs.SetOutput(out)
+	url := fs.String("url", "", "URL del servidor (vacío = handlers en proceso)")
+	rps := fs.Int("rps", 200, "peticiones por segundo (hasta "+strconv.Itoa(maxLoadRPS)+")")
+	duration := fs.Duration("duration", 10*time.Second, "duración de la prueba")
+	concurrency := fs.Int("concurrency", 64, "peticiones en vuelo como máximo")
+	mix := fs.String("mix", "list=20,get=70,create=10", "mezcla de operaciones (op=peso)")
+	seed := fs.Int("seed", 100, "tareas que se crean antes de empezar")
+	format := fs.String("format", "text", `formato del informe: "text" o "json"`)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	m, err := parseMix(*mix)
+	switch {
+	case err != nil:
+	case *rps < 1 || *concurrency < 1 || *duration <= 0 || *seed < 0:
+		err = errors.New("rps, concurrency y duration deben ser positivos")
+	case *rps > maxLoadRPS:
+		err = fmt.Errorf("rps %d demasiado alto (máximo %d)", *rps, maxLoadRPS)
+	case *format != "text" && *format != "json":
+		err = fmt.Errorf("formato %q desconocido", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+
+	lr := newLoadRunner(loadOptions{URL: *url, RPS: *rps, Duration: *duration, Concurrency: *concurrency, Mix: m, Seed: *seed})
+	rep, err := lr.run(context.Background())
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	} else {
+		rep.writeText(out)
+	}
+	return 0
+}
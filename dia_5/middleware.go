@@ -0,0 +1,242 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Middlewares HTTP: cada uno envuelve un http.Handler y devuelve otro,
+// así se pueden encadenar en main.
+
+// corsOptions configura el middleware CORS.
+type corsOptions struct {
+	Origins     []string // orígenes permitidos; "*" permite cualquiera
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      time.Duration // caché de la respuesta preflight
+}
+
+// allowsOrigin indica si origin está en la lista permitida.
+func (o corsOptions) allowsOrigin(origin string) bool {
+	return slices.Contains(o.Origins, "*") || slices.Contains(o.Origins, origin)
+}
+
+// withCORS añade las cabeceras CORS para los orígenes permitidos y
+// responde a las peticiones preflight (OPTIONS) sin llegar al handler.
+func withCORS(opts corsOptions, next http.Handler) http.Handler {
+	methods := strings.Join(opts.Methods, ", ")
+	headers := strings.Join(opts.Headers, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !opts.allowsOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r) // sin cabeceras CORS: el navegador bloqueará la respuesta
+			return
+		}
+
+		// Con credenciales no se puede responder "*": se refleja el origen.
+		if slices.Contains(opts.Origins, "*") && !opts.Credentials {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if opts.Credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", "Location, X-Tasks-Revision")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight: comprobar método y cabeceras solicitados.
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !containsFold(opts.Methods, r.Header.Get("Access-Control-Request-Method")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if h = strings.TrimSpace(h); h != "" && !containsFold(opts.Headers, h) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		if headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		if opts.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// containsFold busca s en list sin distinguir mayúsculas.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// withCompression comprime con gzip o deflate (según Accept-Encoding) las
+// respuestas de al menos minSize bytes. Las más pequeñas se envían tal cual.
+func withCompression(minSize int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+		defer cw.finish()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding elige "gzip", "deflate" o "" (sin comprimir) según la
+// cabecera Accept-Encoding y sus valores q. A igual q se prefiere gzip.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if name == "*" {
+			name = "gzip"
+		}
+		if name != "gzip" && name != "deflate" {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "gzip") {
+			best, bestQ = name, q
+		}
+	}
+	if bestQ <= 0 {
+		return ""
+	}
+	return best
+}
+
+// compressWriter acumula la respuesta hasta saber si supera minSize; en
+// ese momento decide si comprimir y empieza a escribir.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buf      []byte
+	decided  bool
+	enc      io.WriteCloser // no nil si se está comprimiendo
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide envía las cabeceras y lo acumulado, comprimido si corresponde.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+	// Las respuestas parciales (Range) no se comprimen: Content-Range se
+	// refiere a los bytes sin comprimir.
+	compress := len(cw.buf) >= cw.minSize &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		cw.status != http.StatusPartialContent
+	if compress {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if cw.encoding == "gzip" {
+			cw.enc = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			// "deflate" en HTTP es el formato zlib (RFC 9110 §8.4.1.2).
+			cw.enc = zlib.NewWriter(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.Write(buf)
+	return err
+}
+
+// Flush permite respuestas en streaming: fuerza la decisión y vacía el
+// compresor si lo hay.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap expone el ResponseWriter original a http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// finish completa la respuesta al terminar el handler.
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	opts := corsOptions{
+		Origins: []string{"https://dashboard.example"},
+		Methods: []string{"GET", "POST"},
+		Headers: []string{"Content-Type"},
+		MaxAge:  10 * time.Minute,
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := withCORS(opts, ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantOrigin  string
+		wantMaxAge  string
+		wantMethods string
+	}{
+		{
+			name:       "sin Origin no toca nada",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "origen permitido",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://dashboard.example"},
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://dashboard.example",
+		},
+		{
+			name:       "origen no permitido",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Origin": "https://evil.example"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "preflight válido",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "content-type",
+			},
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "https://dashboard.example",
+			wantMaxAge:  "600",
+			wantMethods: "GET, POST",
+		},
+		{
+			name:   "preflight con método no permitido",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://dashboard.example",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wantStatus: http.StatusForbidden,
+			wantOrigin: "https://dashboard.example",
+		},
+		{
+			name:   "preflight con cabecera no permitida",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://dashboard.example",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Secret",
+			},
+			wantStatus: http.StatusForbidden,
+			wantOrigin: "https://dashboard.example",
+		},
+		{
+			name:   "preflight de origen no permitido",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "https://evil.example",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/tasks", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Allow-Origin = %q; want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Max-Age = %q; want %q", got, tt.wantMaxAge)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Allow-Methods = %q; want %q", got, tt.wantMethods)
+			}
+		})
+	}
+}
+
+func TestCORSDefaults(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.CORSOrigins = "https://dashboard.example"
+	h := withCORS(cfg.corsOptions(), http.NotFoundHandler())
+
+	// Los métodos de las rutas de proyectos y adjuntos pasan el preflight.
+	for _, method := range []string{"GET", "POST", "PATCH", "DELETE"} {
+		r := httptest.NewRequest(http.MethodOptions, "/projects/1", nil)
+		r.Header.Set("Origin", "https://dashboard.example")
+		r.Header.Set("Access-Control-Request-Method", method)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("preflight %s: status = %d; want 204", method, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	r.Header.Set("Origin", "https://dashboard.example")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got, want := w.Header().Get("Access-Control-Expose-Headers"), "Location, X-Tasks-Revision"; got != want {
+		t.Errorf("Expose-Headers = %q; want %q", got, want)
+	}
+}
+
+func TestCORSWildcardWithCredentialsReflectsOrigin(t *testing.T) {
+	h := withCORS(corsOptions{Origins: []string{"*"}, Credentials: true}, http.NotFoundHandler())
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://a.example")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://a.example" {
+		t.Errorf("Allow-Origin = %q; want el origen reflejado", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("falta Allow-Credentials")
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header, expected string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0", ""},
+		{"br", ""},
+		{"*", "gzip"},
+		{"identity", ""},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.expected {
+			t.Errorf("negotiateEncoding(%q) = %q; want %q", tt.header, got, tt.expected)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	big := strings.Repeat(`{"id":1,"title":"Aprender Go"},`, 100)
+	small := "pong"
+	handler := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			// Escribir en trozos para probar el buffer.
+			for i := 0; i < len(body); i += 100 {
+				io.WriteString(w, body[i:min(i+100, len(body))])
+			}
+		})
+	}
+
+	tests := []struct {
+		name, acceptEncoding, body, wantEncoding string
+	}{
+		{"gzip grande", "gzip", big, "gzip"},
+		{"deflate grande", "deflate", big, "deflate"},
+		{"pequeña sin comprimir", "gzip", small, ""},
+		{"cliente sin soporte", "", big, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			withCompression(1024, handler(tt.body)).ServeHTTP(w, r)
+
+			if w.Code != http.StatusCreated {
+				t.Errorf("status = %d; want %d", w.Code, http.StatusCreated)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q; want %q", got, tt.wantEncoding)
+			}
+			if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+				t.Errorf("falta Vary: Accept-Encoding")
+			}
+
+			compressedLen := w.Body.Len()
+			var body io.Reader = w.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			case "deflate":
+				zr, err := zlib.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("cuerpo descomprimido distinto del original")
+			}
+			if tt.wantEncoding != "" && compressedLen >= len(tt.body) {
+				t.Errorf("la respuesta comprimida (%d) no es menor que la original (%d)", compressedLen, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressionSkipsRanges(t *testing.T) {
+	content := strings.Repeat("0123456789", 500)
+	h := withCompression(100, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "log.txt", time.Time{}, strings.NewReader(content))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/tasks/1/attachments/1", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Range", "bytes=0-2999")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("got %d %q; want 206 sin comprimir", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	if w.Body.String() != content[:3000] {
+		t.Errorf("cuerpo parcial incorrecto")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// Prueba basada en modelos: secuencias aleatorias de operaciones contra la
+// API y contra un modelo de referencia mínimo (mapas y slices, sin locks
+// ni índices). Tras cada operación se compara el estado de los dos.
+
+// modelTask es lo que el modelo sabe de una tarea.
+type modelTask struct {
+	Title     string
+	Done      bool
+	ProjectID int
+	Column    string
+}
+
+// model es la implementación de referencia.
+type model struct {
+	tasks         map[int]modelTask
+	nextID        int
+	boards        map[int]map[string][]int // proyecto → columna → IDs en orden
+	nextProjectID int
+}
+
+func newModel() *model {
+	return &model{tasks: make(map[int]modelTask), nextID: 1, boards: make(map[int]map[string][]int), nextProjectID: 1}
+}
+
+// columns son las columnas de todos los proyectos (los de la prueba usan
+// las de por defecto).
+var modelColumns = []string{"todo", "doing", "done"}
+
+// create aplica POST /tasks y devuelve el status esperado.
+func (m *model) create(title string, projectID int, column string) int {
+	if validateTitle(title) != nil || (column != "" && projectID == 0) {
+		return http.StatusBadRequest
+	}
+	if projectID != 0 {
+		board, ok := m.boards[projectID]
+		if column == "" {
+			column = modelColumns[0]
+		}
+		if !ok || !slices.Contains(modelColumns, column) {
+			return http.StatusBadRequest
+		}
+		board[column] = append(board[column], m.nextID)
+	}
+	m.tasks[m.nextID] = modelTask{Title: title, ProjectID: projectID, Column: column}
+	m.nextID++
+	return http.StatusCreated
+}
+
+// unlink quita la tarea id de su columna.
+func (m *model) unlink(id int) {
+	t := m.tasks[id]
+	if t.ProjectID != 0 {
+		col := m.boards[t.ProjectID][t.Column]
+		m.boards[t.ProjectID][t.Column] = slices.DeleteFunc(col, func(x int) bool { return x == id })
+	}
+}
+
+// move aplica POST /tasks/{id}/move.
+func (m *model) move(id, projectID int, column string, pos int) int {
+	t, ok := m.tasks[id]
+	if !ok {
+		return http.StatusNotFound
+	}
+	if projectID == 0 {
+		projectID = t.ProjectID
+	}
+	board, ok := m.boards[projectID]
+	if !ok {
+		return http.StatusBadRequest
+	}
+	if column == "" {
+		column = modelColumns[0]
+		if t.ProjectID == projectID {
+			column = t.Column
+		}
+	}
+	if !slices.Contains(modelColumns, column) {
+		return http.StatusBadRequest
+	}
+	m.unlink(id)
+	col := board[column]
+	idx := len(col)
+	if pos >= 1 && pos <= len(col) {
+		idx = pos - 1
+	}
+	board[column] = slices.Insert(col, idx, id)
+	t.ProjectID, t.Column = projectID, column
+	m.tasks[id] = t
+	return http.StatusOK
+}
+
+// remove aplica tasks.delete; false si la tarea no existía.
+func (m *model) remove(id int) bool {
+	if _, ok := m.tasks[id]; !ok {
+		return false
+	}
+	m.unlink(id)
+	delete(m.tasks, id)
+	return true
+}
+
+// removeProject aplica DELETE /projects/{id}.
+func (m *model) removeProject(id int) int {
+	board, ok := m.boards[id]
+	if !ok {
+		return http.StatusNotFound
+	}
+	for _, col := range board {
+		if len(col) > 0 {
+			return http.StatusConflict
+		}
+	}
+	delete(m.boards, id)
+	return http.StatusNoContent
+}
+
+// modelTitles son los títulos entre los que se elige (el vacío y el largo
+// no son válidos).
+var modelTitles = []string{"comprar pan", "Aprender Go", "", "  ", "revisar PR", string(make([]byte, 201))}
+
+// check compara el estado de la API con el del modelo.
+func (m *model) check(t *testing.T, h http.Handler) {
+	t.Helper()
+	w := do(t, h, http.MethodGet, "/tasks", "")
+	var tasks []Task
+	if err := json.NewDecoder(w.Body).Decode(&tasks); err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != len(m.tasks) {
+		t.Fatalf("la API tiene %d tareas; el modelo %d", len(tasks), len(m.tasks))
+	}
+	for _, got := range tasks {
+		want, ok := m.tasks[got.ID]
+		if !ok || got.Title != want.Title || got.Done != want.Done || got.ProjectID != want.ProjectID || got.Column != want.Column {
+			t.Fatalf("tarea %d = %q done=%v en %d/%q; want %+v (existe: %v)", got.ID, got.Title, got.Done, got.ProjectID, got.Column, want, ok)
+		}
+	}
+
+	w = do(t, h, http.MethodGet, "/projects", "")
+	var projects []Project
+	if err := json.NewDecoder(w.Body).Decode(&projects); err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != len(m.boards) {
+		t.Fatalf("la API tiene %d proyectos; el modelo %d", len(projects), len(m.boards))
+	}
+	for _, p := range projects {
+		got := boardOrder(t, h, strconv.Itoa(p.ID))
+		for _, col := range modelColumns {
+			if want := m.boards[p.ID][col]; !slices.Equal(got[col], want) && len(got[col])+len(want) > 0 {
+				t.Fatalf("proyecto %d, columna %s = %v; want %v", p.ID, col, got[col], want)
+			}
+		}
+	}
+}
+
+func TestModelRandomOperations(t *testing.T) {
+	for seed := range uint64(20) {
+		t.Run(fmt.Sprintf("semilla %d", seed), func(t *testing.T) {
+			rnd := rand.New(rand.NewPCG(seed, 46))
+			h := newServer(newTaskStore()).routes()
+			m := newModel()
+
+			// anyID elige un ID que puede existir o no.
+			anyID := func() int { return rnd.IntN(m.nextID+1) + 1 }
+			anyProject := func() int { return rnd.IntN(m.nextProjectID + 1) }
+			anyColumn := func() string {
+				if n := rnd.IntN(len(modelColumns) + 2); n < len(modelColumns) {
+					return modelColumns[n]
+				} else if n == len(modelColumns) {
+					return ""
+				}
+				return "nope"
+			}
+
+			for step := range 300 {
+				var op string
+				switch n := rnd.IntN(100); {
+				case n < 30:
+					title, pid, col := modelTitles[rnd.IntN(len(modelTitles))], 0, ""
+					if rnd.IntN(2) == 0 {
+						pid, col = anyProject(), anyColumn()
+					}
+					op = fmt.Sprintf("crear %q en %d/%q", title, pid, col)
+					body, _ := json.Marshal(map[string]any{"title": title, "project_id": pid, "column": col})
+					if pid == 0 {
+						body, _ = json.Marshal(map[string]any{"title": title, "column": col})
+					}
+					want := m.create(title, pid, col)
+					if w := do(t, h, http.MethodPost, "/tasks", string(body)); w.Code != want {
+						t.Fatalf("paso %d, %s: status = %d; want %d", step, op, w.Code, want)
+					}
+				case n < 45:
+					id, pid, col, pos := anyID(), anyProject(), anyColumn(), rnd.IntN(5)
+					op = fmt.Sprintf("mover %d a %d/%q posición %d", id, pid, col, pos)
+					body, _ := json.Marshal(moveInput{ProjectID: pid, Column: col, Position: pos})
+					want := m.move(id, pid, col, pos)
+					if w := do(t, h, http.MethodPost, "/tasks/"+strconv.Itoa(id)+"/move", string(body)); w.Code != want {
+						t.Fatalf("paso %d, %s: status = %d; want %d", step, op, w.Code, want)
+					}
+				case n < 60:
+					id := anyID()
+					op = fmt.Sprintf("marcar %d", id)
+					w := do(t, h, http.MethodPost, "/rpc", fmt.Sprintf(`{"jsonrpc":"2.0","method":"tasks.update","params":{"id":%d,"done":true},"id":1}`, id))
+					var resp rpcResponse
+					_ = json.NewDecoder(w.Body).Decode(&resp)
+					mt, ok := m.tasks[id]
+					if ok {
+						mt.Done = true
+						m.tasks[id] = mt
+					}
+					if (resp.Error == nil) != ok {
+						t.Fatalf("paso %d, %s: error %+v; want existe=%v", step, op, resp.Error, ok)
+					}
+				case n < 75:
+					id := anyID()
+					op = fmt.Sprintf("borrar %d", id)
+					w := do(t, h, http.MethodPost, "/rpc", fmt.Sprintf(`{"jsonrpc":"2.0","method":"tasks.delete","params":{"id":%d},"id":1}`, id))
+					var resp rpcResponse
+					_ = json.NewDecoder(w.Body).Decode(&resp)
+					if ok := m.remove(id); (resp.Error == nil) != ok {
+						t.Fatalf("paso %d, %s: error %+v; want existía=%v", step, op, resp.Error, ok)
+					}
+				case n < 85:
+					id := anyID()
+					op = fmt.Sprintf("obtener %d", id)
+					want := http.StatusNotFound
+					if _, ok := m.tasks[id]; ok {
+						want = http.StatusOK
+					}
+					if w := do(t, h, http.MethodGet, "/tasks/"+strconv.Itoa(id), ""); w.Code != want {
+						t.Fatalf("paso %d, %s: status = %d; want %d", step, op, w.Code, want)
+					}
+				case n < 93:
+					op = "crear proyecto"
+					if w := do(t, h, http.MethodPost, "/projects", `{"name":"p"}`); w.Code != http.StatusCreated {
+						t.Fatalf("paso %d, %s: status = %d", step, op, w.Code)
+					}
+					m.boards[m.nextProjectID] = make(map[string][]int)
+					m.nextProjectID++
+				default:
+					id := anyProject()
+					op = fmt.Sprintf("borrar proyecto %d", id)
+					want := m.removeProject(id)
+					if w := do(t, h, http.MethodDelete, "/projects/"+strconv.Itoa(id), ""); w.Code != want {
+						t.Fatalf("paso %d, %s: status = %d; want %d", step, op, w.Code, want)
+					}
+				}
+				m.check(t, h)
+			}
+		})
+	}
+}
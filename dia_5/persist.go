@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Almacenamiento "file": el estado completo se guarda como JSON después de
+// cada mutación y se carga al arrancar. La escritura es atómica (fichero
+// temporal + rename), así que un corte nunca deja el fichero a medias.
+
+// snapshot es lo que se guarda en disco.
+type snapshot struct {
+	NextID           int       `json:"next_id"`
+	NextAttachmentID int       `json:"next_attachment_id,omitempty"`
+	NextProjectID    int       `json:"next_project_id,omitempty"`
+	Revision         int64     `json:"revision"`
+	Tasks            []Task    `json:"tasks"`
+	Projects         []Project `json:"projects,omitempty"`
+}
+
+// load carga el fichero de datos en el almacén y lo usa desde entonces
+// para persistir. Si el fichero no existe se empieza vacío.
+func (s *taskStore) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.mu.Lock()
+		s.path = path
+		s.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Se carga directamente (sin insertLocked): cargar no es un cambio
+	// que deban ver los clientes de /tasks/changes.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextAttachmentID = max(snap.NextAttachmentID, s.nextAttachmentID)
+	s.setTasksLocked(append(s.listLocked(), snap.Tasks...))
+	for _, t := range snap.Tasks {
+		s.index.add(t)
+		s.noteAttachmentsLocked(t)
+	}
+	s.nextID = max(snap.NextID, s.nextID)
+	s.projects = append(s.projects, snap.Projects...)
+	s.nextProjectID = max(snap.NextProjectID, s.nextProjectID)
+	s.revision = max(snap.Revision, s.revision)
+	s.path = path
+	return nil
+}
+
+// persistLocked guarda el estado actual si hay fichero de datos.
+// Requiere mu tomado.
+func (s *taskStore) persistLocked() {
+	if s.path == "" {
+		return
+	}
+	s.lastPersistErr = writeFileAtomic(s.path, s.snapshotLocked())
+	if s.lastPersistErr != nil {
+		slog.Error("error guardando las tareas", "path", s.path, "err", s.lastPersistErr)
+	}
+}
+
+// writeFileAtomic serializa v en JSON y reemplaza path de forma atómica.
+func writeFileAtomic(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op si el rename tuvo éxito
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proyectos: tableros Kanban con columnas ordenadas ("todo", "doing",
+// "done"...). Cada tarea puede pertenecer a un proyecto y a una de sus
+// columnas, con una posición explícita dentro de ella (1 = arriba).
+//   - GET  /projects, POST /projects
+//   - GET  /projects/{id} (el tablero), PATCH /projects/{id}, DELETE /projects/{id}
+//   - POST /tasks/{id}/move cambia columna y posición de una vez.
+//
+// Cada columna puede tener un límite WIP (máximo de tareas); meter una
+// tarea más en una columna llena responde 409.
+
+// defaultColumns son las columnas de un proyecto si no se indican otras.
+var defaultColumns = []Column{{Name: "todo"}, {Name: "doing"}, {Name: "done"}}
+
+// Column es una columna de un proyecto.
+type Column struct {
+	Name     string `json:"name"`
+	WIPLimit int    `json:"wip_limit,omitempty"` // máximo de tareas; 0 = sin límite
+}
+
+// Project es un tablero que agrupa tareas en columnas.
+type Project struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Columns   []Column  `json:"columns"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// column devuelve la columna con ese nombre.
+func (p Project) column(name string) (Column, bool) {
+	for _, c := range p.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// wipLimitError indica que una columna ya tiene el máximo de tareas.
+type wipLimitError struct {
+	column string
+	limit  int
+}
+
+func (e *wipLimitError) Error() string {
+	return localize(defaultLang, codeWIPLimitExceeded, e.column, e.limit).Detail
+}
+
+var (
+	// errProjectNotFound indica que el proyecto no existe.
+	errProjectNotFound = errors.New("proyecto no encontrado")
+	// errProjectNotEmpty indica que se intentó borrar un proyecto con tareas.
+	errProjectNotEmpty = errors.New("el proyecto tiene tareas")
+)
+
+// findProjectLocked devuelve la posición en el slice del proyecto con ese ID.
+func (s *taskStore) findProjectLocked(id int) (int, bool) {
+	for i, p := range s.projects {
+		if p.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// createProjectLocked asigna el siguiente ID a p y lo guarda.
+func (s *taskStore) createProjectLocked(p Project) Project {
+	p.ID = s.nextProjectID
+	s.insertProjectLocked(p)
+	return p
+}
+
+// insertProjectLocked guarda p con el ID que ya trae.
+func (s *taskStore) insertProjectLocked(p Project) {
+	s.nextProjectID = max(s.nextProjectID, p.ID+1)
+	s.projects = append(s.projects, p)
+	s.noteProjectAuditLocked(p.ID, nil, &p)
+	s.recordProjectChangeLocked(opProjectCreate, p.ID, &p)
+	s.persistLocked()
+}
+
+// updateProjectLocked reemplaza el proyecto con el mismo ID que p.
+func (s *taskStore) updateProjectLocked(p Project) bool {
+	i, ok := s.findProjectLocked(p.ID)
+	if !ok {
+		return false
+	}
+	old := s.projects[i]
+	s.projects[i] = p
+	s.noteProjectAuditLocked(p.ID, &old, &p)
+	s.recordProjectChangeLocked(opProjectUpdate, p.ID, &p)
+	s.persistLocked()
+	return true
+}
+
+// removeProjectLocked borra un proyecto vacío.
+func (s *taskStore) removeProjectLocked(id int) error {
+	i, ok := s.findProjectLocked(id)
+	if !ok {
+		return errProjectNotFound
+	}
+	for _, t := range s.tasks {
+		if t.ProjectID == id {
+			return errProjectNotEmpty
+		}
+	}
+	old := s.projects[i]
+	s.projects = append(s.projects[:i], s.projects[i+1:]...)
+	s.noteProjectAuditLocked(id, &old, nil)
+	s.recordProjectChangeLocked(opProjectDelete, id, nil)
+	s.persistLocked()
+	return nil
+}
+
+// recordProjectChangeLocked registra un cambio del proyecto id.
+func (s *taskStore) recordProjectChangeLocked(op string, id int, p *Project) {
+	if p != nil {
+		snap := *p // copia: el log no debe ver cambios posteriores
+		snap.Columns = slices.Clone(p.Columns)
+		p = &snap
+	}
+	s.appendChangeLocked(change{Op: op, ProjectID: id, Project: p})
+}
+
+// columnTasksLocked devuelve las tareas de una columna en orden.
+func (s *taskStore) columnTasksLocked(projectID int, column string) []Task {
+	var ts []Task
+	for t := range s.allLocked() {
+		if t.ProjectID == projectID && t.Column == column {
+			ts = append(ts, t)
+		}
+	}
+	slices.SortStableFunc(ts, func(a, b Task) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.ID, b.ID))
+	})
+	return ts
+}
+
+// renumberLocked da a ts las posiciones 1..n y devuelve solo las tareas
+// que cambian respecto a lo guardado.
+func (s *taskStore) renumberLocked(ts []Task) []Task {
+	var changed []Task
+	for i, t := range ts {
+		t.Position = i + 1
+		old, ok := s.getLocked(t.ID)
+		if !ok {
+			continue
+		}
+		if old.ProjectID != t.ProjectID || old.Column != t.Column || old.Position != t.Position {
+			changed = append(changed, t)
+		}
+	}
+	return changed
+}
+
+// placeNewLocked coloca una tarea nueva al final de una columna del
+// proyecto (la primera si column está vacío), respetando su límite WIP.
+func (s *taskStore) placeNewLocked(t *Task, projectID int, column string) error {
+	i, ok := s.findProjectLocked(projectID)
+	if !ok {
+		return newFieldError("project_id", codeProjectNotFound, projectID)
+	}
+	p := s.projects[i]
+	if column == "" {
+		column = p.Columns[0].Name
+	}
+	c, ok := p.column(column)
+	if !ok {
+		return newFieldError("column", codeUnknownColumn, column)
+	}
+	current := s.columnTasksLocked(projectID, column)
+	if c.WIPLimit > 0 && len(current) >= c.WIPLimit {
+		return &wipLimitError{column, c.WIPLimit}
+	}
+	t.ProjectID, t.Column, t.Position = projectID, column, len(current)+1
+	return nil
+}
+
+// moveLocked mueve la tarea id a la columna column del proyecto projectID
+// (0 = el suyo) en la posición pos (1 = arriba; 0 = al final). Todas las
+// tareas renumeradas de ambas columnas se guardan como un solo cambio, así
+// que nadie (tampoco /tasks/changes ni las réplicas) ve un estado
+// intermedio.
+func (s *taskStore) moveLocked(id, projectID int, column string, pos int) (Task, error) {
+	old, ok := s.getLocked(id)
+	if !ok {
+		return Task{}, errTaskNotFound
+	}
+	if projectID == 0 {
+		projectID = old.ProjectID
+	}
+	if projectID == 0 {
+		return Task{}, newFieldError("project_id", codeProjectRequired)
+	}
+	pi, ok := s.findProjectLocked(projectID)
+	if !ok {
+		return Task{}, newFieldError("project_id", codeProjectNotFound, projectID)
+	}
+	p := s.projects[pi]
+	if column == "" {
+		column = p.Columns[0].Name
+		if old.ProjectID == projectID {
+			column = old.Column
+		}
+	}
+	c, ok := p.column(column)
+	if !ok {
+		return Task{}, newFieldError("column", codeUnknownColumn, column)
+	}
+	if pos < 0 {
+		return Task{}, newFieldError("position", codeInvalidPosition)
+	}
+
+	sameColumn := old.ProjectID == projectID && old.Column == column
+	target := slices.DeleteFunc(s.columnTasksLocked(projectID, column), func(t Task) bool { return t.ID == id })
+	if !sameColumn && c.WIPLimit > 0 && len(target) >= c.WIPLimit {
+		return Task{}, &wipLimitError{column, c.WIPLimit}
+	}
+
+	idx := len(target)
+	if pos >= 1 && pos <= len(target) {
+		idx = pos - 1
+	}
+	moved := old
+	moved.ProjectID, moved.Column = projectID, column
+	changed := s.renumberLocked(slices.Insert(target, idx, moved))
+	if !sameColumn && old.ProjectID != 0 {
+		// Cerrar el hueco en la columna de origen.
+		source := slices.DeleteFunc(s.columnTasksLocked(old.ProjectID, old.Column), func(t Task) bool { return t.ID == id })
+		changed = append(changed, s.renumberLocked(source)...)
+	}
+	s.updateBatchLocked(changed)
+
+	moved, _ = s.getLocked(id)
+	return moved, nil
+}
+
+// deleteTaskLocked borra la tarea id y cierra el hueco que deja en su
+// columna, todo como un solo cambio opDelete con las tareas renumeradas.
+func (s *taskStore) deleteTaskLocked(id int) bool {
+	t, ok := s.getLocked(id)
+	if !ok {
+		return false
+	}
+	var renumbered []Task
+	if t.ProjectID != 0 {
+		rest := slices.DeleteFunc(s.columnTasksLocked(t.ProjectID, t.Column), func(c Task) bool { return c.ID == id })
+		renumbered = s.renumberLocked(rest)
+	}
+	return s.removeLocked(id, renumbered...)
+}
+
+// errTaskNotFound indica que la tarea no existe.
+var errTaskNotFound = errors.New("tarea no encontrada")
+
+// projectInput es el payload para crear un proyecto.
+type projectInput struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns,omitempty"` // por defecto defaultColumns
+}
+
+// projectPatch es el payload de PATCH /projects/{id}; los campos ausentes
+// no cambian.
+type projectPatch struct {
+	Name      *string        `json:"name,omitempty"`
+	WIPLimits map[string]int `json:"wip_limits,omitempty"` // columna → límite
+}
+
+// validateProjectName asegura que el nombre no esté vacío ni sea demasiado largo.
+func validateProjectName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return newFieldError("name", codeNameEmpty)
+	}
+	if len(name) > maxTitleLen {
+		return newFieldError("name", codeTitleTooLong, maxTitleLen)
+	}
+	return nil
+}
+
+// validateColumns comprueba que haya columnas con nombres distintos y
+// límites no negativos.
+func validateColumns(cols []Column) error {
+	seen := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		if strings.TrimSpace(c.Name) == "" || seen[c.Name] || c.WIPLimit < 0 {
+			return newFieldError("columns", codeInvalidColumns)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+// projectsHandler maneja /projects.
+// - GET: lista los proyectos.
+// - POST: crea un proyecto.
+func (srv *server) projectsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		srv.store.mu.RLock()
+		projects := slices.Clone(srv.store.projects)
+		srv.store.mu.RUnlock()
+		writeJSON(w, http.StatusOK, projects)
+	case http.MethodPost:
+		srv.createProject(w, r)
+	default:
+		writeMethodNotAllowed(w, r, "GET, POST")
+	}
+}
+
+// createProject crea un proyecto con las columnas indicadas o las de
+// por defecto.
+func (srv *server) createProject(w http.ResponseWriter, r *http.Request) {
+	var in projectInput
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if len(in.Columns) == 0 {
+		in.Columns = slices.Clone(defaultColumns)
+	}
+	var errs []error
+	for _, err := range []error{validateProjectName(in.Name), validateColumns(in.Columns)} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		writeValidationError(w, r, errs...)
+		return
+	}
+
+	srv.store.lockFor(r.Context())
+	p := srv.store.createProjectLocked(Project{Name: in.Name, Columns: in.Columns, CreatedAt: time.Now().UTC()})
+	srv.store.unlock()
+
+	w.Header().Set("Location", "/projects/"+strconv.Itoa(p.ID))
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// boardColumn es una columna del tablero con sus tareas en orden.
+type boardColumn struct {
+	Column
+	Tasks []Task `json:"tasks"`
+}
+
+// projectByIDHandler maneja /projects/{id}.
+// - GET: el tablero con las tareas de cada columna en orden.
+// - PATCH: cambia el nombre o los límites WIP.
+// - DELETE: borra el proyecto si no tiene tareas.
+func (srv *server) projectByIDHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/projects/"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidID)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		srv.store.mu.RLock()
+		i, ok := srv.store.findProjectLocked(id)
+		if !ok {
+			srv.store.mu.RUnlock()
+			writeError(w, r, http.StatusNotFound, codeProjectNotFound, id)
+			return
+		}
+		p := srv.store.projects[i]
+		board := make([]boardColumn, 0, len(p.Columns))
+		for _, c := range p.Columns {
+			ts := srv.store.columnTasksLocked(id, c.Name)
+			if ts == nil {
+				ts = []Task{}
+			}
+			board = append(board, boardColumn{c, ts})
+		}
+		srv.store.mu.RUnlock()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":         p.ID,
+			"name":       p.Name,
+			"created_at": p.CreatedAt,
+			"columns":    board,
+		})
+	case http.MethodPatch:
+		srv.patchProject(w, r, id)
+	case http.MethodDelete:
+		srv.store.lockFor(r.Context())
+		err := srv.store.removeProjectLocked(id)
+		srv.store.unlock()
+		if errors.Is(err, errProjectNotFound) {
+			writeError(w, r, http.StatusNotFound, codeProjectNotFound, id)
+			return
+		}
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, "GET, PATCH, DELETE")
+	}
+}
+
+// patchProject aplica un projectPatch.
+func (srv *server) patchProject(w http.ResponseWriter, r *http.Request, id int) {
+	var in projectPatch
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+	if in.Name != nil {
+		if err := validateProjectName(*in.Name); err != nil {
+			writeValidationError(w, r, err)
+			return
+		}
+	}
+
+	st := srv.store
+	st.lockFor(r.Context())
+	defer st.unlock()
+	i, ok := st.findProjectLocked(id)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, codeProjectNotFound, id)
+		return
+	}
+	p := st.projects[i]
+	p.Columns = slices.Clone(p.Columns)
+	if in.Name != nil {
+		p.Name = *in.Name
+	}
+	for name, limit := range in.WIPLimits {
+		j := slices.IndexFunc(p.Columns, func(c Column) bool { return c.Name == name })
+		if j < 0 {
+			writeValidationError(w, r, newFieldError("wip_limits", codeUnknownColumn, name))
+			return
+		}
+		if limit < 0 {
+			writeValidationError(w, r, newFieldError("wip_limits", codeInvalidColumns))
+			return
+		}
+		p.Columns[j].WIPLimit = limit
+	}
+	st.updateProjectLocked(p)
+	writeJSON(w, http.StatusOK, p)
+}
+
+// moveInput es el payload de POST /tasks/{id}/move.
+type moveInput struct {
+	ProjectID int    `json:"project_id,omitempty"` // por defecto, el de la tarea
+	Column    string `json:"column,omitempty"`     // por defecto, la de la tarea o la primera del proyecto
+	Position  int    `json:"position,omitempty"`   // 1 = arriba; 0 = al final
+}
+
+// moveTaskHandler maneja POST /tasks/{id}/move.
+func (srv *server) moveTaskHandler(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "POST")
+		return
+	}
+	var in moveInput
+	if !decodeJSON(w, r, &in) {
+		return
+	}
+
+	srv.store.lockFor(r.Context())
+	t, err := srv.store.moveLocked(id, in.ProjectID, in.Column, in.Position)
+	srv.store.unlock()
+	if errors.Is(err, errTaskNotFound) {
+		writeError(w, r, http.StatusNotFound, codeTaskNotFound, id)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// do hace una petición JSON al servidor y devuelve la respuesta.
+func do(t *testing.T, h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+// boardOrder devuelve los IDs de las tareas de cada columna del tablero.
+func boardOrder(t *testing.T, h http.Handler, pid string) map[string][]int {
+	t.Helper()
+	w := do(t, h, http.MethodGet, "/projects/"+pid, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /projects/%s: status = %d", pid, w.Code)
+	}
+	var board struct {
+		Columns []boardColumn `json:"columns"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&board); err != nil {
+		t.Fatal(err)
+	}
+	order := make(map[string][]int)
+	for _, c := range board.Columns {
+		order[c.Name] = []int{}
+		for i, task := range c.Tasks {
+			if task.Position != i+1 {
+				t.Errorf("columna %s: la tarea %d tiene position %d; want %d", c.Name, task.ID, task.Position, i+1)
+			}
+			order[c.Name] = append(order[c.Name], task.ID)
+		}
+	}
+	return order
+}
+
+func TestProjectBoardAndMoves(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+
+	w := do(t, h, http.MethodPost, "/projects", `{"name":"Web","columns":[{"name":"todo"},{"name":"doing","wip_limit":2},{"name":"done"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("crear proyecto: status = %d; want 201", w.Code)
+	}
+	for _, title := range []string{"a", "b", "c", "d"} {
+		if w := do(t, h, http.MethodPost, "/tasks", `{"title":"`+title+`","project_id":1}`); w.Code != http.StatusCreated {
+			t.Fatalf("crear tarea %s: status = %d", title, w.Code)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		task       string
+		body       string
+		wantStatus int
+		wantCode   string
+		want       map[string][]int
+	}{
+		{"a doing", "1", `{"column":"doing"}`, http.StatusOK, "",
+			map[string][]int{"todo": {2, 3, 4}, "doing": {1}, "done": {}}},
+		{"b arriba de doing", "2", `{"column":"doing","position":1}`, http.StatusOK, "",
+			map[string][]int{"todo": {3, 4}, "doing": {2, 1}, "done": {}}},
+		{"límite WIP", "3", `{"column":"doing"}`, http.StatusConflict, codeWIPLimitExceeded,
+			map[string][]int{"todo": {3, 4}, "doing": {2, 1}, "done": {}}},
+		{"reordenar dentro de la columna llena", "1", `{"position":1}`, http.StatusOK, "",
+			map[string][]int{"todo": {3, 4}, "doing": {1, 2}, "done": {}}},
+		{"posición más allá del final", "4", `{"column":"todo","position":9}`, http.StatusOK, "",
+			map[string][]int{"todo": {3, 4}, "doing": {1, 2}, "done": {}}},
+		{"columna desconocida", "3", `{"column":"qa"}`, http.StatusBadRequest, codeValidationFailed,
+			map[string][]int{"todo": {3, 4}, "doing": {1, 2}, "done": {}}},
+		{"proyecto desconocido", "3", `{"project_id":7,"column":"todo"}`, http.StatusBadRequest, codeValidationFailed,
+			map[string][]int{"todo": {3, 4}, "doing": {1, 2}, "done": {}}},
+		{"posición negativa", "3", `{"position":-1}`, http.StatusBadRequest, codeValidationFailed,
+			map[string][]int{"todo": {3, 4}, "doing": {1, 2}, "done": {}}},
+		{"tarea inexistente", "99", `{"column":"done"}`, http.StatusNotFound, codeTaskNotFound,
+			map[string][]int{"todo": {3, 4}, "doing": {1, 2}, "done": {}}},
+		{"doing a done libera hueco", "2", `{"column":"done"}`, http.StatusOK, "",
+			map[string][]int{"todo": {3, 4}, "doing": {1}, "done": {2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, h, http.MethodPost, "/tasks/"+tt.task+"/move", tt.body)
+			var p problem
+			if w.Code != http.StatusOK {
+				_ = json.NewDecoder(w.Body).Decode(&p)
+			}
+			if w.Code != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("got %d %q; want %d %q", w.Code, p.Code, tt.wantStatus, tt.wantCode)
+			}
+			got := boardOrder(t, h, "1")
+			for col, want := range tt.want {
+				if !slices.Equal(got[col], want) {
+					t.Errorf("columna %s = %v; want %v", col, got[col], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateTaskInFullColumn(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+	do(t, h, http.MethodPost, "/projects", `{"name":"Web"}`)
+	if w := do(t, h, http.MethodPatch, "/projects/1", `{"wip_limits":{"todo":1}}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH: status = %d; want 200", w.Code)
+	}
+
+	wantStatus := []int{http.StatusCreated, http.StatusConflict}
+	for i, want := range wantStatus {
+		if w := do(t, h, http.MethodPost, "/tasks", `{"title":"x","project_id":1}`); w.Code != want {
+			t.Errorf("tarea %d: status = %d; want %d", i+1, w.Code, want)
+		}
+	}
+	if w := do(t, h, http.MethodPost, "/tasks", `{"title":"x","project_id":1,"column":"done"}`); w.Code != http.StatusCreated {
+		t.Errorf("en otra columna: status = %d; want 201", w.Code)
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+	do(t, h, http.MethodPost, "/projects", `{"name":"Web"}`)
+	do(t, h, http.MethodPost, "/tasks", `{"title":"x","project_id":1}`)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/projects/1", http.StatusConflict}, // tiene una tarea
+		{"/projects/2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := do(t, h, http.MethodDelete, tt.path, ""); w.Code != tt.wantStatus {
+			t.Errorf("DELETE %s: status = %d; want %d", tt.path, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestProjectValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"sin nombre", `{"name":" "}`},
+		{"columnas repetidas", `{"name":"a","columns":[{"name":"x"},{"name":"x"}]}`},
+		{"límite negativo", `{"name":"a","columns":[{"name":"x","wip_limit":-1}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newServer(newTaskStore()).routes()
+			if w := do(t, h, http.MethodPost, "/projects", tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d; want 400", w.Code)
+			}
+		})
+	}
+}
+
+func TestMoveIsOneChange(t *testing.T) {
+	st := newTaskStore()
+	st.mu.Lock()
+	p := st.createProjectLocked(Project{Name: "Web", Columns: defaultColumns})
+	for _, title := range []string{"a", "b", "c", "d"} {
+		task := Task{Title: title}
+		if err := st.placeNewLocked(&task, p.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+		st.createLocked(task)
+	}
+	before := st.revision
+
+	// La 4 sube arriba de su columna: se renumeran las cuatro.
+	if _, err := st.moveLocked(4, 0, "", 1); err != nil {
+		t.Fatal(err)
+	}
+	changes, _ := st.changesSinceLocked(before)
+	st.mu.Unlock()
+
+	if len(changes) != 1 || changes[0].Op != opReorder {
+		t.Fatalf("cambios del movimiento = %+v; want un solo %q", changes, opReorder)
+	}
+	got := map[int]int{}
+	for _, task := range changes[0].Tasks {
+		got[task.ID] = task.Position
+	}
+	want := map[int]int{4: 1, 1: 2, 2: 3, 3: 4}
+	if len(got) != len(want) {
+		t.Errorf("posiciones = %v; want %v", got, want)
+	}
+	for id, pos := range want {
+		if got[id] != pos {
+			t.Errorf("posiciones = %v; want %v", got, want)
+			break
+		}
+	}
+
+	// Una réplica aplica el reorder como un solo cambio.
+	follower := newTaskStore()
+	follower.mu.Lock()
+	defer follower.mu.Unlock()
+	st.mu.Lock()
+	all, _ := st.changesSinceLocked(0)
+	st.mu.Unlock()
+	for _, c := range all {
+		if err := follower.applyLocked(c); err != nil {
+			t.Fatalf("applyLocked(%+v): %v", c, err)
+		}
+	}
+	if follower.revision != before+1 {
+		t.Errorf("revisión del seguidor = %d; want %d", follower.revision, before+1)
+	}
+	if task, _ := follower.getLocked(4); task.Position != 1 {
+		t.Errorf("la tarea 4 en el seguidor tiene position %d; want 1", task.Position)
+	}
+}
+
+func TestDeleteIsOneChange(t *testing.T) {
+	st := newTaskStore()
+	st.mu.Lock()
+	p := st.createProjectLocked(Project{Name: "Web", Columns: defaultColumns})
+	for _, title := range []string{"a", "b", "c"} {
+		task := Task{Title: title}
+		if err := st.placeNewLocked(&task, p.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+		st.createLocked(task)
+	}
+	before := st.revision
+
+	// Al borrar la primera, las otras dos suben en el mismo cambio.
+	if !st.deleteTaskLocked(1) {
+		t.Fatal("deleteTaskLocked(1) = false")
+	}
+	changes, _ := st.changesSinceLocked(before)
+	st.mu.Unlock()
+
+	if len(changes) != 1 || changes[0].Op != opDelete || changes[0].TaskID != 1 {
+		t.Fatalf("cambios del borrado = %+v; want un solo %q de la tarea 1", changes, opDelete)
+	}
+	got := map[int]int{}
+	for _, task := range changes[0].Tasks {
+		got[task.ID] = task.Position
+	}
+	if want := map[int]int{2: 1, 3: 2}; !maps.Equal(got, want) {
+		t.Errorf("posiciones = %v; want %v", got, want)
+	}
+}
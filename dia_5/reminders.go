@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// Recordatorios de fecha límite. Un planificador guarda en un min-heap los
+// próximos avisos (fecha límite menos cada offset configurado, p. ej. "1h")
+// y los envía a un Notifier cuando llega su hora. El heap se calcula a
+// partir del almacén al arrancar y después se actualiza con cada cambio,
+// solo para las tareas que cambian. El único estado propio es hasta cuándo
+// se han enviado avisos, que se guarda junto al fichero de datos cuando se
+// entrega alguno para recuperar al arrancar los que vencieron con el
+// servidor parado.
+
+// clock es la fuente de tiempo del planificador; los tests usan uno falso.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock es el reloj del sistema.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// reminder es un aviso de que se acerca la fecha límite de una tarea.
+type reminder struct {
+	TaskID  int           `json:"task_id"`
+	Title   string        `json:"title"`
+	DueDate time.Time     `json:"due_date"`
+	Before  time.Duration `json:"-"`
+	At      time.Time     `json:"at"` // cuándo debe enviarse
+
+	index int // posición en el heap
+}
+
+// MarshalJSON añade el offset en formato legible ("1h0m0s").
+func (r reminder) MarshalJSON() ([]byte, error) {
+	type plain reminder
+	return json.Marshal(struct {
+		plain
+		Before string `json:"before"`
+	}{plain(r), r.Before.String()})
+}
+
+// Notifier envía los recordatorios. Notify no debe bloquear al planificador.
+type Notifier interface {
+	Notify(ctx context.Context, r reminder) error
+}
+
+// multiNotifier envía cada recordatorio a todos sus notifiers.
+type multiNotifier []Notifier
+
+func (m multiNotifier) Notify(ctx context.Context, r reminder) error {
+	var errs []error
+	for _, n := range m {
+		errs = append(errs, n.Notify(ctx, r))
+	}
+	return errors.Join(errs...)
+}
+
+// logNotifier escribe los recordatorios en el log.
+type logNotifier struct{}
+
+func (logNotifier) Notify(_ context.Context, r reminder) error {
+	slog.Info("recordatorio", "task_id", r.TaskID, "title", r.Title,
+		"due_date", r.DueDate.Format(time.RFC3339), "before", r.Before)
+	return nil
+}
+
+// reminderHeap es un min-heap de recordatorios ordenados por At. Cada uno
+// sabe su posición, para poder quitarlo con heap.Remove.
+type reminderHeap []*reminder
+
+func (h reminderHeap) Len() int           { return len(h) }
+func (h reminderHeap) Less(i, j int) bool { return h[i].At.Before(h[j].At) }
+func (h reminderHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *reminderHeap) Push(x any) {
+	r := x.(*reminder)
+	r.index = len(*h)
+	*h = append(*h, r)
+}
+func (h *reminderHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// reminderRetry es la espera antes de reintentar un aviso que el Notifier
+// no aceptó (por ejemplo, con la cola del webhook llena).
+var reminderRetry = 30 * time.Second
+
+// scheduler envía los recordatorios de las tareas de un almacén.
+type scheduler struct {
+	store    *taskStore
+	offsets  []time.Duration
+	clock    clock
+	notifier Notifier
+
+	// statePath es el fichero donde se guarda sentUntil; vacío (solo
+	// memoria) no lo guarda.
+	statePath string
+
+	queue reminderHeap
+	// byTask son los avisos de queue de cada tarea.
+	byTask map[int][]*reminder
+	// revision es la del almacén con la que está al día queue.
+	revision int64
+	// sentUntil es hasta dónde se han enviado avisos: solo se programan
+	// los posteriores. Al arrancar se lee de statePath, así que los avisos
+	// que vencieron con el servidor parado se envían nada más arrancar; sin
+	// fichero (o la primera vez) es "ahora". Nunca pasa de un aviso que
+	// no se pudo enviar.
+	sentUntil time.Time
+	// retryAt es cuándo reintentar el primer aviso de queue, si falló.
+	retryAt time.Time
+}
+
+// schedulerState es lo que se guarda en statePath.
+type schedulerState struct {
+	SentUntil time.Time `json:"sent_until"`
+}
+
+// newScheduler crea un planificador; offsets son los avisos previos a la
+// fecha límite (0 avisa en el momento del vencimiento).
+func newScheduler(st *taskStore, offsets []time.Duration, c clock, n Notifier) *scheduler {
+	return &scheduler{store: st, offsets: offsets, clock: c, notifier: n}
+}
+
+// run envía recordatorios hasta que se cancele ctx.
+func (s *scheduler) run(ctx context.Context) {
+	s.sentUntil = s.clock.Now()
+	if state, ok := s.loadState(); ok {
+		s.sentUntil = state.SentUntil
+	}
+	wake := s.rebuild()
+	for {
+		s.fireDue(ctx)
+
+		var timer <-chan time.Time
+		if len(s.queue) > 0 {
+			at := s.queue[0].At
+			if s.retryAt.After(at) {
+				at = s.retryAt
+			}
+			timer = s.clock.After(at.Sub(s.clock.Now()))
+		}
+		select {
+		case <-timer:
+		case <-wake:
+			wake = s.update()
+		case <-ctx.Done():
+			// Al parar se guarda hasta dónde se llegó, aunque no se haya
+			// enviado nada desde la última vez.
+			s.saveState()
+			return
+		}
+	}
+}
+
+// rebuild calcula el heap desde el almacén y devuelve el canal que se
+// cerrará en el próximo cambio de las tareas.
+func (s *scheduler) rebuild() <-chan struct{} {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+	return s.rebuildLocked()
+}
+
+// rebuildLocked es rebuild con s.store.mu ya tomado.
+func (s *scheduler) rebuildLocked() <-chan struct{} {
+	s.queue = s.queue[:0]
+	s.byTask = make(map[int][]*reminder)
+	for t := range s.store.allLocked() {
+		for _, r := range s.remindersFor(t) {
+			r.index = len(s.queue)
+			s.queue = append(s.queue, r)
+		}
+	}
+	heap.Init(&s.queue)
+	s.revision = s.store.revision
+	return s.store.changed
+}
+
+// update aplica al heap los cambios del almacén desde la última vez: quita
+// los avisos de las tareas que cambiaron y programa los nuevos. Si el log
+// de cambios ya no los tiene (o se restauró una copia), lo calcula entero.
+func (s *scheduler) update() <-chan struct{} {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+	changes, ok := s.store.changesSinceLocked(s.revision)
+	if !ok {
+		return s.rebuildLocked()
+	}
+	for _, c := range changes {
+		ids := []int{c.TaskID}
+		for _, t := range c.Tasks {
+			ids = append(ids, t.ID)
+		}
+		for _, id := range ids {
+			if id == 0 {
+				continue
+			}
+			for _, r := range s.byTask[id] {
+				heap.Remove(&s.queue, r.index)
+			}
+			delete(s.byTask, id)
+			if t, ok := s.store.getLocked(id); ok {
+				for _, r := range s.remindersFor(t) {
+					heap.Push(&s.queue, r)
+				}
+			}
+		}
+	}
+	s.revision = s.store.revision
+	return s.store.changed
+}
+
+// remindersFor devuelve los avisos pendientes de t y los anota en byTask.
+func (s *scheduler) remindersFor(t Task) []*reminder {
+	if t.Done || t.DueDate == nil {
+		return nil
+	}
+	var rs []*reminder
+	for _, off := range s.offsets {
+		at := t.DueDate.Add(-off)
+		if at.After(s.sentUntil) {
+			rs = append(rs, &reminder{TaskID: t.ID, Title: t.Title, DueDate: *t.DueDate, Before: off, At: at})
+		}
+	}
+	if len(rs) > 0 {
+		s.byTask[t.ID] = rs
+	}
+	return rs
+}
+
+// fireDue envía los recordatorios cuya hora ya llegó. Si el Notifier no
+// acepta uno, se queda en el heap para reintentarlo dentro de
+// reminderRetry y sentUntil no pasa de él. sentUntil solo se guarda en
+// disco si se envió alguno.
+func (s *scheduler) fireDue(ctx context.Context) {
+	now := s.clock.Now()
+	if now.Before(s.retryAt) {
+		return
+	}
+	s.retryAt = time.Time{}
+	sent := false
+	for len(s.queue) > 0 && !s.queue[0].At.After(now) {
+		r := s.queue[0]
+		if err := s.notifier.Notify(ctx, *r); err != nil {
+			slog.Warn("recordatorio no enviado, se reintentará", "task_id", r.TaskID, "err", err)
+			s.sentUntil = r.At.Add(-time.Nanosecond)
+			s.retryAt = now.Add(reminderRetry)
+			break
+		}
+		heap.Pop(&s.queue)
+		s.drop(r)
+		sent = true
+	}
+	if s.retryAt.IsZero() {
+		s.sentUntil = now
+	}
+	if sent {
+		s.saveState()
+	}
+}
+
+// drop quita r, ya enviado, de byTask.
+func (s *scheduler) drop(r *reminder) {
+	rs := slices.DeleteFunc(s.byTask[r.TaskID], func(x *reminder) bool { return x == r })
+	if len(rs) == 0 {
+		delete(s.byTask, r.TaskID)
+		return
+	}
+	s.byTask[r.TaskID] = rs
+}
+
+// loadState lee el estado guardado en statePath; false si no hay.
+func (s *scheduler) loadState() (schedulerState, bool) {
+	if s.statePath == "" {
+		return schedulerState{}, false
+	}
+	var state schedulerState
+	data, err := os.ReadFile(s.statePath)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("recordatorios: estado ilegible, se empieza desde ahora", "path", s.statePath, "err", err)
+		}
+		return schedulerState{}, false
+	}
+	return state, true
+}
+
+// saveState guarda sentUntil en statePath.
+func (s *scheduler) saveState() {
+	if s.statePath == "" {
+		return
+	}
+	if err := writeFileAtomic(s.statePath, schedulerState{s.sentUntil}); err != nil {
+		slog.Error("recordatorios: error guardando el estado", "path", s.statePath, "err", err)
+	}
+}
+
+// parseOffsets interpreta una lista de duraciones separadas por comas.
+func parseOffsets(s string) ([]time.Duration, error) {
+	var offsets []time.Duration
+	for _, part := range splitList(s) {
+		d, err := time.ParseDuration(part)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("offset %q inválido", part)
+		}
+		offsets = append(offsets, d)
+	}
+	return offsets, nil
+}
+
+// webhookNotifier envía los recordatorios por POST (JSON) a una URL. Notify
+// solo los encola; un worker los entrega con reintentos.
+type webhookNotifier struct {
+	url     string
+	client  *http.Client
+	queue   chan reminder
+	retries int
+	backoff time.Duration
+}
+
+// errWebhookQueueFull indica que la cola del webhook está llena.
+var errWebhookQueueFull = errors.New("cola del webhook llena")
+
+// newWebhookNotifier crea un notifier para url con una cola de size avisos.
+func newWebhookNotifier(url string, size int) *webhookNotifier {
+	return &webhookNotifier{
+		url:     url,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan reminder, size),
+		retries: 3,
+		backoff: time.Second,
+	}
+}
+
+func (n *webhookNotifier) Notify(_ context.Context, r reminder) error {
+	select {
+	case n.queue <- r:
+		return nil
+	default:
+		return errWebhookQueueFull
+	}
+}
+
+// run entrega los avisos encolados hasta que se cancele ctx.
+func (n *webhookNotifier) run(ctx context.Context) {
+	for {
+		select {
+		case r := <-n.queue:
+			if err := n.deliver(ctx, r); err != nil {
+				slog.Warn("webhook: recordatorio perdido", "url", n.url, "task_id", r.TaskID, "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver envía un aviso, reintentando con espera creciente.
+func (n *webhookNotifier) deliver(ctx context.Context, r reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	wait := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil || attempt == n.retries {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// post hace un único intento de entrega.
+func (n *webhookNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// check es el chequeo de /readyz: falla si la cola está casi llena, señal
+// de que el webhook no da abasto o no responde.
+func (n *webhookNotifier) check(context.Context) error {
+	if depth, size := len(n.queue), cap(n.queue); depth*4 >= size*3 {
+		return fmt.Errorf("cola del webhook con %d de %d avisos pendientes", depth, size)
+	}
+	return nil
+}
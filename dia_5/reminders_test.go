@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock es un reloj que solo avanza con Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{c.now.Add(d), ch})
+	return ch
+}
+
+// Advance adelanta el reloj y dispara los temporizadores vencidos.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// chanNotifier manda cada recordatorio a un canal.
+type chanNotifier chan reminder
+
+func (n chanNotifier) Notify(_ context.Context, r reminder) error {
+	n <- r
+	return nil
+}
+
+// nextReminder espera el siguiente recordatorio.
+func nextReminder(t *testing.T, n chanNotifier) reminder {
+	t.Helper()
+	select {
+	case r := <-n:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("no llegó ningún recordatorio")
+		return reminder{}
+	}
+}
+
+// noReminder comprueba que no llegue ningún recordatorio.
+func noReminder(t *testing.T, n chanNotifier) {
+	t.Helper()
+	select {
+	case r := <-n:
+		t.Fatalf("recordatorio inesperado: %+v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSchedulerFiresInOrder(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start}
+	st := newTaskStore()
+	due := func(d time.Duration) *time.Time { t := start.Add(d); return &t }
+
+	st.mu.Lock()
+	st.createLocked(Task{Title: "tarde", DueDate: due(3 * time.Hour)})
+	st.createLocked(Task{Title: "pronto", DueDate: due(90 * time.Minute)})
+	st.createLocked(Task{Title: "hecha", Done: true, DueDate: due(2 * time.Hour)})
+	st.createLocked(Task{Title: "sin fecha"})
+	st.createLocked(Task{Title: "ya vencida", DueDate: due(-time.Hour)})
+	st.mu.Unlock()
+
+	n := make(chanNotifier, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newScheduler(st, []time.Duration{time.Hour, 0}, clk, n).run(ctx)
+
+	tests := []struct {
+		advance    time.Duration
+		wantTask   int
+		wantBefore time.Duration
+	}{
+		{30 * time.Minute, 2, time.Hour}, // 10:00 = "pronto" - 1h
+		{60 * time.Minute, 2, 0},         // 10:30
+		{30 * time.Minute, 1, time.Hour}, // 11:00
+		{60 * time.Minute, 1, 0},         // 12:00
+	}
+	for _, tt := range tests {
+		time.Sleep(10 * time.Millisecond) // dejar que el planificador espere
+		clk.Advance(tt.advance)
+		r := nextReminder(t, n)
+		if r.TaskID != tt.wantTask || r.Before != tt.wantBefore {
+			t.Errorf("got tarea %d (%v antes); want tarea %d (%v antes)", r.TaskID, r.Before, tt.wantTask, tt.wantBefore)
+		}
+	}
+	noReminder(t, n)
+}
+
+func TestSchedulerFollowsStoreChanges(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start}
+	st := newTaskStore()
+	n := make(chanNotifier, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go newScheduler(st, []time.Duration{time.Hour}, clk, n).run(ctx)
+
+	// Una tarea nueva se programa sin reiniciar.
+	due := start.Add(2 * time.Hour)
+	st.mu.Lock()
+	task := st.createLocked(Task{Title: "informe", DueDate: &due})
+	other := st.createLocked(Task{Title: "otra", DueDate: &due})
+	st.mu.Unlock()
+
+	// Completar una tarea cancela su aviso.
+	time.Sleep(10 * time.Millisecond)
+	st.mu.Lock()
+	other.Done = true
+	st.updateLocked(other)
+	st.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	clk.Advance(time.Hour)
+	if r := nextReminder(t, n); r.TaskID != task.ID {
+		t.Errorf("got tarea %d; want %d", r.TaskID, task.ID)
+	}
+	noReminder(t, n)
+
+	// Tras "reiniciar" se recalcula desde el almacén y no se repiten los
+	// avisos ya vencidos.
+	cancel()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	go newScheduler(st, []time.Duration{time.Hour, 0}, clk, n).run(ctx2)
+	time.Sleep(10 * time.Millisecond)
+	clk.Advance(time.Hour)
+	if r := nextReminder(t, n); r.TaskID != task.ID || r.Before != 0 {
+		t.Errorf("tras reiniciar: got tarea %d (%v antes); want tarea %d al vencer", r.TaskID, r.Before, task.ID)
+	}
+	noReminder(t, n)
+}
+
+func TestSchedulerReplaysAfterRestart(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start}
+	st := newTaskStore()
+	due := func(d time.Duration) *time.Time { t := start.Add(d); return &t }
+	st.mu.Lock()
+	st.createLocked(Task{Title: "primera", DueDate: due(time.Hour)})
+	st.createLocked(Task{Title: "segunda", DueDate: due(2 * time.Hour)})
+	st.createLocked(Task{Title: "tercera", DueDate: due(5 * time.Hour)})
+	st.mu.Unlock()
+	statePath := filepath.Join(t.TempDir(), "tasks.json.reminders")
+	n := make(chanNotifier, 10)
+
+	// runFor arranca un planificador con estado en statePath y devuelve
+	// la función que lo para (y espera a que termine).
+	runFor := func() func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		sch := newScheduler(st, []time.Duration{0}, clk, n)
+		sch.statePath = statePath
+		done := make(chan struct{})
+		go func() {
+			sch.run(ctx)
+			close(done)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+
+	stop := runFor()
+	clk.Advance(time.Hour)
+	if r := nextReminder(t, n); r.TaskID != 1 {
+		t.Fatalf("got tarea %d; want 1", r.TaskID)
+	}
+	stop()
+
+	// Con el servidor parado vence la segunda. Al arrancar se envía, pero
+	// no se repite la primera ni se adelanta la tercera.
+	clk.Advance(2 * time.Hour)
+	stop = runFor()
+	if r := nextReminder(t, n); r.TaskID != 2 {
+		t.Errorf("tras reiniciar: got tarea %d; want 2", r.TaskID)
+	}
+	noReminder(t, n)
+	clk.Advance(2 * time.Hour)
+	if r := nextReminder(t, n); r.TaskID != 3 {
+		t.Errorf("got tarea %d; want 3", r.TaskID)
+	}
+
+	// Un nuevo reinicio ya no tiene nada pendiente.
+	stop()
+	stop = runFor()
+	noReminder(t, n)
+	stop()
+}
+
+// failingNotifier rechaza los primeros fails avisos (como con la cola del
+// webhook llena) y manda los demás a sent. Cada intento se avisa en tried.
+type failingNotifier struct {
+	fails atomic.Int32
+	tried chan struct{}
+	sent  chanNotifier
+}
+
+func (n *failingNotifier) Notify(ctx context.Context, r reminder) error {
+	n.tried <- struct{}{}
+	if n.fails.Add(-1) >= 0 {
+		return errWebhookQueueFull
+	}
+	return n.sent.Notify(ctx, r)
+}
+
+func TestSchedulerRetriesRejected(t *testing.T) {
+	start := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	clk := &fakeClock{now: start}
+	st := newTaskStore()
+	due := start.Add(time.Hour)
+	st.mu.Lock()
+	st.createLocked(Task{Title: "informe", DueDate: &due})
+	st.mu.Unlock()
+	statePath := filepath.Join(t.TempDir(), "tasks.json.reminders")
+
+	n := &failingNotifier{tried: make(chan struct{}, 10), sent: make(chanNotifier, 10)}
+	n.fails.Store(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sch := newScheduler(st, []time.Duration{0}, clk, n)
+	sch.statePath = statePath
+	go sch.run(ctx)
+
+	// El primer intento falla: no se pierde ni se guarda nada.
+	time.Sleep(10 * time.Millisecond)
+	clk.Advance(time.Hour)
+	<-n.tried
+	noReminder(t, n.sent)
+	if _, err := os.Stat(statePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("estado guardado sin enviar nada: %v", err)
+	}
+
+	// Se reintenta pasado reminderRetry, y entonces sí se guarda.
+	clk.Advance(reminderRetry)
+	if r := nextReminder(t, n.sent); r.TaskID != 1 {
+		t.Errorf("got tarea %d; want 1", r.TaskID)
+	}
+	time.Sleep(10 * time.Millisecond)
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state schedulerState
+	if err := json.Unmarshal(data, &state); err != nil || state.SentUntil.Before(due) {
+		t.Errorf("estado = %s, %v; want sent_until >= %v", data, err, due)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	fails := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fails > 0 { // el primer intento falla: debe reintentar
+			fails--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		got <- body
+	}))
+	defer ts.Close()
+
+	wh := newWebhookNotifier(ts.URL, 4)
+	wh.backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go wh.run(ctx)
+
+	due := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := wh.Notify(ctx, reminder{TaskID: 7, Title: "informe", DueDate: due, Before: time.Hour, At: due.Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-got:
+		if body["task_id"] != 7.0 || body["before"] != "1h0m0s" || body["title"] != "informe" {
+			t.Errorf("cuerpo = %v", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("el webhook no recibió el aviso")
+	}
+}
+
+func TestWebhookQueueCheck(t *testing.T) {
+	wh := newWebhookNotifier("http://localhost:1", 4) // sin worker: la cola no se vacía
+	for i := range 4 {
+		err := wh.check(context.Background())
+		if wantErr := i >= 3; (err != nil) != wantErr {
+			t.Errorf("con %d en cola: check = %v; want error %v", i, err, wantErr)
+		}
+		if err := wh.Notify(context.Background(), reminder{TaskID: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wh.Notify(context.Background(), reminder{}); err != errWebhookQueueFull {
+		t.Errorf("cola llena: got %v; want %v", err, errWebhookQueueFull)
+	}
+}
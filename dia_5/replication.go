@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Replicación líder/seguidor. Cualquier instancia puede ser líder: expone
+//   - GET /replication/snapshot: el estado completo (como el fichero de datos).
+//   - GET /replication/stream?since=N: los cambios posteriores a N como
+//     NDJSON (un change por línea), sin fin; cuando no hay cambios manda
+//     latidos con su revisión actual.
+//   - GET /replication/blobs/{sha256}: el contenido de un adjunto.
+//
+// Una instancia arrancada con replicate_from es seguidora: copia el
+// snapshot del líder, aplica el stream en orden, sirve lecturas y
+// redirige las escrituras al líder con 307. Snapshot y stream solo llevan
+// los datos de los adjuntos; el contenido lo trae la primera vez que se
+// descarga (ver server.openBlob).
+//
+// Estos endpoints exponen todo el estado, así que exigen
+// "Authorization: Bearer <replication_token>" (o el admin_token). El
+// seguidor envía su replication_token.
+
+// replicationToken protege /replication/snapshot y /replication/stream
+// y es el que envía el seguidor. Se configura con replication_token.
+var replicationToken string
+
+// checkReplication comprueba el token de replicación. Si falla ya ha
+// respondido con 401.
+func checkReplication(w http.ResponseWriter, r *http.Request) bool {
+	return checkBearer(w, r, "replication", codeReplicationUnauthorized, replicationToken, adminToken)
+}
+
+// opHeartbeat marca los latidos del stream; no es un cambio del almacén.
+const opHeartbeat = "heartbeat"
+
+var (
+	// replicationHeartbeat es cada cuánto manda el líder un latido.
+	replicationHeartbeat = 5 * time.Second
+	// replicationRetry es la espera del seguidor antes de reconectar.
+	replicationRetry = time.Second
+)
+
+// errResync indica que el seguidor perdió el hilo (hueco en las
+// revisiones o log del líder ya recortado) y debe copiar el snapshot.
+var errResync = errors.New("réplica desincronizada")
+
+// replicationSnapshotHandler maneja GET /replication/snapshot.
+func (srv *server) replicationSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	if !checkReplication(w, r) {
+		return
+	}
+	srv.store.mu.RLock()
+	snap := srv.store.snapshotLocked()
+	srv.store.mu.RUnlock()
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// replicationStreamHandler maneja GET /replication/stream?since=N.
+func (srv *server) replicationStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	if !checkReplication(w, r) {
+		return
+	}
+	since, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		writeError(w, r, http.StatusBadRequest, codeInvalidRevision)
+		return
+	}
+
+	st := srv.store
+	st.mu.RLock()
+	current := st.revision
+	_, ok := st.changesSinceLocked(since)
+	st.mu.RUnlock()
+	if since > current {
+		writeError(w, r, http.StatusBadRequest, codeRevisionAhead, current)
+		return
+	}
+	if !ok {
+		writeError(w, r, http.StatusGone, codeRevisionTooOld, since)
+		return
+	}
+
+	// El stream no termina: sin plazo de escritura.
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	ticker := time.NewTicker(replicationHeartbeat)
+	defer ticker.Stop()
+	heartbeat := true // el primero dice al seguidor por dónde va el líder
+	for {
+		st.mu.RLock()
+		changes, ok := st.changesSinceLocked(since)
+		current, wake := st.revision, st.changed
+		st.mu.RUnlock()
+
+		// Log recortado o almacén reemplazado: se corta el stream y el
+		// seguidor, al reconectar, recibirá un error y copiará el snapshot.
+		if !ok || current < since {
+			return
+		}
+		for _, c := range changes {
+			if enc.Encode(c) != nil {
+				return
+			}
+			since = c.Revision
+		}
+		if heartbeat && len(changes) == 0 {
+			if enc.Encode(change{Revision: current, Op: opHeartbeat, At: time.Now().UTC()}) != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+
+		heartbeat = false
+		select {
+		case <-wake:
+		case <-ticker.C:
+			heartbeat = true
+		case <-shuttingDown:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// replicationBlobHandler maneja GET /replication/blobs/{sha256}.
+func (srv *server) replicationBlobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	if !checkReplication(w, r) {
+		return
+	}
+	sum := strings.TrimPrefix(r.URL.Path, "/replication/blobs/")
+	if srv.blobs == nil || !isHex(sum, sha256.Size*2) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := srv.blobs.open(sum)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// replicationStatusHandler maneja GET /replication/status.
+func (srv *server) replicationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	if srv.follower == nil {
+		srv.store.mu.RLock()
+		rev := srv.store.revision
+		srv.store.mu.RUnlock()
+		writeJSON(w, http.StatusOK, map[string]any{"role": "leader", "revision": rev})
+		return
+	}
+	writeJSON(w, http.StatusOK, srv.follower.status())
+}
+
+// follower replica el almacén de un líder.
+type follower struct {
+	leader string // URL base del líder, sin "/" final
+	token  string // replication_token que se envía al líder
+	store  *taskStore
+	client *http.Client
+
+	mu             sync.Mutex
+	leaderRevision int64
+	lastContact    time.Time
+	connected      bool
+	lastErr        error
+}
+
+// newFollower crea un seguidor de leader que aplica los cambios en st.
+func newFollower(leader string, st *taskStore) *follower {
+	return &follower{
+		leader: strings.TrimSuffix(leader, "/"),
+		token:  replicationToken,
+		store:  st,
+		client: &http.Client{}, // sin Timeout: el stream es indefinido
+	}
+}
+
+// run replica hasta que se cancele ctx, reconectando tras cada error.
+func (f *follower) run(ctx context.Context) {
+	resync := true // al arrancar siempre se copia el snapshot
+	for {
+		var err error
+		if resync {
+			err = f.bootstrap(ctx)
+			resync = err != nil
+		}
+		if err == nil {
+			err = f.stream(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errResync) {
+			resync = true
+		}
+		f.disconnected(err)
+		slog.Warn("replicación interrumpida", "leader", f.leader, "err", err)
+
+		select {
+		case <-time.After(replicationRetry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// bootstrap reemplaza el almacén local por el snapshot del líder.
+func (f *follower) bootstrap(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := f.get(ctx, "/replication/snapshot")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("snapshot: status %d", resp.StatusCode)
+	}
+	var snap snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+
+	f.store.mu.Lock()
+	f.store.replaceLocked(snap)
+	f.store.mu.Unlock()
+	f.contact(snap.Revision)
+	slog.Info("replicación: copia completa recibida del líder", "leader", f.leader, "revision", snap.Revision)
+	return nil
+}
+
+// stream aplica los cambios del líder hasta que se corte la conexión.
+func (f *follower) stream(ctx context.Context) error {
+	f.store.mu.RLock()
+	since := f.store.revision
+	f.store.mu.RUnlock()
+
+	resp, err := f.get(ctx, "/replication/stream?since="+strconv.FormatInt(since, 10))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone, http.StatusBadRequest:
+		return errResync // log recortado o líder por detrás de nosotros
+	default:
+		return fmt.Errorf("stream: status %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var c change
+		if err := dec.Decode(&c); err != nil {
+			return fmt.Errorf("stream: %w", err)
+		}
+		f.contact(c.Revision)
+		if c.Op == opHeartbeat {
+			continue
+		}
+		f.store.mu.Lock()
+		err := f.store.applyLocked(c)
+		f.store.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		slog.Debug("replicación: cambio aplicado", "revision", c.Revision, "op", c.Op, "task_id", c.TaskID)
+	}
+}
+
+// fetchBlob trae del líder el blob sum y lo guarda en blobs.
+func (f *follower) fetchBlob(ctx context.Context, sum string, blobs *blobStore) error {
+	resp, err := f.get(ctx, "/replication/blobs/"+sum)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("blob %s en el líder: %w", sum, fs.ErrNotExist)
+	default:
+		return fmt.Errorf("blob %s: status %d", sum, resp.StatusCode)
+	}
+	info, err := blobs.put(resp.Body, maxAttachmentSize)
+	if err != nil {
+		return fmt.Errorf("blob %s: %w", sum, err)
+	}
+	if info.SHA256 != sum {
+		return fmt.Errorf("blob %s: el líder envió %s", sum, info.SHA256)
+	}
+	return nil
+}
+
+// get hace una petición GET al líder.
+func (f *follower) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.leader+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	return f.client.Do(req)
+}
+
+// contact anota que el líder respondió y por qué revisión va.
+func (f *follower) contact(rev int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leaderRevision = max(f.leaderRevision, rev)
+	f.lastContact = time.Now()
+	f.connected = true
+	f.lastErr = nil
+}
+
+// disconnected anota el error que cortó la replicación.
+func (f *follower) disconnected(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = false
+	f.lastErr = err
+}
+
+// followerStatus es la respuesta de /replication/status en un seguidor.
+type followerStatus struct {
+	Role           string    `json:"role"`
+	Leader         string    `json:"leader"`
+	Connected      bool      `json:"connected"`
+	Revision       int64     `json:"revision"`
+	LeaderRevision int64     `json:"leader_revision"`
+	Lag            int64     `json:"lag"` // revisiones pendientes de aplicar
+	LastContact    time.Time `json:"last_contact,omitzero"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// status devuelve el estado actual de la replicación.
+func (f *follower) status() followerStatus {
+	f.store.mu.RLock()
+	rev := f.store.revision
+	f.store.mu.RUnlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := followerStatus{
+		Role:           "follower",
+		Leader:         f.leader,
+		Connected:      f.connected,
+		Revision:       rev,
+		LeaderRevision: f.leaderRevision,
+		Lag:            max(f.leaderRevision-rev, 0),
+		LastContact:    f.lastContact,
+	}
+	if f.lastErr != nil {
+		s.Error = f.lastErr.Error()
+	}
+	return s
+}
+
+// check es el chequeo de /readyz: el seguidor está listo si está conectado
+// y ha oído al líder hace poco (tres latidos).
+func (f *follower) check(context.Context) error {
+	s := f.status()
+	switch {
+	case !s.Connected && s.Error != "":
+		return errors.New(s.Error)
+	case !s.Connected:
+		return errors.New("sin conexión con el líder")
+	case time.Since(s.LastContact) > 3*replicationHeartbeat:
+		return fmt.Errorf("sin noticias del líder desde hace %v", time.Since(s.LastContact).Round(time.Second))
+	}
+	return nil
+}
+
+// applyLocked aplica un cambio recibido del líder. La revisión local debe
+// ser justo la anterior; si no, hay que volver a copiar el snapshot.
+func (s *taskStore) applyLocked(c change) error {
+	if c.Revision != s.revision+1 {
+		return fmt.Errorf("%w: esperaba revisión %d y llegó %d", errResync, s.revision+1, c.Revision)
+	}
+	switch c.Op {
+	case opCreate:
+		if c.Task == nil {
+			return fmt.Errorf("%w: create sin tarea", errResync)
+		}
+		s.nextID = max(s.nextID, c.TaskID+1)
+		s.insertLocked(*c.Task)
+	case opUpdate:
+		if c.Task == nil || !s.updateLocked(*c.Task) {
+			return fmt.Errorf("%w: update de la tarea %d", errResync, c.TaskID)
+		}
+	case opReorder:
+		if len(c.Tasks) == 0 || !s.updateBatchLocked(c.Tasks) {
+			return fmt.Errorf("%w: reorder de tareas inexistentes", errResync)
+		}
+	case opDelete:
+		if !s.removeLocked(c.TaskID, c.Tasks...) {
+			return fmt.Errorf("%w: delete de la tarea %d", errResync, c.TaskID)
+		}
+	case opProjectCreate:
+		if c.Project == nil {
+			return fmt.Errorf("%w: project.create sin proyecto", errResync)
+		}
+		s.insertProjectLocked(*c.Project)
+	case opProjectUpdate:
+		if c.Project == nil || !s.updateProjectLocked(*c.Project) {
+			return fmt.Errorf("%w: update del proyecto %d", errResync, c.ProjectID)
+		}
+	case opProjectDelete:
+		if err := s.removeProjectLocked(c.ProjectID); err != nil {
+			return fmt.Errorf("%w: delete del proyecto %d: %v", errResync, c.ProjectID, err)
+		}
+	default:
+		return fmt.Errorf("%w: operación %q desconocida", errResync, c.Op)
+	}
+	return nil
+}
+
+// withReadOnly rechaza las escrituras en un seguidor con un 307 al líder,
+// que conserva método y cuerpo al reintentar.
+func withReadOnly(leader string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Location", leader+r.URL.RequestURI())
+		writeError(w, r, http.StatusTemporaryRedirect, codeReadOnlyReplica, leader)
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withReplicationToken configura el token de replicación durante el test.
+// Hay que llamarlo antes de startFollower: el seguidor lo copia al crearse.
+func withReplicationToken(t *testing.T, token string) {
+	t.Helper()
+	saved := replicationToken
+	replicationToken = token
+	t.Cleanup(func() { replicationToken = saved })
+}
+
+// startFollower arranca un seguidor de leaderURL servido por otro httptest.Server.
+func startFollower(t *testing.T, leaderURL string) (*server, *httptest.Server) {
+	t.Helper()
+	srv := newServer(newTaskStore())
+	srv.follower = newFollower(leaderURL, srv.store)
+	ts := httptest.NewServer(srv.routes())
+	ctx, cancel := context.WithCancel(context.Background())
+	go srv.follower.run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		ts.Close()
+	})
+	return srv, ts
+}
+
+// waitRevision espera a que el almacén llegue a la revisión rev.
+func waitRevision(t *testing.T, st *taskStore, rev int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		st.mu.Lock()
+		got := st.revision
+		st.mu.Unlock()
+		if got == rev {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("el seguidor no llegó a la revisión %d", rev)
+}
+
+func TestReplicationLeaderFollower(t *testing.T) {
+	withReplicationToken(t, "réplica")
+	leader := newServer(newTaskStore())
+	lts := httptest.NewServer(leader.routes())
+	t.Cleanup(lts.Close)
+
+	// Tareas creadas antes de arrancar el seguidor: llegan con el snapshot.
+	for _, title := range []string{"antes 1", "antes 2"} {
+		resp, err := http.Post(lts.URL+"/tasks", "application/json", strings.NewReader(`{"title":"`+title+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	follower, fts := startFollower(t, lts.URL)
+	waitRevision(t, follower.store, 2)
+
+	// Cambios posteriores: llegan por el stream.
+	st := leader.store
+	st.mu.Lock()
+	st.createLocked(Task{Title: "después"})
+	t1, _ := st.getLocked(1)
+	t1.Done = true
+	st.updateLocked(t1)
+	st.removeLocked(2)
+	// Borrar una tarea de una columna renumera el resto en el mismo cambio.
+	p := st.createProjectLocked(Project{Name: "Web", Columns: defaultColumns})
+	for _, title := range []string{"arriba", "abajo"} {
+		task := Task{Title: title}
+		if err := st.placeNewLocked(&task, p.ID, ""); err != nil {
+			t.Fatal(err)
+		}
+		st.createLocked(task)
+	}
+	st.deleteTaskLocked(4)
+	st.mu.Unlock()
+	waitRevision(t, follower.store, 9)
+
+	st.mu.Lock()
+	want := st.snapshotLocked()
+	st.mu.Unlock()
+	follower.store.mu.Lock()
+	got := follower.store.snapshotLocked()
+	follower.store.mu.Unlock()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("seguidor = %+v; want %+v", got, want)
+	}
+
+	// Lecturas en el seguidor.
+	resp, err := http.Get(fts.URL + "/tasks/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /tasks/3 en el seguidor: status = %d; want 200", resp.StatusCode)
+	}
+
+	// Escrituras en el seguidor: 307 al líder.
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = noRedirect.Post(fts.URL+"/tasks", "application/json", strings.NewReader(`{"title":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTemporaryRedirect || resp.Header.Get("Location") != lts.URL+"/tasks" {
+		t.Errorf("POST en el seguidor: got %d %q; want 307 %q", resp.StatusCode, resp.Header.Get("Location"), lts.URL+"/tasks")
+	}
+
+	// Siguiendo la redirección la tarea se crea en el líder y se replica.
+	resp, err = http.Post(fts.URL+"/tasks", "application/json", strings.NewReader(`{"title":"vía seguidor"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("POST redirigido: status = %d; want 201", resp.StatusCode)
+	}
+	waitRevision(t, follower.store, 10)
+
+	// Estado y lag.
+	resp, err = http.Get(fts.URL + "/replication/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status followerStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Role != "follower" || !status.Connected || status.Revision != 10 || status.Lag != 0 {
+		t.Errorf("status = %+v", status)
+	}
+	if err := follower.follower.check(context.Background()); err != nil {
+		t.Errorf("check: %v", err)
+	}
+}
+
+func TestReplicationFollowerDownload(t *testing.T) {
+	// El seguidor no recibe el contenido de los adjuntos por el stream: lo
+	// trae del líder la primera vez que se descarga.
+	withReplicationToken(t, "réplica")
+	leader := newAttachmentServer(t)
+	lts := httptest.NewServer(leader.routes())
+	t.Cleanup(lts.Close)
+	content := []byte("contenido del adjunto")
+	if w := upload(t, leader.routes(), "1", "file", "log.txt", content); w.Code != http.StatusCreated {
+		t.Fatalf("subida: status = %d", w.Code)
+	}
+	leader.store.mu.RLock()
+	rev := leader.store.revision
+	leader.store.mu.RUnlock()
+
+	follower, fts := startFollower(t, lts.URL)
+	dir := t.TempDir()
+	follower.blobs = newBlobStore(dir)
+	waitRevision(t, follower.store, rev)
+
+	for i := range 2 {
+		resp, err := http.Get(fts.URL + "/tasks/1/attachments/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(got, content) {
+			t.Errorf("descarga %d en el seguidor: got %d %q; want 200 %q", i+1, resp.StatusCode, got, content)
+		}
+		if n := countBlobs(t, dir); n != 1 {
+			t.Errorf("descarga %d: el seguidor tiene %d blobs; want 1", i+1, n)
+		}
+	}
+
+	// Sin el token, el líder no entrega blobs.
+	resp, err := http.Get(lts.URL + "/replication/blobs/" + strings.Repeat("0", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("blob sin token: status = %d; want 401", resp.StatusCode)
+	}
+}
+
+func TestReplicationRequiresToken(t *testing.T) {
+	withReplicationToken(t, "réplica")
+	leader := newServer(newTaskStore())
+	lts := httptest.NewServer(leader.routes())
+	t.Cleanup(lts.Close)
+
+	for _, path := range []string{"/replication/snapshot", "/replication/stream?since=0"} {
+		resp, err := http.Get(lts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("GET %s sin token: status = %d; want 401", path, resp.StatusCode)
+		}
+	}
+
+	// Un seguidor con otro token no consigue copiar el estado.
+	f := newFollower(lts.URL, newTaskStore())
+	f.token = "otro"
+	if err := f.bootstrap(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("bootstrap con token erróneo: err = %v; want status 401", err)
+	}
+}
+
+func TestApplyLockedDetectsGaps(t *testing.T) {
+	task := &Task{ID: 1, Title: "a"}
+	project := &Project{ID: 1, Name: "Web", Columns: defaultColumns}
+	tests := []struct {
+		name    string
+		c       change
+		wantErr bool
+	}{
+		{"create en orden", change{Revision: 1, Op: opCreate, TaskID: 1, Task: task}, false},
+		{"hueco de revisiones", change{Revision: 3, Op: opCreate, TaskID: 1, Task: task}, true},
+		{"update de tarea inexistente", change{Revision: 1, Op: opUpdate, TaskID: 1, Task: task}, true},
+		{"delete de tarea inexistente", change{Revision: 1, Op: opDelete, TaskID: 9}, true},
+		{"operación desconocida", change{Revision: 1, Op: "rename", TaskID: 1}, true},
+		{"create de proyecto", change{Revision: 1, Op: opProjectCreate, ProjectID: 1, Project: project}, false},
+		{"update de proyecto inexistente", change{Revision: 1, Op: opProjectUpdate, ProjectID: 1, Project: project}, true},
+		{"delete de proyecto inexistente", change{Revision: 1, Op: opProjectDelete, ProjectID: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTaskStore()
+			st.mu.Lock()
+			err := st.applyLocked(tt.c)
+			st.mu.Unlock()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got err %v; want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// routeServer devuelve un servidor con dos tareas (la 1 con fecha límite,
+// en el proyecto 1 y con un adjunto) listo para la tabla de rutas.
+func routeServer(t *testing.T) http.Handler {
+	t.Helper()
+	withAdminToken(t, "secreto")
+	withReplicationToken(t, "réplica")
+	srv := newServer(newTaskStore())
+	srv.blobs = newBlobStore(t.TempDir())
+	due := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	st := srv.store
+	st.mu.Lock()
+	p := st.createProjectLocked(Project{Name: "Web", Columns: defaultColumns})
+	first := Task{Title: "Aprender Go", CreatedAt: due, DueDate: &due}
+	_ = st.placeNewLocked(&first, p.ID, "")
+	st.createLocked(first)
+	st.createLocked(Task{Title: "otra", CreatedAt: due})
+	st.mu.Unlock()
+	h := srv.routes()
+	if w := upload(t, h, "1", "file", "nota.txt", []byte("hola")); w.Code != http.StatusCreated {
+		t.Fatalf("subida: status = %d", w.Code)
+	}
+	return h
+}
+
+// TestRoutes recorre todas las rutas con los status y las cabeceras que
+// devuelve cada una. Una cabecera con valor "*" solo tiene que estar.
+func TestRoutes(t *testing.T) {
+	const (
+		jsonType    = "application/json; charset=utf-8"
+		problemType = problemContentType
+	)
+	replica := map[string]string{"Authorization": "Bearer réplica"}
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		header       map[string]string // cabeceras de la petición
+		cancel       bool              // contexto ya cancelado (streams)
+		wantStatus   int
+		wantHeader   map[string]string
+	}{
+		{"ping", "GET", "/ping", "", nil, false, 200, map[string]string{"Content-Type": "text/plain; charset=utf-8"}},
+		{"healthz", "GET", "/healthz", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"healthz HEAD", "HEAD", "/healthz", "", nil, false, 200, nil},
+		{"healthz POST", "POST", "/healthz", "", nil, false, 405, map[string]string{"Allow": "GET, HEAD", "Content-Type": problemType}},
+		{"readyz", "GET", "/readyz", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"readyz POST", "POST", "/readyz", "", nil, false, 405, map[string]string{"Allow": "GET, HEAD"}},
+		{"whoami", "GET", "/whoami", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"whoami POST", "POST", "/whoami", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+
+		{"listar", "GET", "/tasks", "", nil, false, 200, map[string]string{"Content-Type": jsonType, "X-Tasks-Revision": "*"}},
+		{"crear", "POST", "/tasks", `{"title":"nueva"}`, nil, false, 201, map[string]string{"Location": "/tasks/3", "Content-Type": jsonType}},
+		{"crear en proyecto", "POST", "/tasks", `{"title":"nueva","project_id":1,"column":"doing"}`, nil, false, 201, map[string]string{"Location": "/tasks/3"}},
+		{"crear sin título", "POST", "/tasks", `{"title":""}`, nil, false, 400, map[string]string{"Content-Type": problemType}},
+		{"crear JSON inválido", "POST", "/tasks", `{`, nil, false, 400, map[string]string{"Content-Type": problemType}},
+		{"crear columna sin proyecto", "POST", "/tasks", `{"title":"a","column":"doing"}`, nil, false, 400, nil},
+		{"crear proyecto inexistente", "POST", "/tasks", `{"title":"a","project_id":9}`, nil, false, 400, nil},
+		{"tasks DELETE", "DELETE", "/tasks", "", nil, false, 405, map[string]string{"Allow": "GET, POST"}},
+		{"obtener", "GET", "/tasks/1", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"obtener inexistente", "GET", "/tasks/99", "", nil, false, 404, map[string]string{"Content-Type": problemType}},
+		{"obtener sin ID", "GET", "/tasks/", "", nil, false, 400, nil},
+		{"obtener ID inválido", "GET", "/tasks/abc", "", nil, false, 400, nil},
+		{"tarea DELETE", "DELETE", "/tasks/1", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+		{"problema en inglés", "GET", "/tasks/99", "", map[string]string{"Accept-Language": "en"}, false, 404, map[string]string{"Content-Language": "en"}},
+
+		{"mover", "POST", "/tasks/1/move", `{"column":"done"}`, nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"mover a otro proyecto", "POST", "/tasks/2/move", `{"project_id":1}`, nil, false, 200, nil},
+		{"mover columna inexistente", "POST", "/tasks/1/move", `{"column":"nope"}`, nil, false, 400, nil},
+		{"mover inexistente", "POST", "/tasks/99/move", `{"project_id":1}`, nil, false, 404, nil},
+		{"mover GET", "GET", "/tasks/1/move", "", nil, false, 405, map[string]string{"Allow": "POST"}},
+
+		{"adjuntos", "GET", "/tasks/1/attachments", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"adjuntos PUT", "PUT", "/tasks/1/attachments", "", nil, false, 405, map[string]string{"Allow": "GET, POST"}},
+		{"descargar", "GET", "/tasks/1/attachments/1", "", nil, false, 200, map[string]string{
+			"Content-Type": "text/plain; charset=utf-8", "Content-Disposition": `attachment; filename=nota.txt`,
+			"X-Content-Type-Options": "nosniff", "ETag": "*", "Accept-Ranges": "bytes",
+		}},
+		{"descargar rango", "GET", "/tasks/1/attachments/1", "", map[string]string{"Range": "bytes=0-1"}, false, 206, map[string]string{"Content-Range": "bytes 0-1/4"}},
+		{"descargar inexistente", "GET", "/tasks/1/attachments/9", "", nil, false, 404, nil},
+		{"adjunto ID inválido", "GET", "/tasks/1/attachments/x", "", nil, false, 400, nil},
+		{"borrar adjunto", "DELETE", "/tasks/1/attachments/1", "", nil, false, 204, nil},
+		{"adjunto POST", "POST", "/tasks/1/attachments/1", "", nil, false, 405, map[string]string{"Allow": "GET, HEAD, DELETE"}},
+
+		{"calendario", "GET", "/tasks/calendar.ics", "", nil, false, 200, map[string]string{
+			"Content-Type": "text/calendar; charset=utf-8", "Content-Disposition": `inline; filename="calendar.ics"`,
+		}},
+		{"calendario POST", "POST", "/tasks/calendar.ics", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+		{"cambios", "GET", "/tasks/changes?since=0&wait=0s", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"cambios sin since", "GET", "/tasks/changes", "", nil, false, 400, nil},
+		{"cambios wait inválido", "GET", "/tasks/changes?since=0&wait=1h", "", nil, false, 400, nil},
+		{"cambios del futuro", "GET", "/tasks/changes?since=999&wait=0s", "", nil, false, 400, nil},
+		{"cambios POST", "POST", "/tasks/changes", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+
+		{"buscar", "GET", "/search?q=go", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"buscar sin q", "GET", "/search", "", nil, false, 400, nil},
+		{"buscar limit inválido", "GET", "/search?q=go&limit=x", "", nil, false, 400, nil},
+		{"buscar POST", "POST", "/search", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+
+		{"rpc", "POST", "/rpc", `{"jsonrpc":"2.0","method":"tasks.get","params":{"id":1},"id":1}`, nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"rpc notificación", "POST", "/rpc", `{"jsonrpc":"2.0","method":"tasks.list"}`, nil, false, 204, nil},
+		{"rpc JSON inválido", "POST", "/rpc", `{`, nil, false, 200, nil},
+		{"rpc GET", "GET", "/rpc", "", nil, false, 405, map[string]string{"Allow": "POST"}},
+
+		{"proyectos", "GET", "/projects", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"crear proyecto", "POST", "/projects", `{"name":"App"}`, nil, false, 201, map[string]string{"Location": "/projects/2"}},
+		{"crear proyecto sin nombre", "POST", "/projects", `{"name":""}`, nil, false, 400, nil},
+		{"proyectos PUT", "PUT", "/projects", "", nil, false, 405, map[string]string{"Allow": "GET, POST"}},
+		{"tablero", "GET", "/projects/1", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"tablero inexistente", "GET", "/projects/9", "", nil, false, 404, nil},
+		{"tablero ID inválido", "GET", "/projects/x", "", nil, false, 400, nil},
+		{"renombrar proyecto", "PATCH", "/projects/1", `{"name":"Web 2"}`, nil, false, 200, nil},
+		{"borrar proyecto con tareas", "DELETE", "/projects/1", "", nil, false, 409, nil},
+		{"proyecto POST", "POST", "/projects/1", "", nil, false, 405, map[string]string{"Allow": "GET, PATCH, DELETE"}},
+
+		{"interfaz", "GET", "/", "", nil, false, 200, map[string]string{"Content-Type": "text/html; charset=utf-8", "Set-Cookie": "*"}},
+		{"interfaz POST", "POST", "/", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+		{"ruta desconocida", "GET", "/nope", "", nil, false, 404, nil},
+		{"alta sin CSRF", "POST", "/ui/tasks", "title=a", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, false, 403, nil},
+		{"alta GET", "GET", "/ui/tasks", "", nil, false, 405, map[string]string{"Allow": "POST"}},
+		{"acción sin CSRF", "POST", "/ui/tasks/1/toggle", "", nil, false, 403, nil},
+		{"acción GET", "GET", "/ui/tasks/1/toggle", "", nil, false, 405, map[string]string{"Allow": "POST"}},
+		{"estático", "GET", "/ui/static/style.css", "", nil, false, 200, map[string]string{"Content-Type": "text/css; charset=utf-8"}},
+		{"estático inexistente", "GET", "/ui/static/nope.js", "", nil, false, 404, nil},
+
+		{"snapshot", "GET", "/replication/snapshot", "", replica, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"snapshot con token admin", "GET", "/replication/snapshot", "", map[string]string{"Authorization": "Bearer secreto"}, false, 200, nil},
+		{"snapshot sin token", "GET", "/replication/snapshot", "", nil, false, 401, map[string]string{"WWW-Authenticate": `Bearer realm="replication"`, "Content-Type": problemType}},
+		{"snapshot token erróneo", "GET", "/replication/snapshot", "", map[string]string{"Authorization": "Bearer otro"}, false, 401, nil},
+		{"snapshot POST", "POST", "/replication/snapshot", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+		{"stream", "GET", "/replication/stream?since=0", "", replica, true, 200, map[string]string{"Content-Type": "application/x-ndjson"}},
+		{"stream sin token", "GET", "/replication/stream?since=0", "", nil, true, 401, map[string]string{"WWW-Authenticate": `Bearer realm="replication"`}},
+		{"stream sin since", "GET", "/replication/stream", "", replica, false, 400, nil},
+		{"stream del futuro", "GET", "/replication/stream?since=999", "", replica, false, 400, nil},
+		{"blob inexistente", "GET", "/replication/blobs/" + strings.Repeat("0", 64), "", replica, false, 404, nil},
+		{"blob hash inválido", "GET", "/replication/blobs/x", "", replica, false, 404, nil},
+		{"blob sin token", "GET", "/replication/blobs/x", "", nil, false, 401, nil},
+		{"blob POST", "POST", "/replication/blobs/x", "", replica, false, 405, map[string]string{"Allow": "GET"}},
+		{"estado réplica", "GET", "/replication/status", "", nil, false, 200, map[string]string{"Content-Type": jsonType}},
+		{"estado réplica POST", "POST", "/replication/status", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+
+		{"backup", "GET", "/admin/backup", "", map[string]string{"Authorization": "Bearer secreto"}, false, 200, map[string]string{
+			"Content-Type": "application/x-tar", "Content-Disposition": "*",
+		}},
+		{"backup sin token", "GET", "/admin/backup", "", nil, false, 401, map[string]string{"WWW-Authenticate": `Bearer realm="admin"`}},
+		{"backup token erróneo", "GET", "/admin/backup", "", map[string]string{"Authorization": "Bearer otro"}, false, 401, nil},
+		{"backup POST", "POST", "/admin/backup", "", nil, false, 405, map[string]string{"Allow": "GET"}},
+		{"restore inválido", "POST", "/admin/restore", `{}`, map[string]string{"Authorization": "Bearer secreto"}, false, 400, nil},
+		{"restore sin token", "POST", "/admin/restore", `{}`, nil, false, 401, nil},
+		{"restore GET", "GET", "/admin/restore", "", nil, false, 405, map[string]string{"Allow": "POST"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := routeServer(t)
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if tt.cancel {
+				ctx, cancel := context.WithCancel(r.Context())
+				cancel()
+				r = r.WithContext(ctx)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s %s: status = %d; want %d (%s)", tt.method, tt.path, w.Code, tt.wantStatus, strings.TrimSpace(w.Body.String()))
+			}
+			for k, want := range tt.wantHeader {
+				got := w.Header().Get(k)
+				if (want == "*" && got == "") || (want != "*" && got != want) {
+					t.Errorf("%s = %q; want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// JSON-RPC 2.0 en POST /rpc, para herramientas que no hablan REST. Los
+// métodos tasks.list, tasks.get, tasks.create, tasks.update y tasks.delete
+// trabajan sobre el mismo almacén y con la misma validación que /tasks.
+// Admite lotes (un array de peticiones) y notificaciones (sin id, que no
+// reciben respuesta). Los parámetros van siempre por nombre.
+
+// Códigos de error de JSON-RPC. Los del rango -32000..-32099 son propios;
+// el code estable de la API viaja en data.code.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcNotFound       = -32001
+	rpcConflict       = -32002
+	rpcForbidden      = -32003
+)
+
+// rpcRequest es una petición o notificación JSON-RPC.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // ausente = notificación
+}
+
+// rpcResponse es la respuesta a una petición con id.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError es el objeto error de JSON-RPC.
+type rpcError struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    *rpcErrorData `json:"data,omitempty"`
+}
+
+// rpcErrorData lleva el code estable de la API y, en los errores de
+// validación, el detalle por campo.
+type rpcErrorData struct {
+	Code   string        `json:"code"`
+	Errors []*fieldError `json:"errors,omitempty"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// newRPCError crea un error JSON-RPC con el mensaje del catálogo en lang.
+func newRPCError(lang string, rpcCode int, code string, args ...any) *rpcError {
+	return &rpcError{Code: rpcCode, Message: localize(lang, code, args...).Detail, Data: &rpcErrorData{Code: code}}
+}
+
+// rpcHandler maneja POST /rpc.
+func (srv *server) rpcHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "POST")
+		return
+	}
+	lang := requestLang(r)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", Error: newRPCError(lang, rpcInvalidRequest, codeBodyTooLarge, tooLarge.Limit), ID: json.RawMessage("null")})
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", Error: newRPCError(lang, rpcParseError, codeInvalidJSON), ID: json.RawMessage("null")})
+			return
+		}
+		if len(batch) == 0 {
+			writeJSON(w, http.StatusOK, rpcResponse{JSONRPC: "2.0", Error: newRPCError(lang, rpcInvalidRequest, codeInvalidRPCRequest), ID: json.RawMessage("null")})
+			return
+		}
+		var responses []rpcResponse
+		for _, raw := range batch {
+			if resp, ok := srv.rpcCall(r, raw); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent) // solo notificaciones
+			return
+		}
+		writeJSON(w, http.StatusOK, responses)
+		return
+	}
+
+	resp, ok := srv.rpcCall(r, body)
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// rpcCall ejecuta una petición. Devuelve false si era una notificación y
+// no hay que responder.
+func (srv *server) rpcCall(r *http.Request, raw json.RawMessage) (rpcResponse, bool) {
+	lang := requestLang(r)
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+
+	if !json.Valid(raw) {
+		resp.Error = newRPCError(lang, rpcParseError, codeInvalidJSON)
+		return resp, true
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" || !validRPCID(req.ID) {
+		resp.Error = newRPCError(lang, rpcInvalidRequest, codeInvalidRPCRequest)
+		return resp, true
+	}
+
+	result, err := srv.rpcDispatch(r, req)
+	if req.ID == nil {
+		return resp, false
+	}
+	resp.ID = req.ID
+	if err != nil {
+		resp.Error = toRPCError(lang, err)
+		return resp, true
+	}
+	resp.Result = result
+	return resp, true
+}
+
+// validRPCID indica si id es válido: ausente, null, cadena o número.
+func validRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '{', '[', 't', 'f':
+		return false
+	}
+	return true
+}
+
+// rpcIDParams son los parámetros de tasks.get y tasks.delete.
+type rpcIDParams struct {
+	ID int `json:"id"`
+}
+
+// rpcUpdateParams son los parámetros de tasks.update; los campos ausentes
+// no cambian.
+type rpcUpdateParams struct {
+	ID      int        `json:"id"`
+	Title   *string    `json:"title,omitempty"`
+	Done    *bool      `json:"done,omitempty"`
+	DueDate *time.Time `json:"due_date,omitempty"`
+}
+
+// rpcDispatch ejecuta el método de req.
+func (srv *server) rpcDispatch(r *http.Request, req rpcRequest) (any, error) {
+	lang := requestLang(r)
+	st := srv.store
+	switch req.Method {
+	case "tasks.list":
+		if err := decodeParams(req.Params, &struct{}{}); err != nil {
+			return nil, err
+		}
+		st.mu.RLock()
+		defer st.mu.RUnlock()
+		return st.listLocked(), nil
+	case "tasks.get":
+		var p rpcIDParams
+		if err := decodeParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		st.mu.RLock()
+		defer st.mu.RUnlock()
+		t, ok := st.getLocked(p.ID)
+		if !ok {
+			return nil, newRPCError(lang, rpcNotFound, codeTaskNotFound, p.ID)
+		}
+		return t, nil
+	case "tasks.create":
+		var in taskInput
+		if err := decodeParams(req.Params, &in); err != nil {
+			return nil, err
+		}
+		return st.addTask(r.Context(), in, userFromRequest(r))
+	case "tasks.update":
+		var p rpcUpdateParams
+		if err := decodeParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		t, err := st.editTask(r.Context(), p)
+		if errors.Is(err, errTaskNotFound) {
+			return nil, newRPCError(lang, rpcNotFound, codeTaskNotFound, p.ID)
+		}
+		return t, err
+	case "tasks.delete":
+		var p rpcIDParams
+		if err := decodeParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		st.lockFor(r.Context())
+		defer st.unlock()
+		if !st.deleteTaskLocked(p.ID) {
+			return nil, newRPCError(lang, rpcNotFound, codeTaskNotFound, p.ID)
+		}
+		return true, nil
+	default:
+		return nil, newRPCError(lang, rpcMethodNotFound, codeRPCMethodNotFound, req.Method)
+	}
+}
+
+// editTask aplica los cambios de tasks.update con la misma validación que
+// el alta.
+func (s *taskStore) editTask(ctx context.Context, p rpcUpdateParams) (Task, error) {
+	if p.Title != nil {
+		if err := validateTitle(*p.Title); err != nil {
+			return Task{}, err
+		}
+	}
+	s.lockFor(ctx)
+	defer s.unlock()
+	t, ok := s.getLocked(p.ID)
+	if !ok {
+		return Task{}, errTaskNotFound
+	}
+	if p.Title != nil {
+		t.Title = *p.Title
+	}
+	if p.Done != nil {
+		t.Done = *p.Done
+	}
+	if p.DueDate != nil {
+		due := p.DueDate.UTC()
+		t.DueDate = &due
+	}
+	s.updateLocked(t)
+	t, _ = s.getLocked(t.ID) // con la UpdatedAt que le puso el almacén
+	return t, nil
+}
+
+// errInvalidParams indica que params no encaja con el método.
+var errInvalidParams = errors.New("parámetros inválidos")
+
+// decodeParams lee params (un objeto, por nombre) en v, sin campos
+// desconocidos. Sin params se deja v a cero.
+func decodeParams(params json.RawMessage, v any) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	if params[0] != '{' {
+		return errInvalidParams
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errInvalidParams
+	}
+	return nil
+}
+
+// toRPCError traduce un error de los métodos a su error JSON-RPC.
+func toRPCError(lang string, err error) *rpcError {
+	var re *rpcError
+	var fe *fieldError
+	var wip *wipLimitError
+	switch {
+	case errors.As(err, &re):
+		return re
+	case errors.Is(err, errInvalidParams):
+		return newRPCError(lang, rpcInvalidParams, codeInvalidParams)
+	case errors.As(err, &fe):
+		e := newRPCError(lang, rpcInvalidParams, codeValidationFailed)
+		e.Data.Errors = localizeFieldErrors(lang, err)
+		e.Message = e.Data.Errors[0].Detail
+		return e
+	case errors.Is(err, errQuotaExceeded):
+		return newRPCError(lang, rpcForbidden, codeQuotaExceeded, maxTasksPerUser)
+	case errors.As(err, &wip):
+		return newRPCError(lang, rpcConflict, codeWIPLimitExceeded, wip.column, wip.limit)
+	default:
+		return newRPCError(lang, rpcInternalError, codeInternalError)
+	}
+}
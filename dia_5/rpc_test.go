@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rpcPost envía body a /rpc y devuelve la respuesta.
+func rpcPost(t *testing.T, srv *server, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.rpcHandler(w, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+	return w
+}
+
+func TestRPCMethods(t *testing.T) {
+	srv := newServer(newTaskStore())
+
+	tests := []struct {
+		name        string
+		body        string
+		wantErrCode int // 0 = sin error
+		wantData    string
+		wantResult  string // JSON del resultado, si se comprueba
+	}{
+		{"create", `{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"Aprender Go"},"id":1}`, 0, "", ""},
+		{"create sin título", `{"jsonrpc":"2.0","method":"tasks.create","params":{"title":" "},"id":2}`, rpcInvalidParams, codeValidationFailed, ""},
+		{"create con campo desconocido", `{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"a","x":1},"id":3}`, rpcInvalidParams, codeInvalidParams, ""},
+		{"params por posición", `{"jsonrpc":"2.0","method":"tasks.get","params":[1],"id":4}`, rpcInvalidParams, codeInvalidParams, ""},
+		{"get", `{"jsonrpc":"2.0","method":"tasks.get","params":{"id":1},"id":5}`, 0, "", ""},
+		{"get inexistente", `{"jsonrpc":"2.0","method":"tasks.get","params":{"id":99},"id":6}`, rpcNotFound, codeTaskNotFound, ""},
+		{"update", `{"jsonrpc":"2.0","method":"tasks.update","params":{"id":1,"done":true},"id":7}`, 0, "", ""},
+		{"list", `{"jsonrpc":"2.0","method":"tasks.list","id":"a"}`, 0, "", ""},
+		{"delete", `{"jsonrpc":"2.0","method":"tasks.delete","params":{"id":1},"id":8}`, 0, "", "true"},
+		{"delete repetido", `{"jsonrpc":"2.0","method":"tasks.delete","params":{"id":1},"id":9}`, rpcNotFound, codeTaskNotFound, ""},
+		{"método desconocido", `{"jsonrpc":"2.0","method":"tasks.rename","id":10}`, rpcMethodNotFound, codeRPCMethodNotFound, ""},
+		{"versión incorrecta", `{"jsonrpc":"1.0","method":"tasks.list","id":11}`, rpcInvalidRequest, codeInvalidRPCRequest, ""},
+		{"JSON roto", `{"jsonrpc":`, rpcParseError, codeInvalidJSON, ""},
+		{"lote vacío", `[]`, rpcInvalidRequest, codeInvalidRPCRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := rpcPost(t, srv, tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; want 200", w.Code)
+			}
+			var resp struct {
+				Result json.RawMessage `json:"result"`
+				Error  *rpcError       `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			gotCode, gotData := 0, ""
+			if resp.Error != nil {
+				gotCode = resp.Error.Code
+				if resp.Error.Data != nil {
+					gotData = resp.Error.Data.Code
+				}
+			}
+			if gotCode != tt.wantErrCode || gotData != tt.wantData {
+				t.Errorf("error = %d %q; want %d %q", gotCode, gotData, tt.wantErrCode, tt.wantData)
+			}
+			if tt.wantResult != "" && string(resp.Result) != tt.wantResult {
+				t.Errorf("result = %s; want %s", resp.Result, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestRPCBatchAndNotifications(t *testing.T) {
+	srv := newServer(newTaskStore())
+
+	// Solo notificaciones: se ejecutan pero no hay respuesta.
+	w := rpcPost(t, srv, `[{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"a"}},{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"b"}}]`)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("lote de notificaciones: got %d %q; want 204 sin cuerpo", w.Code, w.Body)
+	}
+	if w := rpcPost(t, srv, `{"jsonrpc":"2.0","method":"tasks.delete","params":{"id":99}}`); w.Code != http.StatusNoContent {
+		t.Errorf("notificación con error: status = %d; want 204", w.Code)
+	}
+
+	// Lote mixto: una respuesta por cada petición con id, en orden.
+	w = rpcPost(t, srv, `[
+		{"jsonrpc":"2.0","method":"tasks.list","id":1},
+		{"jsonrpc":"2.0","method":"tasks.update","params":{"id":2,"title":"b2"}},
+		1,
+		{"jsonrpc":"2.0","method":"tasks.get","params":{"id":2},"id":2}
+	]`)
+	var resps []struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resps); err != nil {
+		t.Fatal(err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("got %d respuestas; want 3", len(resps))
+	}
+	var list []Task
+	if err := json.Unmarshal(resps[0].Result, &list); err != nil || len(list) != 2 {
+		t.Errorf("tasks.list = %s; want 2 tareas", resps[0].Result)
+	}
+	if string(resps[1].ID) != "null" || resps[1].Error == nil || resps[1].Error.Code != rpcInvalidRequest {
+		t.Errorf("elemento 1: got id %s error %+v; want id null e invalid request", resps[1].ID, resps[1].Error)
+	}
+	var got Task
+	if err := json.Unmarshal(resps[2].Result, &got); err != nil || got.Title != "b2" {
+		t.Errorf("tasks.get tras la notificación = %s; want título b2", resps[2].Result)
+	}
+}
+
+func TestRPCSharesRESTValidation(t *testing.T) {
+	saved := maxTasksPerUser
+	maxTasksPerUser = 1
+	t.Cleanup(func() { maxTasksPerUser = saved })
+
+	srv := newServer(newTaskStore())
+	w := httptest.NewRecorder()
+	srv.tasksHandler(w, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"por REST"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /tasks: status = %d", w.Code)
+	}
+
+	w = rpcPost(t, srv, `{"jsonrpc":"2.0","method":"tasks.create","params":{"title":"por RPC"},"id":1}`)
+	var resp struct {
+		Error *rpcError `json:"error"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Error == nil || resp.Error.Code != rpcForbidden || resp.Error.Data.Code != codeQuotaExceeded {
+		t.Errorf("error = %+v; want %d %s", resp.Error, rpcForbidden, codeQuotaExceeded)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchIndex es un índice invertido en memoria sobre los títulos de las
+// tareas. Se actualiza de forma incremental desde las funciones de store.go,
+// por lo que comparte la protección de mu con el slice de tareas. Solo
+// indexa títulos: las tareas no tienen comentarios ni descripción.
+type searchIndex struct {
+	postings map[string]map[int]int // término -> ID de tarea -> frecuencia
+	docs     map[int]Task           // copia de la tarea indexada
+	docTerms map[int][]string       // términos de cada tarea (para desindexar)
+	terms    []string               // términos ordenados para buscar por prefijo
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[int]int),
+		docs:     make(map[int]Task),
+		docTerms: make(map[int][]string),
+	}
+}
+
+// token es una palabra del texto original con su término normalizado y su
+// posición en bytes (para poder resaltarla después).
+type token struct {
+	term       string
+	start, end int
+}
+
+// stopwords contiene palabras vacías en español e inglés que no aportan
+// nada a la búsqueda.
+var stopwords = map[string]bool{
+	// español
+	"a": true, "al": true, "con": true, "de": true, "del": true, "el": true,
+	"en": true, "es": true, "la": true, "las": true, "lo": true, "los": true,
+	"o": true, "para": true, "por": true, "que": true, "se": true, "su": true,
+	"un": true, "una": true, "y": true,
+	// inglés
+	"an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "the": true, "this": true, "to": true,
+	"with": true,
+}
+
+//go:generate go run gen_fold.go
+
+// normalizeWord pasa a minúsculas y elimina acentos y demás marcas
+// combinantes, como NFD seguido de quitar las marcas ("canción" →
+// "cancion"). Las letras precompuestas se llevan a su base con foldBase
+// (generada de UnicodeData.txt); las marcas sueltas, de un texto ya
+// descompuesto, se descartan.
+func normalizeWord(w string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(w) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if base, ok := foldBase[r]; ok {
+			r = base
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// tokenize divide s en palabras (letras y dígitos) y las normaliza.
+// No descarta stopwords: eso lo decide quien llama.
+func tokenize(s string) []token {
+	var toks []token
+	start := -1
+	for i, r := range s {
+		// Una marca combinante sigue la palabra en la que está.
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || (start >= 0 && unicode.Is(unicode.Mn, r))
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			toks = append(toks, token{normalizeWord(s[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{normalizeWord(s[start:]), start, len(s)})
+	}
+	return toks
+}
+
+// indexTerms devuelve los términos de s que se indexan (sin stopwords).
+func indexTerms(s string) []string {
+	var terms []string
+	for _, tk := range tokenize(s) {
+		if !stopwords[tk.term] {
+			terms = append(terms, tk.term)
+		}
+	}
+	return terms
+}
+
+// add indexa (o reindexa) la tarea t.
+func (ix *searchIndex) add(t Task) {
+	ix.remove(t.ID)
+	terms := indexTerms(t.Title)
+	for _, term := range terms {
+		docs, ok := ix.postings[term]
+		if !ok {
+			docs = make(map[int]int)
+			ix.postings[term] = docs
+			ix.insertTerm(term)
+		}
+		docs[t.ID]++
+	}
+	ix.docs[t.ID] = t
+	ix.docTerms[t.ID] = terms
+}
+
+// remove elimina la tarea con ese ID del índice, si estaba.
+func (ix *searchIndex) remove(id int) {
+	terms, ok := ix.docTerms[id]
+	if !ok {
+		return
+	}
+	for _, term := range terms {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+			ix.deleteTerm(term)
+		}
+	}
+	delete(ix.docs, id)
+	delete(ix.docTerms, id)
+}
+
+// insertTerm mantiene ix.terms ordenado al añadir un término nuevo.
+func (ix *searchIndex) insertTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+// deleteTerm quita un término de ix.terms.
+func (ix *searchIndex) deleteTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// searchHit es un resultado de búsqueda con su puntuación.
+type searchHit struct {
+	Task    Task    `json:"task"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// prefixWeight penaliza las coincidencias por prefijo frente a las exactas.
+const prefixWeight = 0.5
+
+// search devuelve las tareas que contienen todos los términos de q
+// (exactos o como prefijo), ordenadas por relevancia (tf-idf).
+func (ix *searchIndex) search(q string) []searchHit {
+	qterms := uniqueTerms(indexTerms(q))
+	if len(qterms) == 0 {
+		return []searchHit{}
+	}
+
+	n := float64(len(ix.docs))
+	var scores map[int]float64
+	for _, qt := range qterms {
+		termScores := make(map[int]float64)
+		// Recorrer los términos que empiezan por qt (incluido qt mismo).
+		for i := sort.SearchStrings(ix.terms, qt); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], qt); i++ {
+			term := ix.terms[i]
+			docs := ix.postings[term]
+			weight := math.Log(1 + n/float64(len(docs)))
+			if term != qt {
+				weight *= prefixWeight
+			}
+			for id, tf := range docs {
+				termScores[id] += float64(tf) * weight
+			}
+		}
+
+		// Intersección: la tarea debe casar con todos los términos.
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]searchHit, 0, len(scores))
+	for id, score := range scores {
+		t := ix.docs[id]
+		hits = append(hits, searchHit{Task: t, Score: score, Snippet: highlight(t.Title, qterms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Task.ID < hits[j].Task.ID
+	})
+	return hits
+}
+
+// uniqueTerms elimina términos repetidos conservando el orden.
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	out := terms[:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// snippetRadius es el número de bytes de contexto a cada lado de la
+// primera coincidencia cuando el texto es largo.
+const snippetRadius = 60
+
+// highlight devuelve un fragmento de text escapado para HTML con las
+// palabras que casan con qterms envueltas en <mark>.
+func highlight(text string, qterms []string) string {
+	toks := tokenize(text)
+	var marks []token
+	for _, tk := range toks {
+		for _, qt := range qterms {
+			if strings.HasPrefix(tk.term, qt) {
+				marks = append(marks, tk)
+				break
+			}
+		}
+	}
+
+	// Recortar alrededor de la primera coincidencia.
+	from, to := 0, len(text)
+	if len(marks) > 0 && len(text) > 2*snippetRadius {
+		from = max(0, marks[0].start-snippetRadius)
+		to = min(len(text), marks[0].end+snippetRadius)
+		// Ajustar a límites de palabra para no cortar runas ni palabras.
+		for _, tk := range toks {
+			if tk.start < from && tk.end > from {
+				from = tk.start
+			}
+			if tk.start < to && tk.end > to {
+				to = tk.end
+			}
+		}
+		for from > 0 && !utf8.RuneStart(text[from]) {
+			from--
+		}
+		for to < len(text) && !utf8.RuneStart(text[to]) {
+			to++
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, m := range marks {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// searchHandler maneja /search?q=texto&limit=n.
+// Devuelve las tareas ordenadas por relevancia con un fragmento resaltado.
+func (srv *server) searchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, r, http.StatusBadRequest, codeQueryRequired)
+		return
+	}
+	limit := 20
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, r, http.StatusBadRequest, codeInvalidLimit)
+			return
+		}
+		limit = n
+	}
+
+	srv.store.mu.RLock()
+	hits := srv.store.index.search(q)
+	srv.store.mu.RUnlock()
+
+	total := len(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"query":   q,
+		"total":   total,
+		"results": hits,
+	})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []token
+	}{
+		{"", nil},
+		{"  ¡¿!!  ", nil},
+		{"Aprender Go", []token{{"aprender", 0, 8}, {"go", 9, 11}}},
+		{"HTTP/2, v1.21", []token{{"http", 0, 4}, {"2", 5, 6}, {"v1", 8, 10}, {"21", 11, 13}}},
+		// Las posiciones son en bytes del texto original, no del normalizado.
+		{"Canción Ñandú", []token{{"cancion", 0, 8}, {"nandu", 9, 16}}},
+		// Con el acento como marca combinante (NFD) la palabra sigue entera.
+		{"Cancio\u0301n", []token{{"cancion", 0, 9}}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v; want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWord(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Canción", "cancion"},
+		{"ÁÉÍÓÚ", "aeiou"},
+		{"pingüino", "pinguino"},
+		{"Façade", "facade"},
+		{"año", "ano"},
+		{"go", "go"},
+		// Cualquier letra que se descompone en base y marcas.
+		{"Łódź", "łodz"}, // la barra de la Ł no es una marca combinante
+		{"Ångström", "angstrom"},
+		{"Tiếng Việt", "tieng viet"},
+		{"Ελληνικά", "ελληνικα"},
+		{"ma\u0301s", "mas"}, // ya descompuesto
+	}
+	for _, tt := range tests {
+		if got := normalizeWord(tt.in); got != tt.want {
+			t.Errorf("normalizeWord(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIndexTermsSkipsStopwords(t *testing.T) {
+	want := []string{"aprender", "concurrencia", "go"}
+	if got := indexTerms("Aprender la concurrencia de Go"); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexTerms = %q; want %q", got, want)
+	}
+	if got := indexTerms("the and de la"); got != nil {
+		t.Errorf("indexTerms(solo stopwords) = %q; want nada", got)
+	}
+}
+
+// newTestIndex indexa los títulos con IDs 1, 2, 3...
+func newTestIndex(titles ...string) *searchIndex {
+	ix := newSearchIndex()
+	for i, title := range titles {
+		ix.add(Task{ID: i + 1, Title: title})
+	}
+	return ix
+}
+
+// hitIDs devuelve los IDs de los resultados en orden.
+func hitIDs(hits []searchHit) []int {
+	ids := []int{}
+	for _, h := range hits {
+		ids = append(ids, h.Task.ID)
+	}
+	return ids
+}
+
+func TestSearchMatching(t *testing.T) {
+	ix := newTestIndex(
+		"Aprender Goroutines",  // 1
+		"Escribir una canción", // 2
+		"Go y canales",         // 3
+		"Canal de Panamá",      // 4
+	)
+	tests := []struct {
+		name string
+		q    string
+		want []int
+	}{
+		{"exacta", "goroutines", []int{1}},
+		{"mayúsculas", "GOROUTINES", []int{1}},
+		{"sin acento encuentra con acento", "cancion", []int{2}},
+		{"con acento encuentra sin acento", "cánción", []int{2}},
+		{"prefijo", "gor", []int{1}},
+		{"prefijo con varias coincidencias", "can", []int{2, 3, 4}},
+		{"todas las palabras", "go canales", []int{3}},
+		{"falta una palabra", "go panama", []int{}},
+		{"solo stopwords", "de la", []int{}},
+		{"sin resultados", "rust", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hitIDs(ix.search(tt.q))
+			// El orden lo prueba TestSearchRanking; aquí solo el conjunto.
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search(%q) = %v; want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	tests := []struct {
+		name   string
+		titles []string
+		q      string
+		want   []int
+	}{
+		// La coincidencia exacta pesa más que la de prefijo.
+		{"exacta antes que prefijo", []string{"Revisar canales", "Revisar canal"}, "canal", []int{2, 1}},
+		// Repetir el término sube la frecuencia.
+		{"más frecuencia primero", []string{"test", "test test"}, "test", []int{2, 1}},
+		// Un término raro aporta más que uno que está en todas partes.
+		{"idf", []string{"go informe", "go tests", "go informe tests tests"}, "go tests", []int{3, 2}},
+		// Con la misma puntuación, gana el ID menor.
+		{"empate por ID", []string{"leer", "leer", "leer"}, "leer", []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := newTestIndex(tt.titles...).search(tt.q)
+			if got := hitIDs(hits); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search(%q) = %v; want %v", tt.q, got, tt.want)
+			}
+			for i := 1; i < len(hits); i++ {
+				if hits[i].Score > hits[i-1].Score {
+					t.Errorf("puntuaciones fuera de orden: %v", hits)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := "Una tarea con un título muy largo que habla de muchas cosas antes de llegar a la palabra clave goroutines y después sigue hablando un buen rato más de otras cosas que no importan"
+	tests := []struct {
+		name   string
+		text   string
+		qterms []string
+		want   string
+	}{
+		{"marca la palabra", "Aprender Go", []string{"go"}, "Aprender <mark>Go</mark>"},
+		{"marca por prefijo", "Goroutines y canales", []string{"gor"}, "<mark>Goroutines</mark> y canales"},
+		{"conserva los acentos", "Una canción", []string{"cancion"}, "Una <mark>canción</mark>"},
+		{"escapa el HTML", `<script>alert("go")</script>`, []string{"go"}, "&lt;script&gt;alert(&#34;<mark>go</mark>&#34;)&lt;/script&gt;"},
+		{"escapa dentro de la marca", "a&b go", []string{"b"}, "a&amp;<mark>b</mark> go"},
+		{"sin coincidencias", "x < y", []string{"z"}, "x &lt; y"},
+		{"recorta textos largos", long, []string{"goroutines"},
+			"…que habla de muchas cosas antes de llegar a la palabra clave <mark>goroutines</mark> y después sigue hablando un buen rato más de otras cosas …"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.text, tt.qterms); got != tt.want {
+				t.Errorf("highlight = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndexFollowsStore(t *testing.T) {
+	st := newTaskStore()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.createLocked(Task{Title: "Aprender Go"})
+	st.createLocked(Task{Title: "Comprar pan"})
+
+	if got := hitIDs(st.index.search("go")); !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("antes de editar: search(go) = %v; want [1]", got)
+	}
+
+	// Al editar, el título viejo deja de encontrarse y el nuevo sí.
+	st.updateLocked(Task{ID: 1, Title: "Aprender Rust"})
+	if got := hitIDs(st.index.search("go")); len(got) != 0 {
+		t.Errorf("tras editar: search(go) = %v; want []", got)
+	}
+	if got := hitIDs(st.index.search("rust")); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("tras editar: search(rust) = %v; want [1]", got)
+	}
+
+	// Al borrar desaparece, y con ella sus términos.
+	st.removeLocked(2)
+	if got := hitIDs(st.index.search("pan")); len(got) != 0 {
+		t.Errorf("tras borrar: search(pan) = %v; want []", got)
+	}
+	if _, ok := st.index.postings["pan"]; ok {
+		t.Error("el término de la tarea borrada sigue en el índice")
+	}
+	want := []string{"aprender", "rust"}
+	if !reflect.DeepEqual(st.index.terms, want) {
+		t.Errorf("terms = %q; want %q", st.index.terms, want)
+	}
+}
+
+func TestSearchHandlerLimit(t *testing.T) {
+	srv := newServer(newTaskStore())
+	srv.store.mu.Lock()
+	for _, title := range []string{"go 1", "go 2", "go 3"} {
+		srv.store.createLocked(Task{Title: title})
+	}
+	srv.store.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	srv.routes().ServeHTTP(w, httptest.NewRequest("GET", "/search?q=go&limit=2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200", w.Code)
+	}
+	var resp struct {
+		Query   string      `json:"query"`
+		Total   int         `json:"total"`
+		Results []searchHit `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Query != "go" || resp.Total != 3 || len(resp.Results) != 2 {
+		t.Errorf("respuesta = %+v; want query go, total 3 y 2 resultados", resp)
+	}
+	if resp.Results[0].Snippet != "<mark>go</mark> 1" {
+		t.Errorf("snippet = %q", resp.Results[0].Snippet)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+)
+
+// taskStore es el almacén de tareas. Antes era un conjunto de variables
+// globales; como struct se pueden tener varios en un mismo proceso (por
+// ejemplo un líder y un seguidor de replicación en un test).
+//
+// Todas las mutaciones pasan por los métodos *Locked de este fichero, así
+// los índices y estructuras derivadas se mantienen al día en un único
+// sitio. Todos requieren que el llamador tenga mu tomado; las lecturas
+// (getLocked, listLocked, allLocked) se conforman con mu.RLock.
+type taskStore struct {
+	// mu asegura concurrencia segura cuando hay múltiples requests. Es un
+	// RWMutex y no un lock por shard porque cada escritura toca también la
+	// revisión y el log de cambios, que son globales; las lecturas, que son
+	// la mayoría, no se bloquean entre sí. Los handlers copian lo que
+	// necesitan y codifican la respuesta ya sin el lock.
+	mu sync.RWMutex
+	// tasks indexa las tareas por ID; order guarda los IDs en orden de
+	// creación (que es el orden de /tasks). Como los IDs siempre crecen,
+	// order está ordenado y se puede buscar en él por bisección.
+	tasks  map[int]Task
+	order  []int
+	nextID int
+	// owners cuenta las tareas de cada usuario, para la cuota (ver
+	// limits.go); se mantiene junto con tasks.
+	owners map[string]int
+	// nextAttachmentID es el ID del próximo adjunto (ver attachments.go).
+	nextAttachmentID int
+
+	// Proyectos (tableros Kanban) que agrupan tareas; ver projects.go.
+	projects      []Project
+	nextProjectID int
+
+	// Registro de cambios para /tasks/changes (ver changes.go).
+	revision  int64
+	changeLog []change
+	// changed se cierra (y se reemplaza) en cada mutación para despertar a
+	// todos los clientes que esperan.
+	changed chan struct{}
+
+	// index es el índice de búsqueda usado por /search.
+	index *searchIndex
+
+	// trail es el registro de auditoría de la petición que tiene mu
+	// tomado (ver lockFor); nil si no se audita.
+	trail *auditTrail
+
+	// path es el fichero de datos; vacío significa solo memoria.
+	path string
+	// lastPersistErr es el error de la última escritura (nil si fue bien).
+	// Lo consulta /readyz.
+	lastPersistErr error
+}
+
+// newTaskStore crea un almacén vacío en memoria.
+func newTaskStore() *taskStore {
+	return &taskStore{
+		tasks:            make(map[int]Task),
+		owners:           make(map[string]int),
+		nextID:           1,
+		nextAttachmentID: 1,
+		projects:         make([]Project, 0),
+		nextProjectID:    1,
+		changed:          make(chan struct{}),
+		index:            newSearchIndex(),
+	}
+}
+
+// lockFor toma mu para escribir en nombre de la petición de ctx: si la
+// petición se audita, los cambios de tareas y proyectos hasta unlock se
+// anotan en su registro (ver auditTrail).
+func (s *taskStore) lockFor(ctx context.Context) {
+	s.mu.Lock()
+	s.trail = auditTrailFrom(ctx)
+}
+
+// unlock suelta el lock tomado con lockFor. Antes escribe en la auditoría
+// los cambios hechos con él, en el orden de las revisiones.
+func (s *taskStore) unlock() {
+	if s.trail != nil {
+		s.trail.flush(s.revision)
+		s.trail = nil
+	}
+	s.mu.Unlock()
+}
+
+// createLocked asigna el siguiente ID a t y lo inserta.
+func (s *taskStore) createLocked(t Task) Task {
+	t.ID = s.nextID
+	s.nextID++
+	s.insertLocked(t)
+	return t
+}
+
+// insertLocked agrega t al almacén, lo indexa, registra el cambio y lo
+// persiste.
+func (s *taskStore) insertLocked(t Task) {
+	if old, ok := s.tasks[t.ID]; ok {
+		s.countOwnerLocked(old.Owner, -1)
+	}
+	s.tasks[t.ID] = t
+	s.countOwnerLocked(t.Owner, 1)
+	if n := len(s.order); n > 0 && s.order[n-1] > t.ID {
+		// No pasa con IDs crecientes, pero mantiene order ordenado.
+		i, _ := slices.BinarySearch(s.order, t.ID)
+		s.order = slices.Insert(s.order, i, t.ID)
+	} else {
+		s.order = append(s.order, t.ID)
+	}
+	s.index.add(t)
+	s.noteAttachmentsLocked(t)
+	s.noteAuditLocked(t.ID, nil, &t)
+	s.recordChangeLocked(opCreate, t.ID, &t)
+	s.persistLocked()
+}
+
+// getLocked devuelve la tarea con ese ID.
+func (s *taskStore) getLocked(id int) (Task, bool) {
+	t, ok := s.tasks[id]
+	return t, ok
+}
+
+// allLocked recorre las tareas en orden de creación.
+func (s *taskStore) allLocked() iter.Seq[Task] {
+	return func(yield func(Task) bool) {
+		for _, id := range s.order {
+			if !yield(s.tasks[id]) {
+				return
+			}
+		}
+	}
+}
+
+// listLocked devuelve una copia de las tareas en orden de creación, para
+// usarla una vez soltado el lock.
+func (s *taskStore) listLocked() []Task {
+	ts := make([]Task, 0, len(s.order))
+	for _, id := range s.order {
+		ts = append(ts, s.tasks[id])
+	}
+	return ts
+}
+
+// updateLocked reemplaza la tarea con el mismo ID que t.
+// Devuelve false si no existe.
+func (s *taskStore) updateLocked(t Task) bool {
+	if _, ok := s.tasks[t.ID]; !ok {
+		return false
+	}
+	s.putLocked(&t)
+	s.recordChangeLocked(opUpdate, t.ID, &t)
+	s.persistLocked()
+	return true
+}
+
+// updateBatchLocked reemplaza varias tareas a la vez como un solo cambio
+// opReorder: una revisión y una escritura del fichero para todas. Devuelve
+// false, sin cambiar nada, si alguna no existe.
+func (s *taskStore) updateBatchLocked(ts []Task) bool {
+	for _, t := range ts {
+		if _, ok := s.tasks[t.ID]; !ok {
+			return false
+		}
+	}
+	if len(ts) == 0 {
+		return true
+	}
+	ts = slices.Clone(ts)
+	for i := range ts {
+		s.putLocked(&ts[i])
+	}
+	s.appendChangeLocked(change{Op: opReorder, Tasks: ts})
+	s.persistLocked()
+	return true
+}
+
+// putLocked reemplaza la tarea existente con el ID de t y pone al día lo
+// que depende de ella. Registrar el cambio y persistir queda para el
+// llamador.
+func (s *taskStore) putLocked(t *Task) {
+	old := s.tasks[t.ID]
+	touchLocked(t, old)
+	s.tasks[t.ID] = *t
+	s.countOwnerLocked(old.Owner, -1)
+	s.countOwnerLocked(t.Owner, 1)
+	s.index.add(*t)
+	s.noteAttachmentsLocked(*t)
+	s.noteAuditLocked(t.ID, &old, t)
+}
+
+// touchLocked pone la hora actual en t.UpdatedAt si el cambio es local:
+// los cambios locales parten de la tarea guardada (old) y llegan con su
+// misma UpdatedAt, mientras que los que aplica una réplica ya traen la del
+// líder y se respetan.
+func touchLocked(t *Task, old Task) {
+	if !t.UpdatedAt.After(old.UpdatedAt) {
+		t.UpdatedAt = time.Now().UTC()
+	}
+}
+
+// removeLocked elimina la tarea con ese ID conservando el orden del
+// resto. Las tareas de ts (las que se renumeran al cerrar el hueco en su
+// columna) se guardan en el mismo cambio opDelete. Devuelve false, sin
+// cambiar nada, si alguna no existe.
+func (s *taskStore) removeLocked(id int, ts ...Task) bool {
+	old, ok := s.tasks[id]
+	if !ok {
+		return false
+	}
+	for _, t := range ts {
+		if _, ok := s.tasks[t.ID]; !ok || t.ID == id {
+			return false
+		}
+	}
+	delete(s.tasks, id)
+	s.countOwnerLocked(old.Owner, -1)
+	if i, ok := slices.BinarySearch(s.order, id); ok {
+		s.order = slices.Delete(s.order, i, i+1)
+	}
+	s.index.remove(id)
+	s.noteAuditLocked(id, &old, nil)
+	ts = slices.Clone(ts)
+	for i := range ts {
+		s.putLocked(&ts[i])
+	}
+	s.appendChangeLocked(change{Op: opDelete, TaskID: id, Tasks: ts})
+	s.persistLocked()
+	return true
+}
+
+// setTasksLocked reemplaza todas las tareas por ts, sin registrar cambios.
+func (s *taskStore) setTasksLocked(ts []Task) {
+	s.tasks = make(map[int]Task, len(ts))
+	s.order = make([]int, 0, len(ts))
+	s.owners = make(map[string]int)
+	for _, t := range ts {
+		s.tasks[t.ID] = t
+		s.order = append(s.order, t.ID)
+		s.owners[t.Owner]++
+	}
+	slices.Sort(s.order)
+}
+
+// countOwnerLocked suma delta a las tareas de owner.
+func (s *taskStore) countOwnerLocked(owner string, delta int) {
+	s.owners[owner] += delta
+	if s.owners[owner] <= 0 {
+		delete(s.owners, owner)
+	}
+}
+
+// replaceLocked sustituye todo el contenido por snap de una vez: reconstruye
+// el índice, vacía el log de cambios (los clientes atrasados recibirán 410 y
+// releerán /tasks) y despierta a los que esperan.
+func (s *taskStore) replaceLocked(snap snapshot) {
+	if s.trail != nil {
+		// Para la auditoría, cada tarea que cambia, aparece o desaparece.
+		old := s.tasks
+		for _, t := range snap.Tasks {
+			if before, ok := old[t.ID]; !ok {
+				s.trail.note(t.ID, nil, &t)
+			} else if taskHash(&before) != taskHash(&t) {
+				s.trail.note(t.ID, &before, &t)
+			}
+		}
+		kept := make(map[int]bool, len(snap.Tasks))
+		for _, t := range snap.Tasks {
+			kept[t.ID] = true
+		}
+		for _, id := range s.order {
+			if before := old[id]; !kept[id] {
+				s.trail.note(id, &before, nil)
+			}
+		}
+		// Y lo mismo con los proyectos.
+		oldProjects := make(map[int]Project, len(s.projects))
+		for _, p := range s.projects {
+			oldProjects[p.ID] = p
+		}
+		for _, p := range snap.Projects {
+			if before, ok := oldProjects[p.ID]; !ok {
+				s.trail.noteProject(p.ID, nil, &p)
+			} else if projectHash(&before) != projectHash(&p) {
+				s.trail.noteProject(p.ID, &before, &p)
+			}
+			delete(oldProjects, p.ID)
+		}
+		for _, p := range s.projects {
+			if _, gone := oldProjects[p.ID]; gone {
+				s.trail.noteProject(p.ID, &p, nil)
+			}
+		}
+	}
+	s.setTasksLocked(snap.Tasks)
+	s.index = newSearchIndex()
+	s.nextAttachmentID = max(snap.NextAttachmentID, 1)
+	for _, t := range s.tasks {
+		s.index.add(t)
+		s.noteAttachmentsLocked(t)
+	}
+	s.nextID = max(snap.NextID, 1)
+	s.projects = append(make([]Project, 0, len(snap.Projects)), snap.Projects...)
+	s.nextProjectID = max(snap.NextProjectID, 1)
+	s.revision = snap.Revision
+	s.changeLog = nil
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.persistLocked()
+}
+
+// snapshotLocked devuelve una copia del estado completo.
+func (s *taskStore) snapshotLocked() snapshot {
+	return snapshot{
+		NextID:           s.nextID,
+		NextAttachmentID: s.nextAttachmentID,
+		NextProjectID:    s.nextProjectID,
+		Revision:         s.revision,
+		Tasks:            s.listLocked(),
+		Projects:         append(make([]Project, 0, len(s.projects)), s.projects...),
+	}
+}
+
+// noteAttachmentsLocked asegura que nextAttachmentID no repita ningún ID
+// de los adjuntos de t (importa al cargar datos o aplicar cambios de otro).
+func (s *taskStore) noteAttachmentsLocked(t Task) {
+	for _, a := range t.Attachments {
+		s.nextAttachmentID = max(s.nextAttachmentID, a.ID+1)
+	}
+}
+
+// recordChangeLocked registra un cambio de la tarea id.
+func (s *taskStore) recordChangeLocked(op string, id int, t *Task) {
+	if t != nil {
+		snap := *t // copia: el log no debe ver cambios posteriores
+		t = &snap
+	}
+	s.appendChangeLocked(change{Op: op, TaskID: id, Task: t})
+}
+
+// appendChangeLocked incrementa la revisión, guarda el cambio y despierta
+// a los que esperan.
+func (s *taskStore) appendChangeLocked(c change) {
+	s.revision++
+	c.Revision, c.At = s.revision, time.Now().UTC()
+	s.changeLog = append(s.changeLog, c)
+	if len(s.changeLog) > maxChangeLog {
+		// Sin copiar: append ya reserva un array nuevo de vez en cuando y
+		// el principio del antiguo se libera entonces.
+		s.changeLog = s.changeLog[len(s.changeLog)-maxChangeLog:]
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// changesSinceLocked devuelve los cambios posteriores a since, o false si
+// el log ya no los contiene.
+func (s *taskStore) changesSinceLocked(since int64) ([]change, bool) {
+	if since >= s.revision {
+		return []change{}, true
+	}
+	if len(s.changeLog) == 0 || s.changeLog[0].Revision > since+1 {
+		return nil, false
+	}
+	i := int(since + 1 - s.changeLog[0].Revision)
+	return append([]change(nil), s.changeLog[i:]...), true
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Soporte TLS nativo: certificado desde ficheros (recargado en caliente
+// cuando cambian), CA de clientes opcional para mTLS y un modo de
+// desarrollo con un certificado autofirmado efímero.
+
+// certReloadInterval es cada cuánto se comprueba si los ficheros cambiaron.
+const certReloadInterval = 5 * time.Second
+
+// certReloader sirve el certificado actual y lo recarga cuando cambian
+// los ficheros en disco. Si la recarga falla se sigue usando el anterior.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time // la más reciente de ambos ficheros
+}
+
+// newCertReloader carga el par certificado/clave por primera vez.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate implementa tls.Config.GetCertificate.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// filesModTime devuelve la fecha de modificación más reciente.
+func (c *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{c.certFile, c.keyFile} {
+		st, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload lee de nuevo los ficheros.
+func (c *certReloader) reload() error {
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return nil
+}
+
+// reloadIfChanged recarga solo si los ficheros son más nuevos que el
+// certificado cargado. Devuelve true si hubo recarga.
+func (c *certReloader) reloadIfChanged() (bool, error) {
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return false, err
+	}
+	c.mu.RLock()
+	changed := !modTime.Equal(c.modTime)
+	c.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, c.reload()
+}
+
+// watch comprueba periódicamente los ficheros hasta que se cierre stop.
+func (c *certReloader) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloaded, err := c.reloadIfChanged()
+			switch {
+			case err != nil:
+				slog.Error("error recargando certificado (se mantiene el anterior)", "err", err)
+			case reloaded:
+				slog.Info("certificado TLS recargado", "path", c.certFile)
+			}
+		}
+	}
+}
+
+// newTLSConfig construye la configuración TLS del servidor según cfg.
+// Devuelve nil si TLS no está activado.
+func newTLSConfig(cfg config) (*tls.Config, error) {
+	if !cfg.tlsEnabled() {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.DevSelfSigned {
+		cert, err := selfSignedCert([]string{"localhost", "127.0.0.1", "::1"}, 24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(cert.Certificate[0])
+		slog.Warn("⚠️ usando certificado autofirmado efímero, solo para desarrollo", "sha256", hex.EncodeToString(sum[:]))
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	} else {
+		reloader, err := newCertReloader(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("cargando certificado: %w", err)
+		}
+		go reloader.watch(certReloadInterval, nil) // vive lo que el proceso
+		tlsCfg.GetCertificate = reloader.GetCertificate
+	}
+
+	if cfg.TLSClientCA != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("leyendo CA de clientes: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("la CA de clientes no contiene certificados PEM válidos")
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// selfSignedCert genera un certificado ECDSA autofirmado en memoria para
+// los nombres dados (DNS o IP).
+func selfSignedCert(hosts []string, validFor time.Duration) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"golang-101 dev"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// userFromRequest devuelve la identidad del cliente: el CN de su
+// certificado cuando se autenticó con mTLS, o "" si es anónimo.
+func userFromRequest(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// whoamiHandler maneja /whoami: devuelve la identidad con la que el
+// servidor ve al cliente (útil para comprobar la configuración mTLS).
+func whoamiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"user": userFromRequest(r)})
+}
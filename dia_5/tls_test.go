@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEMPair guarda cert en dir como cert.pem/key.pem.
+func writePEMPair(t *testing.T, dir string, cert tls.Certificate) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertReloaderPicksUpNewCert(t *testing.T) {
+	dir := t.TempDir()
+	first, err := selfSignedCert([]string{"localhost"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writePEMPair(t, dir, first)
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := r.reloadIfChanged(); reloaded {
+		t.Errorf("recargó sin cambios en disco")
+	}
+
+	second, err := selfSignedCert([]string{"localhost"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEMPair(t, dir, second)
+	future := time.Now().Add(time.Minute) // forzar mtime distinto
+	os.Chtimes(certFile, future, future)
+
+	if reloaded, err := r.reloadIfChanged(); !reloaded || err != nil {
+		t.Fatalf("reloadIfChanged = %v, %v; want true, nil", reloaded, err)
+	}
+	got, _ := r.GetCertificate(nil)
+	if string(got.Certificate[0]) != string(second.Certificate[0]) {
+		t.Errorf("sigue sirviendo el certificado anterior")
+	}
+
+	// Un fichero roto no debe tumbar el certificado en uso.
+	os.WriteFile(certFile, []byte("basura"), 0o600)
+	future = future.Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if _, err := r.reloadIfChanged(); err == nil {
+		t.Errorf("se esperaba error con un certificado inválido")
+	}
+	if got, _ := r.GetCertificate(nil); string(got.Certificate[0]) != string(second.Certificate[0]) {
+		t.Errorf("un fallo de recarga reemplazó el certificado válido")
+	}
+}
+
+func TestMutualTLSMapsCommonNameToUser(t *testing.T) {
+	// CA de clientes y un certificado de cliente firmado por ella.
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	clientKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ana"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0o600)
+
+	tlsCfg, err := newTLSConfig(config{DevSelfSigned: true, TLSClientCA: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(whoamiHandler))
+	srv.TLS = tlsCfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true, // certificado de servidor autofirmado
+		Certificates:       []tls.Certificate{{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}},
+	}}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct{ User string }
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.User != "ana" {
+		t.Errorf("user = %q; want %q", body.User, "ana")
+	}
+
+	// Sin certificado de cliente la conexión se rechaza.
+	anon := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if resp, err := anon.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("se aceptó un cliente sin certificado")
+	}
+}
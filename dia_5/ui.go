@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Interfaz web mínima para gestionar tareas sin usar curl.
+// Funciona solo con formularios HTML (patrón POST → redirect → GET);
+// si hay JavaScript, ui/static/app.js envía los formularios con fetch y
+// reemplaza la lista sin recargar la página.
+
+//go:embed ui
+var uiFiles embed.FS
+
+var uiTemplate = template.Must(template.ParseFS(uiFiles, "ui/index.html"))
+
+// Protección CSRF: cada navegador recibe una cookie de sesión aleatoria y
+// los formularios llevan un token que es el HMAC de esa sesión con una
+// clave generada al arrancar el servidor.
+const (
+	csrfCookie = "csrf_session"
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+var csrfKey = randomBytes(32)
+
+// randomBytes devuelve n bytes aleatorios criptográficamente seguros.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("no se pudo generar aleatoriedad: " + err.Error())
+	}
+	return b
+}
+
+// csrfToken calcula el token asociado a un ID de sesión.
+func csrfToken(session string) string {
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ensureCSRFSession devuelve la sesión CSRF del navegador, creando la
+// cookie si todavía no existe.
+func ensureCSRFSession(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+	session := base64.RawURLEncoding.EncodeToString(randomBytes(16))
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return session
+}
+
+// validCSRF comprueba que el token del formulario (o de la cabecera, para
+// las peticiones hechas con fetch) corresponde a la cookie de sesión.
+func validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	return hmac.Equal([]byte(token), []byte(csrfToken(c.Value)))
+}
+
+// uiPage son los datos que recibe la plantilla.
+type uiPage struct {
+	Tasks     []Task
+	Filter    string // "", "pending" o "done"
+	Query     string
+	CSRFToken string
+	Error     string
+	NewTitle  string // se conserva si la creación falla
+	// MaxTitleLen es el maxlength del título: el mismo límite que aplica
+	// la API (max_title_len).
+	MaxTitleLen int
+}
+
+// ReturnURL es la URL de la lista con los filtros actuales, a la que se
+// vuelve después de cada acción.
+func (p uiPage) ReturnURL() string {
+	v := url.Values{}
+	if p.Filter != "" {
+		v.Set("filter", p.Filter)
+	}
+	if p.Query != "" {
+		v.Set("q", p.Query)
+	}
+	return "/?" + v.Encode()
+}
+
+// uiHandler maneja / (solo la raíz) y pinta la lista de tareas.
+func (srv *server) uiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	srv.renderUI(w, r, http.StatusOK, "", "")
+}
+
+// renderUI pinta la página aplicando los filtros de la query string.
+func (srv *server) renderUI(w http.ResponseWriter, r *http.Request, status int, errMsg, newTitle string) {
+	page := uiPage{
+		Filter:    r.URL.Query().Get("filter"),
+		Query:     strings.TrimSpace(r.URL.Query().Get("q")),
+		CSRFToken: csrfToken(ensureCSRFSession(w, r)),
+		Error:     errMsg,
+		NewTitle:  newTitle,
+
+		MaxTitleLen: maxTitleLen,
+	}
+
+	needle := normalizeWord(page.Query)
+	srv.store.mu.RLock()
+	for t := range srv.store.allLocked() {
+		if (page.Filter == "pending" && t.Done) || (page.Filter == "done" && !t.Done) {
+			continue
+		}
+		if needle != "" && !strings.Contains(normalizeWord(t.Title), needle) {
+			continue
+		}
+		page.Tasks = append(page.Tasks, t)
+	}
+	srv.store.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = uiTemplate.Execute(w, page)
+}
+
+// uiCreateHandler maneja POST /ui/tasks desde el formulario de alta.
+func (srv *server) uiCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if !uiCheckPost(w, r) {
+		return
+	}
+
+	title := r.PostFormValue("title")
+	if _, err := srv.store.addTask(r.Context(), taskInput{Title: title}, userFromRequest(r)); err != nil {
+		status, msg := uiStoreError(r, err)
+		srv.renderUI(w, r, status, msg, title)
+		return
+	}
+	uiRedirect(w, r)
+}
+
+// uiStoreError traduce un error de addTask al status y al mensaje que se
+// muestran en la página, en el idioma de la petición.
+func uiStoreError(r *http.Request, err error) (int, string) {
+	lang := requestLang(r)
+	var fe *fieldError
+	switch {
+	case errors.Is(err, errQuotaExceeded):
+		return http.StatusForbidden, localize(lang, codeQuotaExceeded, maxTasksPerUser).Detail
+	case errors.As(err, &fe):
+		return http.StatusBadRequest, localizeFieldErrors(lang, fe)[0].Detail
+	default:
+		return http.StatusInternalServerError, localize(lang, codeInternalError).Detail
+	}
+}
+
+// uiTaskActionHandler maneja POST /ui/tasks/{id}/toggle y
+// POST /ui/tasks/{id}/delete.
+func (srv *server) uiTaskActionHandler(w http.ResponseWriter, r *http.Request) {
+	if !uiCheckPost(w, r) {
+		return
+	}
+
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/ui/tasks/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeInvalidID)
+		return
+	}
+
+	st := srv.store
+	st.lockFor(r.Context())
+	found := false
+	switch action {
+	case "toggle":
+		var t Task
+		if t, found = st.getLocked(id); found {
+			t.Done = !t.Done
+			st.updateLocked(t)
+		}
+	case "delete":
+		found = st.deleteTaskLocked(id)
+	default:
+		st.unlock()
+		http.NotFound(w, r)
+		return
+	}
+	st.unlock()
+
+	if !found {
+		writeError(w, r, http.StatusNotFound, codeTaskNotFound, id)
+		return
+	}
+	uiRedirect(w, r)
+}
+
+// uiCheckPost valida método y token CSRF de una acción de la interfaz.
+func uiCheckPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "POST")
+		return false
+	}
+	if !validCSRF(r) {
+		writeError(w, r, http.StatusForbidden, codeCSRFInvalid)
+		return false
+	}
+	return true
+}
+
+// uiRedirect vuelve a la lista conservando los filtros que venían en el
+// formulario (patrón POST → redirect → GET).
+func uiRedirect(w http.ResponseWriter, r *http.Request) {
+	target := "/"
+	if ret := r.PostFormValue("return"); strings.HasPrefix(ret, "/?") {
+		target = ret
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// uiStaticHandler sirve los ficheros estáticos embebidos (CSS y JS).
+func uiStaticHandler() http.Handler {
+	static, err := fs.Sub(uiFiles, "ui/static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/ui/static/", http.FileServer(http.FS(static)))
+}
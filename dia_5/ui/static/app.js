@@ -0,0 +1,44 @@
+// Mejora progresiva: si hay JavaScript, los formularios se envían con
+// fetch y se reemplaza solo el contenido de <main>, sin recargar la página.
+// Sin JavaScript todo sigue funcionando con formularios normales.
+(function () {
+  "use strict";
+
+  async function submit(form) {
+    const data = new FormData(form);
+    const opts = { credentials: "same-origin" };
+    let url = form.action;
+
+    if (form.method.toLowerCase() === "post") {
+      opts.method = "POST";
+      opts.body = new URLSearchParams(data);
+      opts.headers = { "X-CSRF-Token": data.get("csrf_token") || "" };
+    } else {
+      url += "?" + new URLSearchParams(data).toString();
+    }
+
+    const resp = await fetch(url, opts);
+    const html = await resp.text();
+    const doc = new DOMParser().parseFromString(html, "text/html");
+    const main = doc.getElementById("app");
+    if (!main) {
+      // Respuesta inesperada (p. ej. error en texto plano): recarga normal.
+      window.location.reload();
+      return;
+    }
+    document.getElementById("app").replaceWith(main);
+    history.replaceState(null, "", resp.redirected ? resp.url : url);
+  }
+
+  document.addEventListener("submit", function (ev) {
+    const form = ev.target;
+    if (form.dataset.confirm && !window.confirm(form.dataset.confirm)) {
+      ev.preventDefault();
+      return;
+    }
+    ev.preventDefault();
+    submit(form).catch(function () {
+      form.submit(); // si fetch falla, envío clásico
+    });
+  });
+})();
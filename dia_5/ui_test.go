@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// uiSession abre la página como un navegador y devuelve la cookie de
+// sesión y el token CSRF del formulario.
+func uiSession(t *testing.T, h http.Handler) (*http.Cookie, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /: status = %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookie {
+		t.Fatalf("cookies = %v; want %s", cookies, csrfCookie)
+	}
+	m := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if m == nil {
+		t.Fatal("la página no tiene token CSRF")
+	}
+	return cookies[0], m[1]
+}
+
+// uiPost envía un formulario de la interfaz con la cookie de sesión.
+func uiPost(h http.Handler, path string, cookie *http.Cookie, form url.Values, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestUICSRF(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+	cookie, token := uiSession(t, h)
+	other, _ := uiSession(t, h)
+
+	tests := []struct {
+		name       string
+		cookie     *http.Cookie
+		form       url.Values
+		header     map[string]string
+		wantStatus int
+	}{
+		{"token del formulario", cookie, url.Values{"title": {"a"}, csrfField: {token}}, nil, http.StatusSeeOther},
+		{"token en la cabecera (fetch)", cookie, url.Values{"title": {"b"}}, map[string]string{csrfHeader: token}, http.StatusSeeOther},
+		{"sin token", cookie, url.Values{"title": {"c"}}, nil, http.StatusForbidden},
+		{"token de otra sesión", other, url.Values{"title": {"d"}, csrfField: {token}}, nil, http.StatusForbidden},
+		{"sin cookie", nil, url.Values{"title": {"e"}, csrfField: {token}}, nil, http.StatusForbidden},
+		{"token alterado", cookie, url.Values{"title": {"f"}, csrfField: {token + "x"}}, nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := uiPost(h, "/ui/tasks", tt.cookie, tt.form, tt.header); w.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d (%s)", w.Code, tt.wantStatus, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+}
+
+func TestUIFlows(t *testing.T) {
+	srv := newServer(newTaskStore())
+	h := srv.routes()
+	cookie, token := uiSession(t, h)
+	form := func(kv ...string) url.Values {
+		v := url.Values{csrfField: {token}}
+		for i := 0; i+1 < len(kv); i += 2 {
+			v.Set(kv[i], kv[i+1])
+		}
+		return v
+	}
+	task := func(id int) (Task, bool) {
+		srv.store.mu.RLock()
+		defer srv.store.mu.RUnlock()
+		return srv.store.getLocked(id)
+	}
+
+	steps := []struct {
+		name         string
+		path         string
+		form         url.Values
+		wantStatus   int
+		wantLocation string
+		check        func(t *testing.T)
+	}{
+		{"alta", "/ui/tasks", form("title", "Aprender Go"), http.StatusSeeOther, "/", func(t *testing.T) {
+			if got, ok := task(1); !ok || got.Title != "Aprender Go" || got.Done {
+				t.Errorf("tarea 1 = %+v, %v", got, ok)
+			}
+		}},
+		{"alta conserva los filtros", "/ui/tasks", form("title", "Otra", "return", "/?filter=pending"), http.StatusSeeOther, "/?filter=pending", nil},
+		{"no redirige fuera", "/ui/tasks", form("title", "Tercera", "return", "https://evil.example/"), http.StatusSeeOther, "/", nil},
+		{"completar", "/ui/tasks/1/toggle", form(), http.StatusSeeOther, "/", func(t *testing.T) {
+			if got, _ := task(1); !got.Done {
+				t.Error("la tarea 1 no quedó completada")
+			}
+		}},
+		{"reabrir", "/ui/tasks/1/toggle", form("return", "/?q=go"), http.StatusSeeOther, "/?q=go", func(t *testing.T) {
+			if got, _ := task(1); got.Done {
+				t.Error("la tarea 1 sigue completada")
+			}
+		}},
+		{"eliminar", "/ui/tasks/1/delete", form(), http.StatusSeeOther, "/", func(t *testing.T) {
+			if _, ok := task(1); ok {
+				t.Error("la tarea 1 sigue existiendo")
+			}
+		}},
+		{"eliminar inexistente", "/ui/tasks/1/delete", form(), http.StatusNotFound, "", nil},
+		{"acción desconocida", "/ui/tasks/2/archive", form(), http.StatusNotFound, "", nil},
+		{"ID inválido", "/ui/tasks/x/toggle", form(), http.StatusBadRequest, "", nil},
+	}
+	for _, s := range steps {
+		w := uiPost(h, s.path, cookie, s.form, nil)
+		if w.Code != s.wantStatus {
+			t.Fatalf("%s: status = %d; want %d (%s)", s.name, w.Code, s.wantStatus, strings.TrimSpace(w.Body.String()))
+		}
+		if got := w.Header().Get("Location"); got != s.wantLocation {
+			t.Errorf("%s: Location = %q; want %q", s.name, got, s.wantLocation)
+		}
+		if s.check != nil {
+			s.check(t)
+		}
+	}
+}
+
+func TestUICreateErrors(t *testing.T) {
+	saved := maxTasksPerUser
+	maxTasksPerUser = 1
+	t.Cleanup(func() { maxTasksPerUser = saved })
+
+	h := newServer(newTaskStore()).routes()
+	cookie, token := uiSession(t, h)
+	tests := []struct {
+		name       string
+		title      string
+		lang       string
+		wantStatus int
+		wantError  string
+	}{
+		{"título vacío", "  ", "", http.StatusBadRequest, "title no puede estar vacío"},
+		{"título vacío en inglés", "", "en", http.StatusBadRequest, "title must not be empty"},
+		{"primera tarea", "una", "", http.StatusSeeOther, ""},
+		{"cuota superada", "dos", "", http.StatusForbidden, "has alcanzado el máximo de 1 tareas por usuario"},
+		{"cuota superada en inglés", "dos", "en", http.StatusForbidden, "you have reached the maximum of 1 tasks per user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := uiPost(h, "/ui/tasks", cookie, url.Values{"title": {tt.title}, csrfField: {token}}, map[string]string{"Accept-Language": tt.lang})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			// La página se vuelve a pintar con el error y el título escrito.
+			body := w.Body.String()
+			if !strings.Contains(body, `role="alert">`+tt.wantError+"<") {
+				t.Errorf("la página no muestra %q:\n%s", tt.wantError, body)
+			}
+			if !strings.Contains(body, `name="title" value="`+tt.title+`"`) {
+				t.Errorf("la página no conserva el título %q", tt.title)
+			}
+		})
+	}
+}
+
+func TestUITitleMaxLength(t *testing.T) {
+	saved := maxTitleLen
+	maxTitleLen = 50
+	t.Cleanup(func() { maxTitleLen = saved })
+
+	w := httptest.NewRecorder()
+	newServer(newTaskStore()).routes().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), `maxlength="50"`) {
+		t.Errorf("el campo de título no usa max_title_len:\n%s", w.Body.String())
+	}
+}
+
+func TestUIErrorsLocalized(t *testing.T) {
+	h := newServer(newTaskStore()).routes()
+	cookie, token := uiSession(t, h)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		form       url.Values
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{"sin CSRF", "POST", "/ui/tasks", url.Values{"title": {"a"}}, http.StatusForbidden, codeCSRFInvalid,
+			"the form expired or did not come from this page; reload it and try again"},
+		{"método", "GET", "/ui/tasks", nil, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed"},
+		{"método en /", "POST", "/", nil, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed"},
+		{"ID inválido", "POST", "/ui/tasks/x/toggle", url.Values{csrfField: {token}}, http.StatusBadRequest, codeInvalidID, "invalid ID"},
+		{"tarea inexistente", "POST", "/ui/tasks/9/toggle", url.Values{csrfField: {token}}, http.StatusNotFound, codeTaskNotFound, "task with id 9 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Accept-Language", "en")
+			r.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			var p problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.wantCode || p.Detail != tt.wantDetail {
+				t.Errorf("problema = %+v; want code %q, detail %q", p, tt.wantCode, tt.wantDetail)
+			}
+		})
+	}
+}